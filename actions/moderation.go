@@ -1,21 +1,149 @@
 package actions
 
-import "github.com/bwmarrin/discordgo"
+import (
+	"errors"
+	"time"
 
-func KickUser(s *discordgo.Session, guild string, user string, reason string) error {
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/discord"
+)
+
+// Types of moderation action.
+const (
+	ActionWarn      = "warn"
+	ActionTimeout   = "timeout"
+	ActionUntimeout = "untimeout"
+	ActionKick      = "kick"
+	ActionBan       = "ban"
+	ActionUnban     = "unban"
+)
+
+// Action is a moderation action that has been carried out against a user.
+type Action struct {
+	Type        string
+	GuildID     string
+	UserID      string
+	ModeratorID string
+	Reason      string
+	// Duration is how long a timeout or temporary ban lasts.
+	Duration time.Duration
+	// RoleID is the mute role of a role based timeout.
+	RoleID string
+}
+
+// MaxTimeout is the longest Discord lets a member be timed out for.
+const MaxTimeout = 28 * 24 * time.Hour
+
+// Observer is told about an action once it has succeeded.
+type Observer func(s discord.Session, a Action)
+
+// Actions takes moderation actions and tells its observers about each one.
+type Actions struct {
+	observers []Observer
+}
+
+func New(observers ...Observer) *Actions {
+	return &Actions{observers: observers}
+}
+
+func (ac *Actions) notify(s discord.Session, a Action) {
+	for _, o := range ac.observers {
+		o(s, a)
+	}
+}
+
+func (ac *Actions) KickUser(s discord.Session, guild string, user string, moderator string, reason string) error {
 	err := s.GuildMemberDeleteWithReason(guild, user, reason)
 	if err != nil {
 		return err
 	}
 
+	ac.notify(s, Action{Type: ActionKick, GuildID: guild, UserID: user, ModeratorID: moderator, Reason: reason})
+
 	return nil
 }
 
-func BanUser(s *discordgo.Session, guild string, user string, reason string) error {
-	err := s.GuildBanCreateWithReason(guild, user, reason, 0)
+// BanUser bans a user for good, deleting the last days of their messages (up to 7).
+func (ac *Actions) BanUser(s discord.Session, guild string, user string, moderator string, days int, reason string) error {
+	return ac.TempBanUser(s, guild, user, moderator, 0, days, reason)
+}
+
+// TempBanUser bans a user for a while. Observers are left to lift the ban once d has passed,
+// a d of 0 bans for good.
+func (ac *Actions) TempBanUser(s discord.Session, guild string, user string, moderator string, d time.Duration, days int, reason string) error {
+	if days < 0 || days > 7 {
+		return errors.New("Messages can only be deleted from the last 0 to 7 days.")
+	}
+
+	err := s.GuildBanCreateWithReason(guild, user, reason, days)
 	if err != nil {
 		return err
 	}
 
+	ac.notify(s, Action{Type: ActionBan, GuildID: guild, UserID: user, ModeratorID: moderator, Reason: reason, Duration: d})
+
+	return nil
+}
+
+func (ac *Actions) UnbanUser(s discord.Session, guild string, user string, moderator string, reason string) error {
+	err := s.GuildBanDelete(guild, user, discordgo.WithAuditLogReason(reason))
+	if err != nil {
+		return err
+	}
+
+	ac.notify(s, Action{Type: ActionUnban, GuildID: guild, UserID: user, ModeratorID: moderator, Reason: reason})
+
+	return nil
+}
+
+// TimeoutUser stops a member talking for a while using Discord's own timeouts.
+func (ac *Actions) TimeoutUser(s discord.Session, guild string, user string, moderator string, d time.Duration, reason string) error {
+	if d <= 0 || d > MaxTimeout {
+		return errors.New("Timeouts must last between a second and 28 days.")
+	}
+
+	until := time.Now().Add(d)
+	err := s.GuildMemberTimeout(guild, user, &until, discordgo.WithAuditLogReason(reason))
+	if err != nil {
+		return err
+	}
+
+	ac.notify(s, Action{Type: ActionTimeout, GuildID: guild, UserID: user, ModeratorID: moderator, Reason: reason, Duration: d})
+
+	return nil
+}
+
+func (ac *Actions) RemoveTimeout(s discord.Session, guild string, user string, moderator string, reason string) error {
+	err := s.GuildMemberTimeout(guild, user, nil, discordgo.WithAuditLogReason(reason))
+	if err != nil {
+		return err
+	}
+
+	ac.notify(s, Action{Type: ActionUntimeout, GuildID: guild, UserID: user, ModeratorID: moderator, Reason: reason})
+
+	return nil
+}
+
+// MuteUser times a member out by giving them a mute role. Observers are left to take the role
+// away once d has passed, a d of 0 mutes until UnmuteUser.
+func (ac *Actions) MuteUser(s discord.Session, guild string, user string, role string, moderator string, d time.Duration, reason string) error {
+	err := s.GuildMemberRoleAdd(guild, user, role, discordgo.WithAuditLogReason(reason))
+	if err != nil {
+		return err
+	}
+
+	ac.notify(s, Action{Type: ActionTimeout, GuildID: guild, UserID: user, ModeratorID: moderator, Reason: reason, Duration: d, RoleID: role})
+
+	return nil
+}
+
+func (ac *Actions) UnmuteUser(s discord.Session, guild string, user string, role string, moderator string, reason string) error {
+	err := s.GuildMemberRoleRemove(guild, user, role, discordgo.WithAuditLogReason(reason))
+	if err != nil {
+		return err
+	}
+
+	ac.notify(s, Action{Type: ActionUntimeout, GuildID: guild, UserID: user, ModeratorID: moderator, Reason: reason, RoleID: role})
+
 	return nil
 }
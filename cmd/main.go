@@ -1,15 +1,14 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"github.com/foxtrot/scuzzy/commands"
-	"github.com/foxtrot/scuzzy/models"
-	"github.com/foxtrot/scuzzy/permissions"
-	"io/ioutil"
+	"github.com/foxtrot/scuzzy/config"
+	"github.com/foxtrot/scuzzy/storage"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -20,27 +19,14 @@ import (
 var (
 	Token      string
 	ConfigPath string
-	Config     models.Configuration
+	Store      *config.Store
+	Data       *storage.Store
 )
 
-func getConfig() error {
-	cf, err := ioutil.ReadFile(ConfigPath)
-	if err != nil {
-		return err
-	}
-
-	err = json.Unmarshal(cf, &Config)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
 func main() {
 	// Parse and Check Flags
 	flag.StringVar(&Token, "t", "", "Bot Token")
-	flag.StringVar(&ConfigPath, "c", "", "Config Path")
+	flag.StringVar(&ConfigPath, "c", "", "Config Path (directory of per-guild configs, or a single config file)")
 	flag.Parse()
 
 	if len(Token) == 0 {
@@ -51,11 +37,17 @@ func main() {
 	}
 
 	// Get Config
-	err := getConfig()
+	var err error
+	Store, err = config.NewStore(ConfigPath)
+	if err != nil {
+		log.Fatal("[!] Error: " + err.Error())
+	}
+
+	// Scheduled jobs and other state live alongside the guild configs
+	Data, err = storage.New(filepath.Join(Store.Path, "data"))
 	if err != nil {
 		log.Fatal("[!] Error: " + err.Error())
 	}
-	Config.ConfigPath = ConfigPath
 
 	// Instantiate Bot
 	bot, err := discordgo.New("Bot " + Token)
@@ -71,33 +63,37 @@ func main() {
 	bot.State.TrackChannels = true
 	bot.State.TrackMembers = true
 
-	// Open Connection
-	err = bot.Open()
-	if err != nil {
-		log.Fatal("[!] Error: " + err.Error())
-	}
+	// Request Member and Message Content Intents
+	bot.Identify.Intents = discordgo.IntentsAllWithoutPrivileged | discordgo.IntentsGuildMembers | discordgo.IntentMessageContent
 
-	// Setup Auth
-	g, err := bot.Guild(Config.GuildID)
+	// Setup Handlers
+	c := commands.Commands{
+		Token: Token,
+		Store: Store,
+		Data:  Data,
+	}
+	err = c.RegisterHandlers()
 	if err != nil {
 		log.Fatal("[!] Error: " + err.Error())
 	}
-	var p *permissions.Permissions
-	p = permissions.New(&Config, g)
-
-	Config.GuildName = g.Name
 
-	// Setup Handlers
-	c := commands.Commands{
-		Token:       Token,
-		Permissions: p,
-		Config:      &Config,
+	// Load every module compiled in, guilds enable them in their config
+	for _, mod := range commands.Modules() {
+		err = c.LoadModule(mod)
+		if err != nil {
+			log.Fatal("[!] Error: " + err.Error())
+		}
 	}
-	c.RegisterHandlers()
 
-	// Add Handlers for Bot
+	// Add Handlers for Bot, each guild is set up as it becomes available
 	bot.AddHandler(c.ProcessMessage)
 
+	// Open Connection
+	err = bot.Open()
+	if err != nil {
+		log.Fatal("[!] Error: " + err.Error())
+	}
+
 	log.Printf("[*] Bot Running.\n")
 
 	// Set Bot Status
@@ -126,6 +122,9 @@ func main() {
 	signal.Notify(sc, syscall.SIGINT, syscall.SIGKILL)
 	<-sc
 
+	// Let running commands finish before disconnecting
+	c.Shutdown()
+
 	err = bot.Close()
 	if err != nil {
 		log.Fatal("[!] Error: " + err.Error())
@@ -0,0 +1,564 @@
+package commands
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/discord"
+	"github.com/foxtrot/scuzzy/models"
+)
+
+type ArgumentType int
+
+const (
+	ArgString ArgumentType = iota
+	ArgRest
+	ArgInt
+	ArgFloat
+	ArgDuration
+	ArgUser
+	ArgChannel
+	ArgRole
+)
+
+var (
+	userMentionRegex    = regexp.MustCompile(`^<@!?(\d+)>$`)
+	channelMentionRegex = regexp.MustCompile(`^<#(\d+)>$`)
+	roleMentionRegex    = regexp.MustCompile(`^<@&(\d+)>$`)
+	snowflakeRegex      = regexp.MustCompile(`^\d{15,21}$`)
+	durationRegex       = regexp.MustCompile(`(\d+)([a-z]+)`)
+)
+
+// minDuration is the shortest duration an argument accepts.
+const minDuration = time.Second
+
+// errShortDuration is returned for durations under minDuration. Unlike other parse errors it isn't
+// skipped past for optional arguments, `ban @user 0s` shouldn't become a permanent ban.
+var errShortDuration = errors.New("durations must be at least `" + formatDuration(minDuration) + "`")
+
+var durationUnits = map[string]time.Duration{
+	"s": time.Second, "sec": time.Second, "secs": time.Second,
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "wk": 7 * 24 * time.Hour, "wks": 7 * 24 * time.Hour,
+}
+
+func (t ArgumentType) String() string {
+	switch t {
+	case ArgInt:
+		return "number"
+	case ArgFloat:
+		return "decimal"
+	case ArgDuration:
+		return "duration"
+	case ArgUser:
+		return "user"
+	case ArgChannel:
+		return "channel"
+	case ArgRole:
+		return "role"
+	default:
+		return "text"
+	}
+}
+
+func (t ArgumentType) OptionType() discordgo.ApplicationCommandOptionType {
+	switch t {
+	case ArgInt:
+		return discordgo.ApplicationCommandOptionInteger
+	case ArgFloat:
+		return discordgo.ApplicationCommandOptionNumber
+	case ArgUser:
+		return discordgo.ApplicationCommandOptionUser
+	case ArgChannel:
+		return discordgo.ApplicationCommandOptionChannel
+	case ArgRole:
+		return discordgo.ApplicationCommandOptionRole
+	default:
+		return discordgo.ApplicationCommandOptionString
+	}
+}
+
+// GuildChoices returns the values an argument accepts in a guild, if it is limited to a set.
+func (arg ScuzzyArgument) GuildChoices(conf *models.Configuration) []string {
+	if arg.ChoicesFrom != nil {
+		return arg.ChoicesFrom(conf)
+	}
+
+	return arg.Choices
+}
+
+type ArgumentError struct {
+	Argument string
+	Reason   string
+	Usage    string
+}
+
+func (e *ArgumentError) Error() string {
+	msg := e.Reason
+	if len(e.Argument) > 0 {
+		msg = "Invalid `" + e.Argument + "`: " + e.Reason
+	}
+
+	return msg + "\nUsage: `" + e.Usage + "`"
+}
+
+type ScuzzyArguments struct {
+	values  map[string]interface{}
+	members map[string]*discordgo.Member
+}
+
+func (a *ScuzzyArguments) value(name string) interface{} {
+	if a == nil {
+		return nil
+	}
+
+	return a.values[name]
+}
+
+// list returns every value a variadic argument took.
+func (a *ScuzzyArguments) list(name string) []interface{} {
+	vs, _ := a.value(name).([]interface{})
+	return vs
+}
+
+func (a *ScuzzyArguments) Has(name string) bool {
+	return a.value(name) != nil
+}
+
+func (a *ScuzzyArguments) String(name string) string {
+	v, _ := a.value(name).(string)
+	return v
+}
+
+// Strings returns every value a variadic argument took.
+func (a *ScuzzyArguments) Strings(name string) []string {
+	var vs []string
+	for _, v := range a.list(name) {
+		if str, ok := v.(string); ok {
+			vs = append(vs, str)
+		}
+	}
+
+	return vs
+}
+
+func (a *ScuzzyArguments) Int(name string) int {
+	v, _ := a.value(name).(int)
+	return v
+}
+
+func (a *ScuzzyArguments) Float(name string) float64 {
+	v, _ := a.value(name).(float64)
+	return v
+}
+
+func (a *ScuzzyArguments) Duration(name string) time.Duration {
+	v, _ := a.value(name).(time.Duration)
+	return v
+}
+
+func (a *ScuzzyArguments) User(name string) *discordgo.User {
+	v, _ := a.value(name).(*discordgo.User)
+	return v
+}
+
+// Users returns every user a variadic user argument took.
+func (a *ScuzzyArguments) Users(name string) []*discordgo.User {
+	var vs []*discordgo.User
+	for _, v := range a.list(name) {
+		if user, ok := v.(*discordgo.User); ok {
+			vs = append(vs, user)
+		}
+	}
+
+	return vs
+}
+
+// Member returns the guild member for a user argument, or nil when the user is not in the guild.
+func (a *ScuzzyArguments) Member(name string) *discordgo.Member {
+	if a == nil {
+		return nil
+	}
+
+	return a.members[name]
+}
+
+func (a *ScuzzyArguments) Channel(name string) *discordgo.Channel {
+	v, _ := a.value(name).(*discordgo.Channel)
+	return v
+}
+
+func (a *ScuzzyArguments) Role(name string) *discordgo.Role {
+	v, _ := a.value(name).(*discordgo.Role)
+	return v
+}
+
+type argumentToken struct {
+	Value string
+	Start int
+}
+
+func tokenizeArguments(content string) []argumentToken {
+	var tokens []argumentToken
+
+	runes := []rune(content)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		start := i
+		if runes[i] == '"' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end < len(runes) {
+				tokens = append(tokens, argumentToken{Value: string(runes[i+1 : end]), Start: len(string(runes[:start]))})
+				i = end + 1
+				continue
+			}
+		}
+
+		for i < len(runes) && !unicode.IsSpace(runes[i]) {
+			i++
+		}
+		tokens = append(tokens, argumentToken{Value: string(runes[start:i]), Start: len(string(runes[:start]))})
+	}
+
+	return tokens
+}
+
+func parseDuration(str string) (time.Duration, error) {
+	str = strings.ToLower(strings.TrimSpace(str))
+	if len(str) == 0 {
+		return 0, errors.New("empty duration")
+	}
+
+	// Accept plain Go durations such as 1h30m first
+	if d, err := time.ParseDuration(str); err == nil {
+		return checkDuration(d)
+	}
+
+	matches := durationRegex.FindAllStringSubmatchIndex(str, -1)
+	if len(matches) == 0 {
+		return 0, errors.New("expected a duration such as `10m`, `2h` or `1d`")
+	}
+
+	var total time.Duration
+	consumed := 0
+	for _, match := range matches {
+		if match[0] != consumed {
+			return 0, errors.New("expected a duration such as `10m`, `2h` or `1d`")
+		}
+		consumed = match[1]
+
+		n, err := strconv.Atoi(str[match[2]:match[3]])
+		if err != nil {
+			return 0, err
+		}
+		unit, ok := durationUnits[str[match[4]:match[5]]]
+		if !ok {
+			return 0, errors.New("unknown duration unit `" + str[match[4]:match[5]] + "`")
+		}
+		total += time.Duration(n) * unit
+	}
+	if consumed != len(str) {
+		return 0, errors.New("expected a duration such as `10m`, `2h` or `1d`")
+	}
+
+	return checkDuration(total)
+}
+
+// checkDuration rejects durations too short to mean anything, e.g. `0s` or `-5m`.
+func checkDuration(d time.Duration) (time.Duration, error) {
+	if d < minDuration {
+		return 0, errShortDuration
+	}
+
+	return d, nil
+}
+
+// formatDuration writes a duration the way parseDuration reads it, e.g. 1d12h.
+func formatDuration(d time.Duration) string {
+	units := []struct {
+		suffix string
+		size   time.Duration
+	}{
+		{"w", 7 * 24 * time.Hour}, {"d", 24 * time.Hour}, {"h", time.Hour}, {"m", time.Minute}, {"s", time.Second},
+	}
+
+	out := ""
+	for _, u := range units {
+		if n := d / u.size; n > 0 {
+			out += strconv.Itoa(int(n)) + u.suffix
+			d -= n * u.size
+		}
+	}
+	if len(out) == 0 {
+		return "0s"
+	}
+
+	return out
+}
+
+func mentionID(raw string, mention *regexp.Regexp) (string, bool) {
+	if match := mention.FindStringSubmatch(raw); match != nil {
+		return match[1], true
+	}
+	if snowflakeRegex.MatchString(raw) {
+		return raw, true
+	}
+
+	return "", false
+}
+
+// cachedUser resolves a user mention or ID from the state alone. Users it hasn't seen have only their ID.
+func cachedUser(s discord.Session, guildID string, raw string) (*discordgo.User, *discordgo.Member, error) {
+	id, ok := mentionID(raw, userMentionRegex)
+	if !ok {
+		return nil, nil, errors.New("expected a user mention or ID")
+	}
+
+	member, err := s.GetState().Member(guildID, id)
+	if err == nil && member.User != nil {
+		return member.User, member, nil
+	}
+
+	return &discordgo.User{ID: id}, nil, nil
+}
+
+func (c *Commands) resolveUser(s discord.Session, guildID string, raw string) (*discordgo.User, *discordgo.Member, error) {
+	id, ok := mentionID(raw, userMentionRegex)
+	if !ok {
+		return nil, nil, errors.New("expected a user mention or ID")
+	}
+
+	member, err := s.GetState().Member(guildID, id)
+	if err != nil {
+		member, err = s.GuildMember(guildID, id)
+	}
+	if err == nil && member.User != nil {
+		return member.User, member, nil
+	}
+
+	// The user may not be in the guild (e.g. banning)
+	user, err := s.User(id)
+	if err != nil {
+		return nil, nil, errors.New("could not find that user")
+	}
+
+	return user, nil, nil
+}
+
+func (c *Commands) resolveChannel(s discord.Session, raw string) (*discordgo.Channel, error) {
+	id, ok := mentionID(raw, channelMentionRegex)
+	if !ok {
+		return nil, errors.New("expected a channel mention or ID")
+	}
+
+	channel, err := s.GetState().Channel(id)
+	if err != nil {
+		channel, err = s.Channel(id)
+	}
+	if err != nil {
+		return nil, errors.New("could not find that channel")
+	}
+
+	return channel, nil
+}
+
+func (c *Commands) resolveRole(s discord.Session, guildID string, raw string) (*discordgo.Role, error) {
+	id, _ := mentionID(raw, roleMentionRegex)
+
+	roles, err := s.GuildRoles(guildID)
+	if err != nil {
+		return nil, err
+	}
+	for _, role := range roles {
+		if (len(id) > 0 && role.ID == id) || strings.EqualFold(role.Name, raw) {
+			return role, nil
+		}
+	}
+
+	return nil, errors.New("could not find that role")
+}
+
+func (c *Commands) parseArgument(s discord.Session, m *ScuzzyContext, arg ScuzzyArgument, raw string) (interface{}, *discordgo.Member, error) {
+	choices := arg.GuildChoices(m.Config)
+	if len(choices) > 0 {
+		valid := false
+		for _, choice := range choices {
+			if strings.EqualFold(choice, raw) {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, nil, errors.New("expected one of `" + strings.Join(choices, "`, `") + "`")
+		}
+	}
+
+	switch arg.Type {
+	case ArgInt:
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, nil, errors.New("expected a whole number")
+		}
+		return v, nil, nil
+	case ArgFloat:
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, nil, errors.New("expected a number")
+		}
+		return v, nil, nil
+	case ArgDuration:
+		v, err := parseDuration(raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		return v, nil, nil
+	case ArgUser:
+		// Lists can run to hundreds of IDs, far too many to look up a request at a time
+		if arg.Variadic {
+			user, member, err := cachedUser(s, m.GuildID, raw)
+			if err != nil {
+				return nil, nil, err
+			}
+			return user, member, nil
+		}
+
+		user, member, err := c.resolveUser(s, m.GuildID, raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		return user, member, nil
+	case ArgChannel:
+		channel, err := c.resolveChannel(s, raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		return channel, nil, nil
+	case ArgRole:
+		role, err := c.resolveRole(s, m.GuildID, raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		return role, nil, nil
+	default:
+		return raw, nil, nil
+	}
+}
+
+func (c *Commands) CommandUsage(conf *models.Configuration, cmd *ScuzzyCommand) string {
+	usage := commandKey(conf) + cmd.Path
+	for _, arg := range cmd.Arguments {
+		name := arg.Name
+		if arg.Variadic || arg.Type == ArgRest {
+			name += "..."
+		}
+
+		if arg.Required {
+			usage += " <" + name + ">"
+		} else {
+			usage += " [" + name + "]"
+		}
+	}
+
+	return usage
+}
+
+func (c *Commands) ParseArguments(s discord.Session, m *ScuzzyContext) (*ScuzzyArguments, error) {
+	args := &ScuzzyArguments{
+		values:  make(map[string]interface{}),
+		members: make(map[string]*discordgo.Member),
+	}
+	usage := c.CommandUsage(m.Config, m.Command)
+
+	// Strip the command name and any subcommands, everything after them is arguments
+	content := m.Invocation
+	for range strings.Fields(m.Command.Path) {
+		content = strings.TrimLeftFunc(content, unicode.IsSpace)
+		if idx := strings.IndexFunc(content, unicode.IsSpace); idx >= 0 {
+			content = content[idx:]
+		} else {
+			content = ""
+		}
+	}
+	tokens := tokenizeArguments(content)
+
+	// Groups take no arguments of their own, leave unknown subcommands to the group's handler
+	if len(m.Command.Subcommands) > 0 && len(m.Command.Arguments) == 0 {
+		return args, nil
+	}
+
+	pos := 0
+	for k, arg := range m.Command.Arguments {
+		hasLater := k < len(m.Command.Arguments)-1
+
+		if arg.Type == ArgRest {
+			if pos < len(tokens) {
+				args.values[arg.Name] = strings.TrimSpace(content[tokens[pos].Start:])
+				pos = len(tokens)
+			} else if arg.Required {
+				return nil, &ArgumentError{Reason: "Missing argument `" + arg.Name + "`.", Usage: usage}
+			}
+			continue
+		}
+
+		if pos >= len(tokens) {
+			if arg.Required {
+				return nil, &ArgumentError{Reason: "Missing argument `" + arg.Name + "`.", Usage: usage}
+			}
+			continue
+		}
+
+		if arg.Variadic {
+			var vs []interface{}
+			for ; pos < len(tokens); pos++ {
+				v, _, err := c.parseArgument(s, m, arg, tokens[pos].Value)
+				if err == nil {
+					vs = append(vs, v)
+					continue
+				}
+
+				// The values end at the first that doesn't parse, what follows is for a rest argument
+				if err != errShortDuration && (len(vs) > 0 || (!arg.Required && hasLater)) {
+					break
+				}
+				return nil, &ArgumentError{Argument: arg.Name, Reason: err.Error(), Usage: usage}
+			}
+			if len(vs) > 0 {
+				args.values[arg.Name] = vs
+			}
+			continue
+		}
+
+		v, member, err := c.parseArgument(s, m, arg, tokens[pos].Value)
+		if err != nil {
+			// Optional arguments may be skipped if a later argument can take the value
+			if !arg.Required && hasLater && err != errShortDuration {
+				continue
+			}
+			return nil, &ArgumentError{Argument: arg.Name, Reason: err.Error(), Usage: usage}
+		}
+
+		args.values[arg.Name] = v
+		args.members[arg.Name] = member
+		pos++
+	}
+
+	if pos < len(tokens) {
+		return nil, &ArgumentError{Reason: "Too many arguments.", Usage: usage}
+	}
+
+	return args, nil
+}
@@ -0,0 +1,263 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/models"
+)
+
+func TestTokenizeArguments(t *testing.T) {
+	tokens := tokenizeArguments(`  ban "two words"  héllo "unclosed`)
+
+	want := []argumentToken{
+		{Value: "ban", Start: 2},
+		{Value: "two words", Start: 6},
+		{Value: "héllo", Start: 19},
+		{Value: `"unclosed`, Start: 26},
+	}
+	if len(tokens) != len(want) {
+		t.Fatalf("got %v, want %v", tokens, want)
+	}
+	for k := range want {
+		if tokens[k] != want[k] {
+			t.Errorf("token %d = %+v, want %+v", k, tokens[k], want[k])
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"10m":    10 * time.Minute,
+		"1h30m":  90 * time.Minute,
+		"2d":     48 * time.Hour,
+		"1w2d":   9 * 24 * time.Hour,
+		"3 DAYS": 0,
+		"1s":     time.Second,
+		"90sec":  90 * time.Second,
+	}
+	for in, want := range tests {
+		got, err := parseDuration(in)
+		if want == 0 {
+			if err == nil {
+				t.Errorf("parseDuration(%q) = %v, want an error", in, got)
+			}
+			continue
+		}
+		if err != nil || got != want {
+			t.Errorf("parseDuration(%q) = %v, %v, want %v", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"", "soon", "5x", "1h-", "10mfoo"} {
+		if _, err := parseDuration(in); err == nil || err == errShortDuration {
+			t.Errorf("parseDuration(%q) = %v, want a parse error", in, err)
+		}
+	}
+	for _, in := range []string{"0s", "0d", "-5m", "1ns", "999ms"} {
+		if _, err := parseDuration(in); err != errShortDuration {
+			t.Errorf("parseDuration(%q) = %v, want errShortDuration", in, err)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                           "0s",
+		time.Second:                 "1s",
+		90 * time.Minute:            "1h30m",
+		36 * time.Hour:              "1d12h",
+		15*24*time.Hour + time.Hour: "2w1d1h",
+	}
+	for in, want := range tests {
+		if got := formatDuration(in); got != want {
+			t.Errorf("formatDuration(%v) = %q, want %q", in, got, want)
+		}
+
+		// What formatDuration writes parseDuration reads back
+		if in > 0 {
+			if back, err := parseDuration(want); err != nil || back != in {
+				t.Errorf("parseDuration(%q) = %v, %v, want %v", want, back, err, in)
+			}
+		}
+	}
+}
+
+// parseArgs parses content for a command taking args.
+func parseArgs(c *Commands, path string, args []ScuzzyArgument, content string) (*ScuzzyArguments, error) {
+	cmd := &ScuzzyCommand{Name: path, Path: path, Arguments: args}
+	m := &ScuzzyContext{
+		Guild:      &Guild{Config: &models.Configuration{CommandKey: "."}},
+		Command:    cmd,
+		Invocation: path + " " + content,
+	}
+
+	return c.ParseArguments(nil, m)
+}
+
+func TestParseArguments(t *testing.T) {
+	c := &Commands{}
+	args := []ScuzzyArgument{
+		{Name: "count", Type: ArgInt, Required: true},
+		{Name: "duration", Type: ArgDuration},
+		{Name: "days", Type: ArgInt},
+		{Name: "reason", Type: ArgRest},
+	}
+
+	a, err := parseArgs(c, "test", args, `5 2h 1 was "very" rude`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Int("count") != 5 || a.Duration("duration") != 2*time.Hour || a.Int("days") != 1 || a.String("reason") != `was "very" rude` {
+		t.Errorf("got count %d, duration %v, days %d, reason %q", a.Int("count"), a.Duration("duration"), a.Int("days"), a.String("reason"))
+	}
+
+	// Optional arguments that can't take a value are skipped
+	a, err = parseArgs(c, "test", args, "5 3 Spamming")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Has("duration") || a.Int("days") != 3 || a.String("reason") != "Spamming" {
+		t.Errorf("got duration %v, days %d, reason %q", a.Duration("duration"), a.Int("days"), a.String("reason"))
+	}
+
+	a, err = parseArgs(c, "test", args, "5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Has("duration") || a.Has("days") || a.Has("reason") {
+		t.Error("missing optional arguments should be left unset")
+	}
+
+	// Durations too short to mean anything aren't skipped, `ban @user 0s` isn't a permanent ban
+	_, err = parseArgs(c, "test", args, "5 0s Spamming")
+	if err == nil || !strings.Contains(err.Error(), "Invalid `duration`") {
+		t.Errorf("err = %v, want an invalid duration", err)
+	}
+
+	_, err = parseArgs(c, "test", args, "")
+	if err == nil || !strings.Contains(err.Error(), "Missing argument `count`") {
+		t.Errorf("err = %v, want a missing argument", err)
+	}
+	_, err = parseArgs(c, "test", args, "five")
+	if err == nil || !strings.Contains(err.Error(), "Invalid `count`") {
+		t.Errorf("err = %v, want an invalid count", err)
+	}
+	if aErr, ok := err.(*ArgumentError); !ok || aErr.Usage != ".test <count> [duration] [days] [reason...]" {
+		t.Errorf("err = %#v, want the usage", err)
+	}
+
+	_, err = parseArgs(c, "test", args[:2], "5 2h extra")
+	if err == nil || !strings.Contains(err.Error(), "Too many arguments.") {
+		t.Errorf("err = %v, want too many arguments", err)
+	}
+
+	// Variadic arguments take values until one doesn't parse, leaving the rest for a rest argument
+	variadic := []ScuzzyArgument{
+		{Name: "modes", Required: true, Variadic: true, Choices: []string{"on", "off"}},
+		{Name: "reason", Type: ArgRest},
+	}
+	a, err = parseArgs(c, "test", variadic, "on off on because")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modes := a.Strings("modes"); len(modes) != 3 || modes[1] != "off" || a.String("reason") != "because" {
+		t.Errorf("got modes %v, reason %q", modes, a.String("reason"))
+	}
+	if _, err = parseArgs(c, "test", variadic, "maybe"); err == nil {
+		t.Error("a required variadic argument needs at least one value")
+	}
+	if _, err = parseArgs(c, "test", variadic[:1], "on maybe"); err == nil || !strings.Contains(err.Error(), "Too many arguments.") {
+		t.Errorf("err = %v, want too many arguments", err)
+	}
+
+	choices := []ScuzzyArgument{{Name: "mode", Required: true, Choices: []string{"on", "off"}}}
+	if _, err = parseArgs(c, "test", choices, "maybe"); err == nil {
+		t.Error("values outside the choices should be rejected")
+	}
+	if a, err = parseArgs(c, "test", choices, "on"); err != nil || a.String("mode") != "on" {
+		t.Errorf("got %q, %v", a.String("mode"), err)
+	}
+}
+
+func TestParseUserArguments(t *testing.T) {
+	b := newTestBot(t, nil)
+	args := []ScuzzyArgument{{Name: "user", Type: ArgUser, Required: true}}
+
+	cmd := &ScuzzyCommand{Name: "test", Path: "test", Arguments: args}
+	guild, _ := b.Guild(testGuildID)
+	m := &ScuzzyContext{
+		MessageCreate: &discordgo.MessageCreate{Message: &discordgo.Message{GuildID: testGuildID}},
+		Guild:         guild,
+		Command:       cmd,
+		Invocation:    "test <@!" + testUserID + ">",
+	}
+
+	a, err := b.ParseArguments(b.s, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.User("user").ID != testUserID || a.Member("user") == nil {
+		t.Errorf("got user %v, member %v", a.User("user"), a.Member("user"))
+	}
+
+	m.Command = &ScuzzyCommand{Name: "test", Path: "test", Arguments: []ScuzzyArgument{
+		{Name: "users", Type: ArgUser, Variadic: true},
+		{Name: "reason", Type: ArgRest},
+	}}
+	m.Invocation = "test " + testUserID + " <@" + testAdminID + "> Raid accounts"
+	a, err = b.ParseArguments(b.s, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if users := a.Users("users"); len(users) != 2 || users[1].ID != testAdminID || a.String("reason") != "Raid accounts" {
+		t.Errorf("got users %v, reason %q", users, a.String("reason"))
+	}
+
+	m.Command = cmd
+	m.Invocation = "test someone"
+	if _, err = b.ParseArguments(b.s, m); err == nil {
+		t.Error("text that isn't a mention or ID should be rejected")
+	}
+}
+
+func TestPrepareCommandVariadic(t *testing.T) {
+	c := &Commands{}
+
+	// A trailing rest argument takes what the variadic argument leaves
+	err := c.prepareCommand(&ScuzzyCommand{
+		Name:      "test",
+		Arguments: []ScuzzyArgument{{Name: "users", Type: ArgUser, Variadic: true}, {Name: "reason", Type: ArgRest}},
+	}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = c.prepareCommand(&ScuzzyCommand{
+		Name:      "test",
+		Arguments: []ScuzzyArgument{{Name: "users", Type: ArgUser, Variadic: true}, {Name: "days", Type: ArgInt}},
+	}, nil)
+	if err == nil {
+		t.Error("a variadic argument followed by another argument should be rejected")
+	}
+}
+
+func TestPrepareCommandOrder(t *testing.T) {
+	c := &Commands{}
+
+	err := c.prepareCommand(&ScuzzyCommand{
+		Name: "test",
+		Subcommands: []ScuzzyCommand{{
+			Name: "sub",
+			Arguments: []ScuzzyArgument{
+				{Name: "first"},
+				{Name: "second", Required: true},
+			},
+		}},
+	}, nil)
+	if err == nil {
+		t.Error("a required argument after an optional one should be rejected")
+	}
+}
@@ -0,0 +1,179 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/actions"
+	"github.com/foxtrot/scuzzy/discord"
+)
+
+// unbanTask is the data of the scheduled end of a temporary ban.
+type unbanTask struct {
+	UserID string `json:"user_id"`
+	Reason string `json:"reason"`
+}
+
+func unbanTaskUser(task ScheduledTask) string {
+	var ut unbanTask
+	json.Unmarshal(task.Data, &ut)
+
+	return ut.UserID
+}
+
+func isUnknownBan(err error) bool {
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Message != nil {
+		return restErr.Message.Code == discordgo.ErrCodeUnknownBan
+	}
+
+	return false
+}
+
+// trackBans schedules the end of temporary bans. It observes the actions package, so bans from
+// escalations are handled the same as those from commands.
+func (c *Commands) trackBans(s discord.Session, a actions.Action) {
+	if a.Type != actions.ActionBan && a.Type != actions.ActionUnban {
+		return
+	}
+
+	// A new ban or an unban replaces whatever was pending for the user
+	_, err := c.CancelTasks(a.GuildID, "unban", func(task ScheduledTask) bool {
+		return unbanTaskUser(task) == a.UserID
+	})
+	if err != nil {
+		c.logGuildError(s, a.GuildID, "Error (Bans)", err)
+		return
+	}
+
+	if a.Type != actions.ActionBan || a.Duration <= 0 {
+		return
+	}
+
+	_, err = c.ScheduleTask(s, a.GuildID, "unban", time.Now().Add(a.Duration), unbanTask{UserID: a.UserID, Reason: a.Reason})
+	if err != nil {
+		c.logGuildError(s, a.GuildID, "Error (Bans)", err)
+	}
+}
+
+func (c *Commands) runUnbanTask(s discord.Session, guildID string, task ScheduledTask) error {
+	var ut unbanTask
+	err := json.Unmarshal(task.Data, &ut)
+	if err != nil {
+		return err
+	}
+
+	err = c.Actions.UnbanUser(s, guildID, ut.UserID, s.GetState().User.ID, "Temporary ban expired")
+	// Already lifted by hand
+	if err != nil && !isUnknownBan(err) {
+		return err
+	}
+
+	return nil
+}
+
+// banExpiries maps each temporarily banned user to when their ban ends.
+func (c *Commands) banExpiries(guildID string) map[string]time.Time {
+	expiries := make(map[string]time.Time)
+	for _, task := range c.Tasks(guildID, "unban") {
+		expiries[unbanTaskUser(task)] = task.At
+	}
+
+	return expiries
+}
+
+func (c *Commands) handleUnbanUser(s discord.Session, m *ScuzzyContext) error {
+	user := m.Args.User("user")
+	reason := moderationReason(m)
+
+	err := c.Actions.UnbanUser(s, m.GuildID, user.ID, m.Author.ID, reason)
+	if isUnknownBan(err) {
+		return errors.New("<@" + user.ID + "> isn't banned.")
+	}
+	if err != nil {
+		return err
+	}
+
+	msg := "User `" + user.Username + "#" + user.Discriminator + "` was unbanned.\n"
+	if len(reason) > 0 {
+		msg += "Reason: `" + reason + "`\n"
+	}
+
+	embed := c.CreateDefinedEmbed("Unban User", msg, "success", m.Author)
+	_, err = c.SendCannedEmbed(s, m, "unban", map[string]string{"target": "<@" + user.ID + ">", "reason": reason}, embed)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Commands) handleListBans(s discord.Session, m *ScuzzyContext) error {
+	query := strings.ToLower(m.Args.String("query"))
+	expiries := c.banExpiries(m.GuildID)
+
+	var (
+		matches []*discordgo.GuildBan
+		after   string
+	)
+	for {
+		bans, err := s.GuildBans(m.GuildID, 1000, "", after, m.requestOptions()...)
+		if err != nil {
+			return err
+		}
+
+		for _, ban := range bans {
+			if len(query) == 0 || ban.User.ID == query ||
+				strings.Contains(strings.ToLower(ban.User.Username), query) ||
+				strings.Contains(strings.ToLower(ban.Reason), query) {
+				matches = append(matches, ban)
+			}
+		}
+
+		if len(bans) < 1000 {
+			break
+		}
+		after = bans[len(bans)-1].User.ID
+	}
+
+	if len(matches) == 0 {
+		msg := "There are no bans."
+		if len(query) > 0 {
+			msg = "No bans match `" + query + "`."
+		}
+		eMsg := c.CreateDefinedEmbed("Bans", msg, "", m.Author)
+		_, err := c.SendEmbed(s, m, eMsg)
+		return err
+	}
+
+	const maxListed = 20
+
+	msg := ""
+	for k, ban := range matches {
+		if k == maxListed {
+			msg += "...and `" + strconv.Itoa(len(matches)-maxListed) + "` more, search to narrow them down.\n"
+			break
+		}
+
+		msg += "`" + ban.User.ID + "` " + ban.User.Username
+		if at, ok := expiries[ban.User.ID]; ok {
+			msg += ", ends <t:" + strconv.FormatInt(at.Unix(), 10) + ":R>"
+		}
+		if len(ban.Reason) > 0 {
+			msg += "\n> " + ban.Reason
+		}
+		msg += "\n"
+	}
+
+	eMsg := c.CreateDefinedEmbed("Bans ("+strconv.Itoa(len(matches))+")", msg, "", m.Author)
+	_, err := c.SendEmbed(s, m, eMsg)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
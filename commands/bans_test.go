@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/foxtrot/scuzzy/actions"
+)
+
+func TestTemporaryBan(t *testing.T) {
+	b := newTestBot(t, nil)
+
+	b.run(".ban <@" + testUserID + "> 7d 1 Spamming")
+
+	ban := b.s.Bans[testGuildID][testUserID]
+	if ban == nil || ban.Reason != "Spamming" {
+		t.Fatalf("expected a ban, got %+v", ban)
+	}
+	if calls := b.s.CallsTo("GuildBanCreateWithReason"); len(calls) != 1 || calls[0].Args[3] != 1 {
+		t.Errorf("expected a day of messages deleted, got %v", calls)
+	}
+
+	tasks := b.Tasks(testGuildID, "unban")
+	if len(tasks) != 1 || unbanTaskUser(tasks[0]) != testUserID {
+		t.Fatalf("expected an unban to be scheduled, got %+v", tasks)
+	}
+	if d := time.Until(tasks[0].At); d < 7*24*time.Hour-time.Minute || d > 7*24*time.Hour {
+		t.Errorf("unban scheduled in %v, want 7d", d)
+	}
+
+	err := b.runUnbanTask(b.s, testGuildID, tasks[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Wait()
+	if _, ok := b.s.Bans[testGuildID][testUserID]; ok {
+		t.Error("the ban should have been lifted")
+	}
+	if len(b.Tasks(testGuildID, "unban")) != 0 {
+		t.Error("lifting the ban should cancel its task")
+	}
+
+	cases, _ := b.UserCases(testGuildID, testUserID)
+	if len(cases) != 2 || cases[0].Action != actions.ActionBan || cases[0].Duration != 7*24*time.Hour || cases[1].Action != actions.ActionUnban {
+		t.Errorf("expected a ban and an unban case, got %+v", cases)
+	}
+
+	// Bans lifted by hand in the meantime aren't an error
+	if err := b.runUnbanTask(b.s, testGuildID, tasks[0]); err != nil {
+		t.Errorf("lifting a lifted ban: %v", err)
+	}
+}
+
+func TestPermanentBan(t *testing.T) {
+	b := newTestBot(t, nil)
+
+	b.run(".ban <@" + testUserID + "> 7d Spamming")
+	// The fake only looks up users who are members
+	b.addMember(testUserID, "bob")
+	b.run(".ban <@" + testUserID + "> Spamming again")
+
+	if _, ok := b.s.Bans[testGuildID][testUserID]; !ok {
+		t.Fatal("expected a ban")
+	}
+	if tasks := b.Tasks(testGuildID, "unban"); len(tasks) != 0 {
+		t.Errorf("a permanent ban should replace the temporary one, got %+v", tasks)
+	}
+
+	// `0s` isn't taken as a permanent ban
+	b.s.Bans[testGuildID] = nil
+	b.addMember(testUserID, "bob")
+	b.s.Calls = nil
+	b.run(".ban <@" + testUserID + "> 0s Spamming")
+	if len(b.s.CallsTo("GuildBanCreateWithReason")) != 0 {
+		t.Error("a 0s ban should be refused")
+	}
+}
@@ -1,21 +1,14 @@
 package commands
 
 import (
-	"errors"
-	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/discord"
 	"log"
 	"os"
-	"strings"
 	"time"
 )
 
-func (c *Commands) handleSetStatus(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	stSplit := strings.SplitN(m.Content, " ", 2)
-	if len(stSplit) < 2 {
-		return errors.New("You did not specify a status.")
-	}
-
-	st := stSplit[1]
+func (c *Commands) handleSetStatus(s discord.Session, m *ScuzzyContext) error {
+	st := m.Args.String("status")
 
 	err := s.UpdateGameStatus(0, st)
 	if err != nil {
@@ -23,7 +16,7 @@ func (c *Commands) handleSetStatus(s *discordgo.Session, m *discordgo.MessageCre
 	}
 
 	msg := c.CreateDefinedEmbed("Set Status", "Operation completed successfully.", "success", m.Author)
-	_, err = s.ChannelMessageSendEmbed(m.ChannelID, msg)
+	_, err = c.SendEmbed(s, m, msg)
 	if err != nil {
 		return err
 	}
@@ -31,9 +24,9 @@ func (c *Commands) handleSetStatus(s *discordgo.Session, m *discordgo.MessageCre
 	return nil
 }
 
-func (c *Commands) handleDisconnect(s *discordgo.Session, m *discordgo.MessageCreate) error {
+func (c *Commands) handleDisconnect(s discord.Session, m *ScuzzyContext) error {
 	msg := c.CreateDefinedEmbed("Disconnect", "Attempting Disconnect...", "", m.Author)
-	_, err := s.ChannelMessageSendEmbed(m.ChannelID, msg)
+	_, err := c.SendEmbed(s, m, msg)
 	if err != nil {
 		return err
 	}
@@ -48,7 +41,7 @@ func (c *Commands) handleDisconnect(s *discordgo.Session, m *discordgo.MessageCr
 	return nil
 }
 
-func (c *Commands) handleReconnect(s *discordgo.Session, m *discordgo.MessageCreate) error {
+func (c *Commands) handleReconnect(s discord.Session, m *ScuzzyContext) error {
 	t := time.Now()
 
 	err := s.Close()
@@ -62,7 +55,7 @@ func (c *Commands) handleReconnect(s *discordgo.Session, m *discordgo.MessageCre
 	}
 
 	msg := c.CreateDefinedEmbed("Reconnect", "Reconnected Successfully.\nTime: `"+time.Since(t).String()+"`.", "success", m.Author)
-	_, err = s.ChannelMessageSendEmbed(m.ChannelID, msg)
+	_, err = c.SendEmbed(s, m, msg)
 	if err != nil {
 		return err
 	}
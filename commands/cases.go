@@ -0,0 +1,434 @@
+package commands
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/actions"
+	"github.com/foxtrot/scuzzy/discord"
+	"github.com/foxtrot/scuzzy/models"
+)
+
+// casesDir is the storage directory a guild's moderation cases are kept in, one file per case
+// so opening or updating a case only writes that case.
+const casesDir = "cases"
+
+// casePageLength keeps each page of a case list well inside Discord's 4096 character embed limit.
+const casePageLength = 1800
+
+// caseButtonPrefix starts the custom ID of a case list's page buttons, "cases:<page>:<user ID>:<target ID>".
+const caseButtonPrefix = "cases:"
+
+var messageLinkRegex = regexp.MustCompile(`https://(?:(?:ptb|canary)\.)?discord(?:app)?\.com/channels/\d+/\d+/\d+`)
+
+func caseName(id int) string {
+	return casesDir + "/" + strconv.Itoa(id)
+}
+
+// guildCases returns a guild's cases, oldest first, loading them from storage the first time.
+// The caller holds modMu.
+func (c *Commands) guildCases(guildID string) ([]models.Case, error) {
+	if cases, ok := c.cases[guildID]; ok {
+		return cases, nil
+	}
+
+	names, err := c.Data.Names(guildID, casesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	cases := []models.Case{}
+	for _, name := range names {
+		var mc models.Case
+		err = c.Data.Load(guildID, name, &mc)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, mc)
+	}
+	sort.Slice(cases, func(i, j int) bool {
+		return cases[i].ID < cases[j].ID
+	})
+	c.cases[guildID] = cases
+
+	return cases, nil
+}
+
+// UserCases returns every case against a user, oldest first.
+func (c *Commands) UserCases(guildID string, userID string) ([]models.Case, error) {
+	c.modMu.Lock()
+	defer c.modMu.Unlock()
+
+	all, err := c.guildCases(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	var cases []models.Case
+	for _, mc := range all {
+		if mc.UserID == userID {
+			cases = append(cases, mc)
+		}
+	}
+
+	return cases, nil
+}
+
+// FindCase looks up a case by number.
+func (c *Commands) FindCase(guildID string, id int) (models.Case, error) {
+	c.modMu.Lock()
+	defer c.modMu.Unlock()
+
+	cases, err := c.guildCases(guildID)
+	if err != nil {
+		return models.Case{}, err
+	}
+
+	for _, mc := range cases {
+		if mc.ID == id {
+			return mc, nil
+		}
+	}
+
+	return models.Case{}, errors.New("There is no case `#" + strconv.Itoa(id) + "`.")
+}
+
+// addCase numbers and stores a new case.
+func (c *Commands) addCase(guildID string, mc models.Case) (models.Case, error) {
+	c.modMu.Lock()
+	defer c.modMu.Unlock()
+
+	cases, err := c.guildCases(guildID)
+	if err != nil {
+		return mc, err
+	}
+
+	// Cases are never removed, so the newest has the highest number
+	mc.ID = 1
+	if len(cases) > 0 {
+		mc.ID = cases[len(cases)-1].ID + 1
+	}
+
+	err = c.Data.Save(guildID, caseName(mc.ID), mc)
+	if err != nil {
+		return mc, err
+	}
+	c.cases[guildID] = append(cases, mc)
+
+	return mc, nil
+}
+
+// updateCase changes a stored case, returning it as updated.
+func (c *Commands) updateCase(guildID string, id int, update func(mc *models.Case)) (models.Case, error) {
+	c.modMu.Lock()
+	defer c.modMu.Unlock()
+
+	cases, err := c.guildCases(guildID)
+	if err != nil {
+		return models.Case{}, err
+	}
+
+	for k := range cases {
+		if cases[k].ID != id {
+			continue
+		}
+
+		mc := cases[k]
+		update(&mc)
+		err = c.Data.Save(guildID, caseName(id), mc)
+		if err != nil {
+			return models.Case{}, err
+		}
+		cases[k] = mc
+
+		return mc, nil
+	}
+
+	return models.Case{}, errors.New("There is no case `#" + strconv.Itoa(id) + "`.")
+}
+
+// splitEvidence pulls message links out of a reason to keep as evidence.
+func splitEvidence(reason string) (string, []string) {
+	evidence := messageLinkRegex.FindAllString(reason, -1)
+	reason = strings.Join(strings.Fields(messageLinkRegex.ReplaceAllString(reason, "")), " ")
+
+	return reason, evidence
+}
+
+// moderationReason is the reason given to a moderation command. Replying to a message with the
+// command adds a link to it, which ends up as evidence on the case.
+func moderationReason(m *ScuzzyContext) string {
+	reason := m.Args.String("reason")
+	if m.MessageCreate == nil || m.Message == nil || m.Message.MessageReference == nil {
+		return reason
+	}
+
+	ref := m.Message.MessageReference
+	link := "https://discord.com/channels/" + m.GuildID + "/" + ref.ChannelID + "/" + ref.MessageID
+
+	return strings.TrimSpace(reason + " " + link)
+}
+
+var caseTitles = map[string]string{
+	actions.ActionWarn:      "Warning",
+	actions.ActionTimeout:   "Timeout",
+	actions.ActionUntimeout: "Timeout Removed",
+	actions.ActionKick:      "Kick",
+	actions.ActionBan:       "Ban",
+	actions.ActionUnban:     "Unban",
+}
+
+func (c *Commands) caseEmbed(mc models.Case) *discordgo.MessageEmbed {
+	title, ok := caseTitles[mc.Action]
+	if !ok {
+		title = mc.Action
+	}
+
+	msg := "**User**: <@" + mc.UserID + "> (`" + mc.UserID + "`)\n"
+	msg += "**Moderator**: <@" + mc.ModeratorID + ">\n"
+	if mc.Duration > 0 {
+		msg += "**Duration**: `" + formatDuration(mc.Duration) + "`\n"
+	}
+	if len(mc.Reason) > 0 {
+		msg += "**Reason**: " + mc.Reason + "\n"
+	} else {
+		msg += "**Reason**: None given, use `reason " + strconv.Itoa(mc.ID) + " <text>` to add one\n"
+	}
+	if len(mc.Evidence) > 0 {
+		msg += "**Evidence**:\n" + strings.Join(mc.Evidence, "\n") + "\n"
+	}
+	if mc.UpdatedAt != nil {
+		msg += "**Updated By**: <@" + mc.UpdatedBy + "> <t:" + strconv.FormatInt(mc.UpdatedAt.Unix(), 10) + ":R>\n"
+	}
+
+	status := ""
+	if mc.Action == actions.ActionBan || mc.Action == actions.ActionKick {
+		status = "error"
+	}
+
+	embed := c.CreateDefinedEmbed("Case #"+strconv.Itoa(mc.ID)+" | "+title, msg, status, nil)
+	embed.Timestamp = mc.CreatedAt.Format(time.RFC3339)
+
+	return embed
+}
+
+// modLogChannel returns where a guild's cases are posted.
+func (c *Commands) modLogChannel(guildID string) string {
+	guild, ok := c.Guild(guildID)
+	if !ok {
+		return ""
+	}
+	if len(guild.Config.ModLogChannel) > 0 {
+		return guild.Config.ModLogChannel
+	}
+
+	return guild.Config.LoggingChannel
+}
+
+// postCase posts a new case to the moderation log, remembering the post so it can be edited.
+func (c *Commands) postCase(s discord.Session, guildID string, mc models.Case) error {
+	channelID := c.modLogChannel(guildID)
+	if len(channelID) == 0 {
+		return nil
+	}
+
+	msg, err := s.ChannelMessageSendEmbed(channelID, c.caseEmbed(mc))
+	if err != nil {
+		return err
+	}
+
+	_, err = c.updateCase(guildID, mc.ID, func(mc *models.Case) {
+		mc.LogChannelID = channelID
+		mc.LogMessageID = msg.ID
+	})
+
+	return err
+}
+
+// recordAction opens a case for an action and applies the guild's escalation policy. It observes
+// c.Actions, so everything done through it is logged and counted.
+func (c *Commands) recordAction(s discord.Session, a actions.Action) {
+	reason, evidence := splitEvidence(a.Reason)
+
+	mc, err := c.addCase(a.GuildID, models.Case{
+		Action:      a.Type,
+		UserID:      a.UserID,
+		ModeratorID: a.ModeratorID,
+		Reason:      reason,
+		Duration:    a.Duration,
+		Evidence:    evidence,
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		c.logGuildError(s, a.GuildID, "Error (Cases)", err)
+		return
+	}
+
+	err = c.postCase(s, a.GuildID, mc)
+	if err != nil {
+		c.logGuildError(s, a.GuildID, "Error (Cases)", err)
+	}
+
+	err = c.escalate(s, a)
+	if err != nil {
+		c.logGuildError(s, a.GuildID, "Error (Escalation)", err)
+	}
+}
+
+func (c *Commands) handleCase(s discord.Session, m *ScuzzyContext) error {
+	mc, err := c.FindCase(m.GuildID, m.Args.Int("id"))
+	if err != nil {
+		return err
+	}
+
+	_, err = c.SendEmbed(s, m, c.caseEmbed(mc))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Commands) handleCaseReason(s discord.Session, m *ScuzzyContext) error {
+	id := m.Args.Int("id")
+	reason, evidence := splitEvidence(m.Args.String("reason"))
+
+	now := time.Now()
+	mc, err := c.updateCase(m.GuildID, id, func(mc *models.Case) {
+		if len(reason) > 0 {
+			mc.Reason = reason
+		}
+		mc.Evidence = append(mc.Evidence, evidence...)
+		mc.UpdatedBy = m.Author.ID
+		mc.UpdatedAt = &now
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(mc.LogMessageID) > 0 {
+		_, err = s.ChannelMessageEditEmbed(mc.LogChannelID, mc.LogMessageID, c.caseEmbed(mc))
+		// The log post has been deleted, the case itself is still updated
+		if err != nil && !isUnknownMessage(err) {
+			return err
+		}
+	}
+
+	eMsg := c.CreateDefinedEmbed("Case Reason", "Updated case `#"+strconv.Itoa(id)+"`.", "success", m.Author)
+	_, err = c.SendEmbed(s, m, eMsg)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// casePages lists cases newest first, split into pages that fit in an embed.
+func casePages(cases []models.Case) []string {
+	var pages []string
+	page := ""
+	for k := len(cases) - 1; k >= 0; k-- {
+		mc := cases[k]
+		line := "`#" + strconv.Itoa(mc.ID) + "` <t:" + strconv.FormatInt(mc.CreatedAt.Unix(), 10) + ":d> **" + mc.Action + "**"
+		if mc.Duration > 0 {
+			line += " (" + formatDuration(mc.Duration) + ")"
+		}
+		line += " by <@" + mc.ModeratorID + ">"
+		if len(mc.Reason) > 0 {
+			line += ": " + mc.Reason
+		}
+		line += "\n"
+
+		if len(page)+len(line) > casePageLength && len(page) > 0 {
+			pages = append(pages, page)
+			page = ""
+		}
+		page += line
+	}
+	if len(page) > 0 {
+		pages = append(pages, page)
+	}
+
+	return pages
+}
+
+func (c *Commands) casePageEmbed(target *discordgo.User, pages []string, page int, user *discordgo.User) *discordgo.MessageEmbed {
+	msg := "<@" + target.ID + "> has no cases."
+	if len(pages) > 0 {
+		msg = pages[page]
+	}
+
+	embed := c.CreateDefinedEmbed("Cases ("+target.Username+")", msg, "", user)
+	if len(pages) > 1 {
+		embed.Footer.Text = "Page " + strconv.Itoa(page+1) + "/" + strconv.Itoa(len(pages)) + " - " + embed.Footer.Text
+	}
+
+	return embed
+}
+
+func (c *Commands) handleListCases(s discord.Session, m *ScuzzyContext) error {
+	target := m.Args.User("user")
+
+	cases, err := c.UserCases(m.GuildID, target.ID)
+	if err != nil {
+		return err
+	}
+	pages := casePages(cases)
+
+	key := m.Author.ID + ":" + target.ID
+	_, err = c.SendEmbedComponents(s, m, c.casePageEmbed(target, pages, 0, m.Author), pageButtons(caseButtonPrefix, 0, len(pages), key))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// handleCasesButton turns the page of a case list. Only the moderator who listed the cases can page it.
+func (c *Commands) handleCasesButton(s discord.Session, i *discordgo.InteractionCreate, guild *Guild) error {
+	parts := strings.Split(strings.TrimPrefix(i.MessageComponentData().CustomID, caseButtonPrefix), ":")
+	if len(parts) != 3 {
+		return errors.New("Malformed cases button")
+	}
+	page, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return err
+	}
+
+	if parts[1] != i.Member.User.ID {
+		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "This case list belongs to someone else, use `" + commandKey(guild.Config) + "cases` to get your own.",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+	}
+
+	target, err := s.User(parts[2])
+	if err != nil {
+		return err
+	}
+	cases, err := c.UserCases(i.GuildID, target.ID)
+	if err != nil {
+		return err
+	}
+
+	pages := casePages(cases)
+	if page < 0 || page >= len(pages) {
+		page = 0
+	}
+
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{c.casePageEmbed(target, pages, page, i.Member.User)},
+			Components: pageButtons(caseButtonPrefix, page, len(pages), parts[1]+":"+parts[2]),
+		},
+	})
+}
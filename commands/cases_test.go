@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/foxtrot/scuzzy/actions"
+	"github.com/foxtrot/scuzzy/models"
+)
+
+func TestSplitEvidence(t *testing.T) {
+	link := "https://discord.com/channels/1/2/3"
+	reason, evidence := splitEvidence("Spamming " + link + " again https://ptb.discordapp.com/channels/1/2/4")
+
+	if reason != "Spamming again" {
+		t.Errorf("reason = %q", reason)
+	}
+	if len(evidence) != 2 || evidence[0] != link {
+		t.Errorf("evidence = %v", evidence)
+	}
+
+	if reason, evidence = splitEvidence("https://example.com/channels/1/2/3"); len(evidence) != 0 || reason != "https://example.com/channels/1/2/3" {
+		t.Errorf("only Discord links are evidence, got %q, %v", reason, evidence)
+	}
+}
+
+func TestRecordAction(t *testing.T) {
+	b := newTestBot(t, map[string]interface{}{"mod_log_channel": testChannelID})
+
+	for _, a := range []actions.Action{
+		{Type: actions.ActionWarn, UserID: testUserID, Reason: "First https://discord.com/channels/1/2/3"},
+		{Type: actions.ActionTimeout, UserID: testUserID, Duration: time.Hour},
+		{Type: actions.ActionWarn, UserID: testAdminID},
+	} {
+		a.GuildID = testGuildID
+		a.ModeratorID = testAdminID
+		b.recordAction(b.s, a)
+	}
+
+	cases, err := b.UserCases(testGuildID, testUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) != 2 || cases[0].ID != 1 || cases[1].ID != 2 {
+		t.Fatalf("expected cases 1 and 2, got %+v", cases)
+	}
+	if cases[0].Reason != "First" || len(cases[0].Evidence) != 1 || cases[1].Duration != time.Hour {
+		t.Errorf("got %+v", cases)
+	}
+	if len(b.s.Sent[testChannelID]) != 3 || len(cases[0].LogMessageID) == 0 {
+		t.Errorf("expected every case posted to the mod log, got %d posts", len(b.s.Sent[testChannelID]))
+	}
+
+	// Each case has its own file, and numbering carries on after a restart
+	names, err := b.Data.Names(testGuildID, casesDir)
+	if err != nil || len(names) != 3 {
+		t.Errorf("expected 3 case files, got %v, %v", names, err)
+	}
+
+	restarted := b.start()
+	mc, err := restarted.FindCase(testGuildID, 3)
+	if err != nil || mc.UserID != testAdminID {
+		t.Errorf("got %+v, %v", mc, err)
+	}
+	restarted.recordAction(b.s, actions.Action{Type: actions.ActionKick, GuildID: testGuildID, UserID: testUserID, ModeratorID: testAdminID})
+	if mc, err = restarted.FindCase(testGuildID, 4); err != nil || mc.Action != actions.ActionKick {
+		t.Errorf("got %+v, %v", mc, err)
+	}
+}
+
+func TestCaseReason(t *testing.T) {
+	b := newTestBot(t, nil)
+
+	b.recordAction(b.s, actions.Action{Type: actions.ActionWarn, GuildID: testGuildID, UserID: testUserID, ModeratorID: testAdminID, Reason: "Spam"})
+	b.run(".reason 1 Spamming links https://discord.com/channels/1/2/3")
+
+	mc, err := b.FindCase(testGuildID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mc.Reason != "Spamming links" || len(mc.Evidence) != 1 || mc.UpdatedBy != testAdminID {
+		t.Errorf("got %+v", mc)
+	}
+	if calls := b.s.CallsTo("ChannelMessageEditEmbed"); len(calls) != 1 || calls[0].Args[1] != mc.LogMessageID {
+		t.Errorf("expected the log post to be updated, got %v", calls)
+	}
+}
+
+func TestCasePages(t *testing.T) {
+	var cases []models.Case
+	for k := 1; k <= 40; k++ {
+		cases = append(cases, models.Case{ID: k, Action: actions.ActionWarn, ModeratorID: testAdminID, Reason: strings.Repeat("x", 100)})
+	}
+
+	pages := casePages(cases)
+	if len(pages) < 2 {
+		t.Fatalf("expected several pages, got %d", len(pages))
+	}
+	lines := 0
+	for _, page := range pages {
+		if len(page) > casePageLength {
+			t.Errorf("page is %d long, over %d", len(page), casePageLength)
+		}
+		lines += strings.Count(page, "\n")
+	}
+	if lines != len(cases) {
+		t.Errorf("expected every case listed once, got %d lines", lines)
+	}
+	if !strings.HasPrefix(pages[0], "`#40`") {
+		t.Errorf("expected the newest case first, got %q", pages[0][:10])
+	}
+
+	if pages := casePages(nil); len(pages) != 0 {
+		t.Errorf("expected no pages, got %v", pages)
+	}
+	if pages := casePages(cases[:1]); len(pages) != 1 || !strings.Contains(pages[0], "`#1`") {
+		t.Errorf("got %v", pages)
+	}
+}
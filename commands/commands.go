@@ -1,25 +1,130 @@
 package commands
 
 import (
+	"context"
+	"sync"
+	"time"
+
 	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/actions"
+	"github.com/foxtrot/scuzzy/config"
+	"github.com/foxtrot/scuzzy/discord"
 	"github.com/foxtrot/scuzzy/models"
 	"github.com/foxtrot/scuzzy/permissions"
+	"github.com/foxtrot/scuzzy/storage"
 )
 
-type ScuzzyHandler func(session *discordgo.Session, m *discordgo.MessageCreate) error
+type ScuzzyHandler func(session discord.Session, m *ScuzzyContext) error
+
+type ScuzzyArgument struct {
+	Name        string
+	Description string
+	Type        ArgumentType
+	Required    bool
+	// Variadic arguments take as many values as parse, read back with Strings or Users. Only the
+	// last argument, or the one before a trailing ArgRest that takes what follows, may be variadic.
+	// Variadic users are only looked up in the state, those it hasn't seen have just their ID.
+	Variadic bool
+	Choices  []string
+
+	// ChoicesFrom builds the choices from a guild's configuration, e.g. its colour roles.
+	ChoicesFrom func(conf *models.Configuration) []string
+}
 
 type ScuzzyCommand struct {
 	Index       int
 	Name        string
+	Aliases     []string
 	Description string
-	AdminOnly   bool
+	Category    string
+	Examples    []string
+	Tier        permissions.Tier
+	Permissions int64
+	Hidden      bool
+	Response    ScuzzyResponse
+	Cooldown    ScuzzyCooldown
+	Timeout     time.Duration
+	Arguments   []ScuzzyArgument
+	Subcommands []ScuzzyCommand
 	Handler     ScuzzyHandler
+
+	// Path is the full invocation name including parent groups, e.g. "role join". Set on registration.
+	Path string
+	// Module is the name of the module that provides the command, empty for built in commands.
+	Module string
+}
+
+// ScuzzyContext is the message a command was invoked with. Slash commands are
+// converted into an equivalent message and keep a reference to their Interaction.
+type ScuzzyContext struct {
+	*discordgo.MessageCreate
+	*Guild
+
+	Command     *ScuzzyCommand
+	Args        *ScuzzyArguments
+	Interaction *discordgo.Interaction
+
+	// Response is the command's response policy for this guild. Handlers may change it, e.g. to keep a reply.
+	Response ScuzzyResponse
+
+	// Context is cancelled once the command runs past its deadline.
+	Context context.Context
+
+	// Invocation is the content following the command prefix, e.g. "role join pineapple".
+	Invocation string
+}
+
+// Guild is the configuration and permissions of one server Scuzzy is serving.
+type Guild struct {
+	Config      *models.Configuration
+	Permissions *permissions.Permissions
+
+	// modules holds the config section of each module the guild has enabled.
+	modules map[string]interface{}
+	// templates holds the guild's message templates, compiled.
+	templates guildTemplates
 }
 
 type Commands struct {
 	Token                 string
-	Permissions           *permissions.Permissions
-	Config                *models.Configuration
+	Store                 *config.Store
+	Data                  *storage.Store
 	ScuzzyCommands        map[string]ScuzzyCommand
 	ScuzzyCommandsByIndex map[int]ScuzzyCommand
+	ScuzzyAliases         map[string]string
+	Metrics               *Metrics
+	// Actions takes moderation actions, opening cases for them and tracking bans and mutes.
+	Actions *actions.Actions
+
+	cooldowns  *Cooldowns
+	middleware []ScuzzyMiddleware
+	modules    []loadedModule
+	workers    *WorkerPool
+	scheduler  *Scheduler
+	// taskWorkers runs the scheduler's jobs apart from workers.
+	taskWorkers *WorkerPool
+
+	tasksMu      sync.Mutex
+	tasks        map[string]map[string]ScheduledTask
+	taskHandlers map[string]TaskHandler
+
+	tagsMu sync.Mutex
+	tags   map[string]map[string]models.Tag
+
+	// modMu guards the moderation records in Data, such as warnings, and cases.
+	modMu sync.Mutex
+	cases map[string][]models.Case
+
+	massbanMu sync.Mutex
+	massbans  map[string]pendingMassban
+
+	// raidMu guards the join windows used for raid detection and raid mode starting or ending.
+	raidMu sync.Mutex
+	raids  map[string]*raidState
+
+	// configMu orders configuration changes and reloads so the newest is the one served.
+	configMu sync.Mutex
+
+	guildsMu sync.RWMutex
+	guilds   map[string]*Guild
 }
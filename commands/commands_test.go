@@ -0,0 +1,218 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/config"
+	"github.com/foxtrot/scuzzy/discord/discordtest"
+	"github.com/foxtrot/scuzzy/storage"
+)
+
+const (
+	testGuildID   = "100000000000000001"
+	testBotID     = "200000000000000000"
+	testLogID     = "300000000000000001"
+	testChannelID = "300000000000000002"
+	testAdminRole = "400000000000000001"
+	testJoinRole  = "400000000000000002"
+	testMuteRole  = "400000000000000003"
+	testAdminID   = "500000000000000001"
+	testUserID    = "500000000000000002"
+)
+
+func TestMain(m *testing.M) {
+	// Every command registered and event handled is logged, which buries test failures
+	log.SetOutput(ioutil.Discard)
+
+	os.Exit(m.Run())
+}
+
+// testBot is a Commands serving one guild on a fake session, with an admin and a regular member.
+type testBot struct {
+	*Commands
+
+	t     *testing.T
+	dir   string
+	s     *discordtest.Session
+	guild *discordgo.Guild
+	admin *discordgo.Member
+
+	nextID int
+}
+
+// newTestBot starts a bot whose guild is configured with conf on top of a command key, admin role
+// and logging channel.
+func newTestBot(t *testing.T, conf map[string]interface{}) *testBot {
+	t.Helper()
+
+	full := map[string]interface{}{
+		"command_key":     ".",
+		"guild_id":        testGuildID,
+		"admin_roles":     []string{"Admin"},
+		"logging_channel": testLogID,
+	}
+	for k, v := range conf {
+		full[k] = v
+	}
+	j, err := json.Marshal(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	err = ioutil.WriteFile(filepath.Join(dir, testGuildID+".json"), j, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := discordtest.NewSession(testBotID)
+	guild := &discordgo.Guild{
+		ID:   testGuildID,
+		Name: "Test",
+		Roles: []*discordgo.Role{
+			{ID: testGuildID, Name: "@everyone"},
+			{ID: testAdminRole, Name: "Admin", Permissions: discordgo.PermissionAdministrator},
+			{ID: testJoinRole, Name: "Member"},
+			{ID: testMuteRole, Name: "Muted"},
+		},
+		Channels: []*discordgo.Channel{
+			{ID: testLogID, GuildID: testGuildID, Name: "log"},
+			{ID: testChannelID, GuildID: testGuildID, Name: "general"},
+		},
+	}
+	err = s.State.GuildAdd(guild)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &testBot{t: t, dir: dir, s: s, guild: guild}
+	b.admin = b.addMember(testAdminID, "admin", testAdminRole)
+	b.addMember(testUserID, "bob")
+	b.Commands = b.start()
+
+	return b
+}
+
+// start loads a fresh Commands from the bot's config and data directories, with mods loaded.
+func (b *testBot) start(mods ...Module) *Commands {
+	b.t.Helper()
+
+	store, err := config.NewStore(b.dir)
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	data, err := storage.New(filepath.Join(b.dir, "data"))
+	if err != nil {
+		b.t.Fatal(err)
+	}
+
+	c := &Commands{Store: store, Data: data}
+	err = c.RegisterHandlers()
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	for _, mod := range mods {
+		err = c.LoadModule(mod)
+		if err != nil {
+			b.t.Fatal(err)
+		}
+	}
+	b.t.Cleanup(c.Shutdown)
+
+	c.HandleEvent(b.s, &discordgo.GuildCreate{Guild: b.guild})
+	c.Wait()
+
+	return c
+}
+
+func (b *testBot) addMember(id string, name string, roles ...string) *discordgo.Member {
+	b.t.Helper()
+
+	member := &discordgo.Member{GuildID: testGuildID, User: &discordgo.User{ID: id, Username: name}, Roles: roles}
+	err := b.s.State.MemberAdd(member)
+	if err != nil {
+		b.t.Fatal(err)
+	}
+
+	return member
+}
+
+func (b *testBot) member(id string) *discordgo.Member {
+	member, err := b.s.State.Member(testGuildID, id)
+	if err != nil {
+		return nil
+	}
+
+	return member
+}
+
+// run sends a command as the admin and waits for it to be handled.
+func (b *testBot) run(content string) {
+	b.runAs(b.admin, content)
+}
+
+// runAs sends a message as member and waits for it to be handled.
+func (b *testBot) runAs(member *discordgo.Member, content string) {
+	b.nextID++
+	msg := &discordgo.Message{
+		ID:        strconv.Itoa(600000000000000000 + b.nextID),
+		ChannelID: testChannelID,
+		GuildID:   testGuildID,
+		Content:   content,
+		Author:    member.User,
+		Member:    member,
+	}
+
+	b.HandleEvent(b.s, &discordgo.MessageCreate{Message: msg})
+	b.Wait()
+}
+
+// join adds a member as if they had just joined the guild.
+func (b *testBot) join(id string) *discordgo.Member {
+	member := b.addMember(id, "new")
+
+	b.HandleEvent(b.s, &discordgo.GuildMemberAdd{Member: member})
+	b.Wait()
+
+	return member
+}
+
+func hasRole(member *discordgo.Member, roleID string) bool {
+	for _, r := range member.Roles {
+		if r == roleID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestFakeSession(t *testing.T) {
+	b := newTestBot(t, nil)
+
+	if len(b.s.Commands[testGuildID]) == 0 {
+		t.Error("expected the slash commands to be published")
+	}
+
+	b.run(".ping")
+	sent := b.s.Sent[testChannelID]
+	if len(sent) != 1 || sent[0].Author.ID != testBotID {
+		t.Fatalf("expected a reply, got %v", sent)
+	}
+
+	// Errors makes the fake fail calls the way Discord would
+	b.s.Errors["ChannelMessageSendEmbed"] = errors.New("500 Internal Server Error")
+	b.s.Errors["ChannelMessageSendComplex"] = errors.New("500 Internal Server Error")
+	b.run(".ping")
+	if len(b.s.Sent[testChannelID]) != 1 {
+		t.Error("a failed reply shouldn't be kept")
+	}
+}
@@ -0,0 +1,147 @@
+package commands
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/foxtrot/scuzzy/discord"
+	"github.com/foxtrot/scuzzy/models"
+)
+
+type ScuzzyCooldown struct {
+	User    time.Duration
+	Channel time.Duration
+	Global  time.Duration
+}
+
+type cooldownBucket struct {
+	Key      string
+	Duration time.Duration
+}
+
+type Cooldowns struct {
+	sync.Mutex
+	expiry map[string]time.Time
+}
+
+func NewCooldowns() *Cooldowns {
+	return &Cooldowns{
+		expiry: make(map[string]time.Time),
+	}
+}
+
+// Take starts every bucket's cooldown, unless one is still active, in which case nothing changes and how
+// long is left on the longest active bucket is returned. Checking and starting at once stops two commands
+// sent together both getting through.
+func (cd *Cooldowns) Take(buckets []cooldownBucket) time.Duration {
+	cd.Lock()
+	defer cd.Unlock()
+
+	var remaining time.Duration
+	now := time.Now()
+	for _, b := range buckets {
+		if left := cd.expiry[b.Key].Sub(now); left > remaining {
+			remaining = left
+		}
+	}
+	if remaining > 0 {
+		return remaining
+	}
+
+	// Drop expired buckets so the map doesn't grow forever
+	if len(cd.expiry) > 1024 {
+		for k, v := range cd.expiry {
+			if v.Before(now) {
+				delete(cd.expiry, k)
+			}
+		}
+	}
+
+	for _, b := range buckets {
+		cd.expiry[b.Key] = now.Add(b.Duration)
+	}
+
+	return 0
+}
+
+func (cd *Cooldowns) Reset(buckets []cooldownBucket) {
+	cd.Lock()
+	defer cd.Unlock()
+
+	for _, b := range buckets {
+		delete(cd.expiry, b.Key)
+	}
+}
+
+// commandCooldown returns a command's cooldown, or the guild's command_cooldowns override. Overrides
+// may name the command by its path, name or any of its aliases, like restrictions.
+func (c *Commands) commandCooldown(conf *models.Configuration, cmd *ScuzzyCommand) ScuzzyCooldown {
+	for _, cCD := range conf.CommandCooldowns {
+		if cmd.HasName(cCD.Command) {
+			return ScuzzyCooldown{
+				User:    time.Duration(cCD.User) * time.Second,
+				Channel: time.Duration(cCD.Channel) * time.Second,
+				Global:  time.Duration(cCD.Global) * time.Second,
+			}
+		}
+	}
+
+	return cmd.Cooldown
+}
+
+func (c *Commands) cooldownBuckets(m *ScuzzyContext) []cooldownBucket {
+	cd := c.commandCooldown(m.Config, m.Command)
+	name := m.Command.Path
+
+	var buckets []cooldownBucket
+	if cd.User > 0 {
+		buckets = append(buckets, cooldownBucket{Key: "user:" + name + ":" + m.Author.ID, Duration: cd.User})
+	}
+	if cd.Channel > 0 {
+		buckets = append(buckets, cooldownBucket{Key: "channel:" + name + ":" + m.ChannelID, Duration: cd.Channel})
+	}
+	if cd.Global > 0 {
+		buckets = append(buckets, cooldownBucket{Key: "global:" + name + ":" + m.GuildID, Duration: cd.Global})
+	}
+
+	return buckets
+}
+
+// CheckCooldown replies to the user and returns false if the command is still cooling down,
+// otherwise the cooldown starts.
+func (c *Commands) CheckCooldown(s discord.Session, m *ScuzzyContext) (bool, error) {
+	if m.Permissions.CheckAdminRole(m.Member) {
+		return true, nil
+	}
+
+	buckets := c.cooldownBuckets(m)
+	if len(buckets) == 0 {
+		return true, nil
+	}
+
+	remaining := c.cooldowns.Take(buckets)
+	if remaining <= 0 {
+		return true, nil
+	}
+
+	// Tell the user once per cooldown, replying to every attempt would spam as much as the command
+	notice := []cooldownBucket{{Key: "notice:" + m.Command.Path + ":" + m.ChannelID + ":" + m.Author.ID, Duration: remaining}}
+	if c.cooldowns.Take(notice) > 0 {
+		return false, nil
+	}
+
+	secs := strconv.Itoa(int(math.Ceil(remaining.Seconds())))
+	log.Printf("[*] User %s hit the cooldown for command %s\n", m.Author.Username, m.Command.Name)
+
+	msg := c.CreateDefinedEmbed("Slow Down", "<@"+m.Author.ID+">: You're doing that too often, try again in "+secs+"s.", "error", m.Author)
+	_, err := c.SendCannedEmbed(s, m, "cooldown", map[string]string{"command": m.Command.Path, "remaining": secs + "s"}, msg)
+
+	return false, err
+}
+
+func (c *Commands) ResetCooldown(m *ScuzzyContext) {
+	c.cooldowns.Reset(c.cooldownBuckets(m))
+}
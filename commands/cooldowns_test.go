@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/foxtrot/scuzzy/models"
+)
+
+func TestCooldownsTake(t *testing.T) {
+	cd := NewCooldowns()
+	user := cooldownBucket{Key: "user:ping:1", Duration: time.Minute}
+	global := cooldownBucket{Key: "global:ping:1", Duration: time.Hour}
+
+	if left := cd.Take([]cooldownBucket{user}); left != 0 {
+		t.Fatalf("the first use should go through, %v left", left)
+	}
+	if left := cd.Take([]cooldownBucket{user}); left <= 0 || left > time.Minute {
+		t.Errorf("the second use should be held back, %v left", left)
+	}
+
+	// A bucket still cooling down holds back the rest, without starting them
+	if left := cd.Take([]cooldownBucket{user, global}); left <= 0 {
+		t.Error("an active bucket should hold back the others")
+	}
+	if _, ok := cd.expiry[global.Key]; ok {
+		t.Error("buckets shouldn't start while another is active")
+	}
+
+	cd.Reset([]cooldownBucket{user})
+	if left := cd.Take([]cooldownBucket{user, global}); left != 0 {
+		t.Errorf("reset buckets should go through, %v left", left)
+	}
+
+	// The longest bucket left is reported
+	if left := cd.Take([]cooldownBucket{user, global}); left <= time.Minute {
+		t.Errorf("expected the global cooldown to be reported, %v left", left)
+	}
+}
+
+func TestCommandCooldown(t *testing.T) {
+	c := &Commands{}
+	cmd := &ScuzzyCommand{Name: "colour", Path: "colour", Aliases: []string{"color"}, Cooldown: ScuzzyCooldown{User: 5 * time.Second}}
+
+	conf := &models.Configuration{}
+	if cd := c.commandCooldown(conf, cmd); cd.User != 5*time.Second {
+		t.Errorf("got %+v, want the command's own cooldown", cd)
+	}
+
+	for _, name := range []string{"colour", "COLOR"} {
+		conf.CommandCooldowns = []models.CommandCooldown{{Command: name, Channel: 30}}
+		cd := c.commandCooldown(conf, cmd)
+		if cd.User != 0 || cd.Channel != 30*time.Second {
+			t.Errorf("override for %q: got %+v", name, cd)
+		}
+	}
+
+	conf.CommandCooldowns = []models.CommandCooldown{{Command: "ping", Global: 30}}
+	if cd := c.commandCooldown(conf, cmd); cd.User != 5*time.Second {
+		t.Errorf("overrides for other commands shouldn't apply, got %+v", cd)
+	}
+}
+
+func TestCheckCooldown(t *testing.T) {
+	b := newTestBot(t, map[string]interface{}{
+		"command_cooldowns": []models.CommandCooldown{{Command: "ctof", User: 60}},
+	})
+	bob := b.member(testUserID)
+
+	for k := 0; k < 5; k++ {
+		b.runAs(bob, ".ctof 20")
+	}
+
+	// One reply and one notice, spamming the command after that gets nothing
+	if sent := b.s.Sent[testChannelID]; len(sent) != 2 {
+		t.Errorf("expected a reply and a cooldown notice, got %d messages", len(sent))
+	}
+
+	// Admins aren't held back
+	b.run(".ctof 20")
+	b.run(".ctof 20")
+	if sent := b.s.Sent[testChannelID]; len(sent) != 4 {
+		t.Errorf("expected admins to skip the cooldown, got %d messages", len(sent))
+	}
+}
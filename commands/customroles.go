@@ -1,23 +1,22 @@
 package commands
 
 import (
-	"errors"
-	discordgo "github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/discord"
 	"github.com/foxtrot/scuzzy/models"
 	"strings"
 )
 
-func (c *Commands) handleListCustomRoles(s *discordgo.Session, m *discordgo.MessageCreate) error {
+func (c *Commands) handleListCustomRoles(s discord.Session, m *ScuzzyContext) error {
 	msgC := "You can choose from the following roles:\n\n"
-	for _, v := range c.Config.CustomRoles {
+	for _, v := range m.Config.CustomRoles {
 		msgC += "<@&" + v.ID + "> (" + v.ShortName + ")\n"
 	}
-	msgC += "\n\n Use `" + c.Config.CommandKey + "joinrole <role_name>` to join a role.\n"
-	msgC += "Example: `" + c.Config.CommandKey + "joinrole pineapple`.\n"
+	msgC += "\n\n Use `" + commandKey(m.Config) + "role join <role_name>` to join a role.\n"
+	msgC += "Example: `" + commandKey(m.Config) + "role join pineapple`.\n"
 
 	msg := c.CreateDefinedEmbed("Joinable Roles", msgC, "", m.Author)
 
-	_, err := s.ChannelMessageSendEmbed(m.ChannelID, msg)
+	_, err := c.SendEmbed(s, m, msg)
 	if err != nil {
 		return err
 	}
@@ -25,22 +24,20 @@ func (c *Commands) handleListCustomRoles(s *discordgo.Session, m *discordgo.Mess
 	return nil
 }
 
-func (c *Commands) handleJoinCustomRole(s *discordgo.Session, m *discordgo.MessageCreate) error {
+func (c *Commands) handleJoinCustomRole(s discord.Session, m *ScuzzyContext) error {
 	var err error
 
 	rUserID := m.Author.ID
 
-	userInput := strings.Split(m.Content, " ")
-	if len(userInput) < 2 {
+	if !m.Args.Has("role") {
 		err = c.handleListCustomRoles(s, m)
 		return err
 	}
 
-	desiredRole := userInput[1]
-	desiredRole = strings.ToLower(desiredRole)
+	desiredRole := strings.ToLower(m.Args.String("role"))
 	desiredRoleID := ""
 
-	for _, role := range c.Config.CustomRoles {
+	for _, role := range m.Config.CustomRoles {
 		if role.ShortName == desiredRole {
 			desiredRoleID = role.ID
 			break
@@ -57,36 +54,29 @@ func (c *Commands) handleJoinCustomRole(s *discordgo.Session, m *discordgo.Messa
 		return err
 	} else {
 		msg := c.CreateDefinedEmbed("Join Role", "<@"+m.Author.ID+">: You have joined <@&"+desiredRoleID+">!", "success", m.Author)
-		_, err = s.ChannelMessageSendEmbed(m.ChannelID, msg)
+		_, err = c.SendCannedEmbed(s, m, "role_join", map[string]string{"role": "<@&" + desiredRoleID + ">"}, msg)
 		if err != nil {
 			return err
 		}
 	}
 
-	err = s.ChannelMessageDelete(m.ChannelID, m.ID)
-	if err != nil {
-		return err
-	}
-
 	return nil
 }
 
-func (c *Commands) handleLeaveCustomRole(s *discordgo.Session, m *discordgo.MessageCreate) error {
+func (c *Commands) handleLeaveCustomRole(s discord.Session, m *ScuzzyContext) error {
 	var err error
 
 	rUserID := m.Author.ID
 
-	userInput := strings.Split(m.Content, " ")
-	if len(userInput) < 2 {
+	if !m.Args.Has("role") {
 		err = c.handleListCustomRoles(s, m)
 		return err
 	}
 
-	desiredRole := userInput[1]
-	desiredRole = strings.ToLower(desiredRole)
+	desiredRole := strings.ToLower(m.Args.String("role"))
 	desiredRoleID := ""
 
-	for _, role := range c.Config.CustomRoles {
+	for _, role := range m.Config.CustomRoles {
 		if role.ShortName == desiredRole {
 			desiredRoleID = role.ID
 			break
@@ -103,31 +93,20 @@ func (c *Commands) handleLeaveCustomRole(s *discordgo.Session, m *discordgo.Mess
 		return err
 	} else {
 		msg := c.CreateDefinedEmbed("Leave Role", "<@"+m.Author.ID+">: You have left <@&"+desiredRoleID+">!", "success", m.Author)
-		_, err = s.ChannelMessageSendEmbed(m.ChannelID, msg)
+		_, err = c.SendCannedEmbed(s, m, "role_leave", map[string]string{"role": "<@&" + desiredRoleID + ">"}, msg)
 		if err != nil {
 			return err
 		}
 	}
 
-	err = s.ChannelMessageDelete(m.ChannelID, m.ID)
-	if err != nil {
-		return err
-	}
-
 	return nil
 }
 
-func (c *Commands) handleAddCustomRole(s *discordgo.Session, m *discordgo.MessageCreate) error {
+func (c *Commands) handleAddCustomRole(s discord.Session, m *ScuzzyContext) error {
 	var err error
 
-	userInput := strings.Split(m.Content, " ")
-	if len(userInput) < 3 {
-		return errors.New("Expected Arguments: short_name role_id")
-	}
-
-	shortName := userInput[1]
-	shortName = strings.ToLower(shortName)
-	roleID := userInput[2]
+	shortName := strings.ToLower(m.Args.String("short_name"))
+	roleID := m.Args.Role("role").ID
 
 	customRole := models.CustomRole{
 		Name:      "",
@@ -135,7 +114,13 @@ func (c *Commands) handleAddCustomRole(s *discordgo.Session, m *discordgo.Messag
 		ID:        roleID,
 	}
 
-	c.Config.CustomRoles = append(c.Config.CustomRoles, customRole)
+	err = c.UpdateConfig(s, m, func(conf *models.Configuration) error {
+		conf.CustomRoles = append(conf.CustomRoles, customRole)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
 	err = c.handleSaveConfig(s, m)
 	if err != nil {
@@ -144,3 +129,12 @@ func (c *Commands) handleAddCustomRole(s *discordgo.Session, m *discordgo.Messag
 
 	return nil
 }
+
+func customRoleNames(conf *models.Configuration) []string {
+	var names []string
+	for _, role := range conf.CustomRoles {
+		names = append(names, role.ShortName)
+	}
+
+	return names
+}
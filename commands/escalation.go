@@ -0,0 +1,166 @@
+package commands
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/foxtrot/scuzzy/actions"
+	"github.com/foxtrot/scuzzy/discord"
+	"github.com/foxtrot/scuzzy/models"
+)
+
+// escalationRules returns a guild's escalation policy. MaxUserWarnings and MaxUserKicks
+// are rules of their own, checked after the configured ones.
+func escalationRules(conf *models.Configuration) []models.EscalationRule {
+	rules := append([]models.EscalationRule(nil), conf.Escalations...)
+
+	if conf.MaxUserWarnings > 0 {
+		action := conf.WarningAction
+		if len(action) == 0 {
+			action = "alert"
+		}
+		rules = append(rules, models.EscalationRule{After: actions.ActionWarn, Count: conf.MaxUserWarnings, Action: action, Duration: conf.WarningDuration})
+	}
+	if conf.MaxUserKicks > 0 {
+		rules = append(rules, models.EscalationRule{After: actions.ActionKick, Count: conf.MaxUserKicks, Action: actions.ActionBan})
+	}
+
+	return rules
+}
+
+// actionNoun names a type of action in the plural, for explanations.
+func actionNoun(kind string) string {
+	if kind == actions.ActionWarn {
+		return "warnings"
+	}
+
+	return kind + "s"
+}
+
+// countActions returns the actions of a type against a user since a time. Warnings only count while active.
+func (c *Commands) countActions(guildID string, userID string, kind string, since time.Time) ([]models.Case, error) {
+	var counted []models.Case
+
+	if kind == actions.ActionWarn {
+		warnings, err := c.UserWarnings(guildID, userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, w := range warnings {
+			if w.CreatedAt.After(since) {
+				counted = append(counted, models.Case{Action: kind, UserID: userID, ModeratorID: w.ModeratorID, Reason: w.Reason, CreatedAt: w.CreatedAt})
+			}
+		}
+
+		return counted, nil
+	}
+
+	cases, err := c.UserCases(guildID, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, mc := range cases {
+		if mc.Action == kind && mc.CreatedAt.After(since) {
+			counted = append(counted, mc)
+		}
+	}
+
+	return counted, nil
+}
+
+// escalate applies the first rule an action brings a user up to. Rules fire as the count reaches
+// them rather than on every action past it, so an escalation can't set itself off again.
+func (c *Commands) escalate(s discord.Session, a actions.Action) error {
+	guild, ok := c.Guild(a.GuildID)
+	if !ok {
+		return nil
+	}
+
+	for _, rule := range escalationRules(guild.Config) {
+		if rule.After != a.Type || rule.Count <= 0 {
+			continue
+		}
+
+		var since time.Time
+		if rule.Within > 0 {
+			since = time.Now().Add(-time.Duration(rule.Within) * time.Second)
+		}
+
+		counted, err := c.countActions(a.GuildID, a.UserID, rule.After, since)
+		if err != nil {
+			return err
+		}
+		if len(counted) != rule.Count {
+			continue
+		}
+
+		return c.applyEscalation(s, a, rule, counted)
+	}
+
+	return nil
+}
+
+func (c *Commands) applyEscalation(s discord.Session, a actions.Action, rule models.EscalationRule, counted []models.Case) error {
+	botID := s.GetState().User.ID
+	noun := actionNoun(rule.After)
+	d := time.Duration(rule.Duration) * time.Second
+	reason := "Escalation: " + strconv.Itoa(rule.Count) + " " + noun
+
+	var (
+		err     error
+		outcome string
+	)
+	switch rule.Action {
+	case actions.ActionTimeout:
+		if d <= 0 {
+			return errors.New("The escalation after " + strconv.Itoa(rule.Count) + " " + noun + " needs a duration for its timeout.")
+		}
+		err = c.TimeoutUser(s, a.GuildID, a.UserID, botID, d, reason)
+		outcome = "Timed out for " + formatDuration(d)
+	case actions.ActionKick:
+		err = c.Actions.KickUser(s, a.GuildID, a.UserID, botID, reason)
+		outcome = "Kicked"
+	case actions.ActionBan:
+		err = c.Actions.TempBanUser(s, a.GuildID, a.UserID, botID, d, 0, reason)
+		outcome = "Banned"
+		if d > 0 {
+			outcome += " for " + formatDuration(d)
+		}
+	case "alert":
+		outcome = "None, please review"
+	default:
+		return errors.New("Unknown escalation action `" + rule.Action + "`.")
+	}
+	if err != nil {
+		return err
+	}
+
+	msg := "<@" + a.UserID + "> reached `" + strconv.Itoa(rule.Count) + "` " + noun
+	if rule.Within > 0 {
+		msg += " within " + formatDuration(time.Duration(rule.Within)*time.Second)
+	}
+	msg += ".\n"
+	msg += "**Triggered By**: " + a.Type + " from <@" + a.ModeratorID + ">"
+	if len(a.Reason) > 0 {
+		msg += ", " + a.Reason
+	}
+	msg += "\n"
+	msg += "**Action Taken**: " + outcome + "\n\n"
+
+	msg += "**Counted**:\n"
+	for _, mc := range counted {
+		if mc.ID > 0 {
+			msg += "`#" + strconv.Itoa(mc.ID) + "` "
+		}
+		msg += "<t:" + strconv.FormatInt(mc.CreatedAt.Unix(), 10) + ":d> " + mc.Action + " by <@" + mc.ModeratorID + ">"
+		if len(mc.Reason) > 0 {
+			msg += ": " + mc.Reason
+		}
+		msg += "\n"
+	}
+
+	c.logGuildEvent(s, a.GuildID, c.CreateDefinedEmbed("Escalation", msg, "error", nil))
+
+	return nil
+}
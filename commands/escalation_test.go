@@ -0,0 +1,22 @@
+package commands
+
+import "testing"
+
+func TestEscalation(t *testing.T) {
+	b := newTestBot(t, map[string]interface{}{
+		"MaxUserKicks": 2,
+		"escalations":  []map[string]interface{}{{"after": "warn", "count": 2, "action": "kick"}},
+	})
+
+	b.run(".warn <@" + testUserID + "> one")
+	b.run(".warn <@" + testUserID + "> two")
+	if b.member(testUserID) != nil {
+		t.Fatal("expected a kick at the second warning")
+	}
+
+	b.addMember(testUserID, "bob")
+	b.run(".kick <@" + testUserID + ">")
+	if len(b.s.Bans[testGuildID]) != 1 {
+		t.Errorf("expected a ban at the second kick, got %v", b.s.Bans[testGuildID])
+	}
+}
@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/discord"
+	"github.com/foxtrot/scuzzy/models"
+	"github.com/foxtrot/scuzzy/permissions"
+)
+
+func (c *Commands) Guild(guildID string) (*Guild, bool) {
+	c.guildsMu.RLock()
+	defer c.guildsMu.RUnlock()
+
+	g, ok := c.guilds[guildID]
+	return g, ok
+}
+
+func (c *Commands) setGuild(g *discordgo.Guild, conf *models.Configuration) *Guild {
+	conf.GuildName = g.Name
+
+	guild := &Guild{
+		Config:      conf,
+		Permissions: permissions.New(conf, g),
+	}
+	guild.modules = c.moduleConfigs(guild)
+	guild.templates = compileTemplates(conf)
+
+	c.guildsMu.Lock()
+	if c.guilds == nil {
+		c.guilds = make(map[string]*Guild)
+	}
+	c.guilds[g.ID] = guild
+	c.guildsMu.Unlock()
+
+	return guild
+}
+
+// SetupGuild loads a guild's configuration from the store and publishes its slash commands.
+func (c *Commands) SetupGuild(s discord.Session, g *discordgo.Guild) error {
+	conf, err := c.Store.Get(g.ID)
+	if err != nil {
+		return err
+	}
+
+	guild := c.setGuild(g, conf)
+	log.Printf("[*] Serving guild %s (%s)\n", g.Name, g.ID)
+
+	err = c.loadTasks(s, g.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.PublishCommands(s, guild)
+}
+
+func discordGuild(s discord.Session, guildID string) (*discordgo.Guild, error) {
+	g, err := s.GetState().Guild(guildID)
+	if err != nil {
+		g, err = s.Guild(guildID)
+	}
+
+	return g, err
+}
+
+// ReloadGuild replaces a guild's configuration and permissions with the copy on disk.
+func (c *Commands) ReloadGuild(s discord.Session, guildID string) (*Guild, error) {
+	g, err := discordGuild(s, guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	conf, err := c.Store.Reload(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.setGuild(g, conf), nil
+}
+
+// UpdateConfig changes the configuration of the guild a command was run in. The change is made to
+// a copy that then replaces the guild's configuration, m's included, as commands running alongside
+// may be reading it. Handlers change the configuration through here rather than in place.
+func (c *Commands) UpdateConfig(s discord.Session, m *ScuzzyContext, change func(conf *models.Configuration) error) error {
+	g, err := discordGuild(s, m.GuildID)
+	if err != nil {
+		return err
+	}
+
+	c.configMu.Lock()
+	defer c.configMu.Unlock()
+
+	conf, err := c.Store.Update(m.GuildID, change)
+	if err != nil {
+		return err
+	}
+	m.Guild = c.setGuild(g, conf)
+
+	return nil
+}
+
+func (c *Commands) ProcessGuildCreate(s discord.Session, m *discordgo.GuildCreate) error {
+	return c.SetupGuild(s, m.Guild)
+}
+
+// logGuildError reports an error to a guild's logging channel.
+func (c *Commands) logGuildError(s discord.Session, guildID string, title string, err error) {
+	guild, ok := c.Guild(guildID)
+	if !ok {
+		log.Println("[!] Error " + err.Error())
+		return
+	}
+
+	eMsg := c.CreateDefinedEmbed(title, err.Error(), "error", nil)
+	_, err = s.ChannelMessageSendEmbed(guild.Config.LoggingChannel, eMsg)
+	if err != nil {
+		log.Println("[!] Error " + err.Error())
+	}
+}
+
+// logGuildEvent posts an embed to a guild's logging channel.
+func (c *Commands) logGuildEvent(s discord.Session, guildID string, embed *discordgo.MessageEmbed) {
+	guild, ok := c.Guild(guildID)
+	if !ok || len(guild.Config.LoggingChannel) == 0 {
+		return
+	}
+
+	_, err := s.ChannelMessageSendEmbed(guild.Config.LoggingChannel, embed)
+	if err != nil {
+		log.Println("[!] Error " + err.Error())
+	}
+}
@@ -2,125 +2,385 @@ package commands
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"runtime/debug"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/actions"
+	"github.com/foxtrot/scuzzy/discord"
+	"github.com/foxtrot/scuzzy/models"
+	"github.com/foxtrot/scuzzy/permissions"
 )
 
-func (c *Commands) RegisterCommand(name string, description string, adminonly bool, handler ScuzzyHandler) {
-	log.Printf("[*] Registering Command '%s'\n", name)
-	co := ScuzzyCommand{
-		Index:       len(c.ScuzzyCommands) + 1,
-		Name:        name,
-		Description: description,
-		AdminOnly:   adminonly,
-		Handler:     handler,
+func (c *Commands) prepareCommand(cmd *ScuzzyCommand, parent *ScuzzyCommand) error {
+	cmd.Path = cmd.Name
+	if parent != nil {
+		cmd.Path = parent.Path + " " + cmd.Name
+		if parent.Tier > cmd.Tier {
+			cmd.Tier = parent.Tier
+		}
+		cmd.Permissions |= parent.Permissions
+		cmd.Category = parent.Category
+	}
+
+	optional := ""
+	for k, arg := range cmd.Arguments {
+		// Variadic arguments take values up to the first that doesn't parse, only a rest argument can take what's left
+		later := cmd.Arguments[k+1:]
+		if arg.Variadic && len(later) > 0 && (len(later) > 1 || later[0].Type != ArgRest) {
+			return errors.New("Command '" + cmd.Path + "': variadic argument '" + arg.Name + "' can only be followed by a rest argument")
+		}
+
+		// Discord rejects slash commands whose required options follow optional ones, and with them
+		// every other command published alongside
+		if !arg.Required {
+			optional = arg.Name
+		} else if len(optional) > 0 {
+			return errors.New("Command '" + cmd.Path + "': required argument '" + arg.Name + "' follows optional argument '" + optional + "'")
+		}
+	}
+
+	// Groups without a handler of their own list their subcommands
+	if cmd.Handler == nil {
+		cmd.Handler = c.handleSubcommands
 	}
-	c.ScuzzyCommands[name] = co
-	c.ScuzzyCommandsByIndex[co.Index] = co
+
+	for k := range cmd.Subcommands {
+		err := c.prepareCommand(&cmd.Subcommands[k], cmd)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func (c *Commands) RegisterHandlers() {
+func (c *Commands) RegisterCommand(cmd ScuzzyCommand) error {
+	log.Printf("[*] Registering Command '%s'\n", cmd.Name)
+	err := c.prepareCommand(&cmd, nil)
+	if err != nil {
+		return err
+	}
+	cmd.Index = len(c.ScuzzyCommands) + 1
+	c.ScuzzyCommands[cmd.Name] = cmd
+	c.ScuzzyCommandsByIndex[cmd.Index] = cmd
+
+	for _, alias := range cmd.Aliases {
+		c.ScuzzyAliases[alias] = cmd.Name
+	}
+
+	return nil
+}
+
+func (c *Commands) FindCommand(name string) (ScuzzyCommand, bool) {
+	name = strings.ToLower(name)
+
+	if cmd, ok := c.ScuzzyCommands[name]; ok {
+		return cmd, true
+	}
+	if cName, ok := c.ScuzzyAliases[name]; ok {
+		return c.ScuzzyCommands[cName], true
+	}
+
+	return ScuzzyCommand{}, false
+}
+
+func (cmd *ScuzzyCommand) FindSubcommand(name string) *ScuzzyCommand {
+	for k := range cmd.Subcommands {
+		sub := &cmd.Subcommands[k]
+		if strings.EqualFold(sub.Name, name) {
+			return sub
+		}
+		for _, alias := range sub.Aliases {
+			if strings.EqualFold(alias, name) {
+				return sub
+			}
+		}
+	}
+
+	return nil
+}
+
+// Names returns every name the command answers to: its path, name and aliases.
+func (cmd *ScuzzyCommand) Names() []string {
+	return append([]string{cmd.Path, cmd.Name}, cmd.Aliases...)
+}
+
+// HasName reports whether name is the command's path, name or one of its aliases.
+func (cmd *ScuzzyCommand) HasName(name string) bool {
+	for _, n := range cmd.Names() {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ResolveCommand finds the command a name like `w` or `case v` refers to, following aliases.
+func (c *Commands) ResolveCommand(name string) (*ScuzzyCommand, bool) {
+	fields := strings.Fields(name)
+	if len(fields) == 0 {
+		return nil, false
+	}
+
+	cmd, ok := c.FindCommand(fields[0])
+	if !ok {
+		return nil, false
+	}
+
+	for _, f := range fields[1:] {
+		sub := cmd.FindSubcommand(f)
+		if sub == nil {
+			return nil, false
+		}
+		cmd = *sub
+	}
+
+	return &cmd, true
+}
+
+// ResolveSubcommand walks the words following a command name down to the deepest matching subcommand.
+func (cmd *ScuzzyCommand) ResolveSubcommand(args []string) *ScuzzyCommand {
+	for _, arg := range args {
+		sub := cmd.FindSubcommand(arg)
+		if sub == nil {
+			break
+		}
+		cmd = sub
+	}
+
+	return cmd
+}
+
+// RegisterHandlers sets up the built in commands, tasks and middleware. It fails if a command is
+// badly defined.
+func (c *Commands) RegisterHandlers() error {
 	c.ScuzzyCommands = make(map[string]ScuzzyCommand)
 	c.ScuzzyCommandsByIndex = make(map[int]ScuzzyCommand)
+	c.ScuzzyAliases = make(map[string]string)
+	c.cooldowns = NewCooldowns()
+	c.Metrics = NewMetrics()
+	c.workers = NewWorkerPool(commandWorkers, commandQueue)
+	c.taskWorkers = NewWorkerPool(taskWorkers, taskQueue)
+	c.scheduler = NewScheduler(c.taskWorkers)
+	c.tasks = make(map[string]map[string]ScheduledTask)
+	c.taskHandlers = make(map[string]TaskHandler)
+	c.RegisterTask("delete", c.runDeleteTask)
+	c.RegisterTask("unban", c.runUnbanTask)
+	c.RegisterTask("unmute", c.runUnmuteTask)
+	c.RegisterTask(raidEndTask, c.runRaidEndTask)
+	c.Actions = actions.New(c.recordAction, c.trackBans, c.trackMutes)
+	c.tags = make(map[string]map[string]models.Tag)
+	c.cases = make(map[string][]models.Case)
+	c.massbans = make(map[string]pendingMassban)
+	c.raids = make(map[string]*raidState)
+
+	// Every command runs through these stages in order before its handler. Only commands the user
+	// may run here are logged and counted as runs
+	c.middleware = nil
+	c.Use(
+		c.RecoverMiddleware,
+		c.TimeoutMiddleware,
+		c.IgnoreMiddleware,
+		c.PermissionMiddleware,
+		c.RestrictionMiddleware,
+		c.LoggingMiddleware,
+		c.MetricsMiddleware,
+		c.CooldownMiddleware,
+		c.ArgumentMiddleware,
+		c.ResponseMiddleware,
+	)
+
+	// Only the first badly defined command is reported
+	var err error
+	register := func(cmd ScuzzyCommand) {
+		if err == nil {
+			err = c.RegisterCommand(cmd)
+		}
+	}
+
+	userArg := ScuzzyArgument{Name: "user", Description: "User to act on", Type: ArgUser, Required: true}
+	reasonArg := ScuzzyArgument{Name: "reason", Description: "Reason for the action", Type: ArgRest}
+	distanceArg := ScuzzyArgument{Name: "distance", Description: "Distance to convert", Type: ArgFloat, Required: true}
+	temperatureArg := ScuzzyArgument{Name: "temperature", Description: "Temperature to convert", Type: ArgFloat, Required: true}
+	colorArg := ScuzzyArgument{Name: "color", Description: "Color to use", Type: ArgString, ChoicesFrom: colorRoleNames}
+	roleArg := ScuzzyArgument{Name: "role", Description: "Role name", Type: ArgString}
+	configKeyArg := ScuzzyArgument{Name: "key", Description: "Configuration key", Type: ArgString, Required: true}
+	restrictCommandArg := ScuzzyArgument{Name: "command", Description: "Command to restrict, or * for every command", Type: ArgString, Required: true}
+	restrictChannelArg := ScuzzyArgument{Name: "channel", Description: "Channel or category", Type: ArgChannel}
+	restrictRoleArg := ScuzzyArgument{Name: "role", Description: "Role", Type: ArgRole}
+	tagNameArg := ScuzzyArgument{Name: "name", Description: "Tag name", Type: ArgString, Required: true}
+	tagContentArg := ScuzzyArgument{Name: "content", Description: "Text or embed JSON, may use {user}, {username}, {channel}, {server} and {args}", Type: ArgRest, Required: true}
+	allArg := ScuzzyArgument{Name: "scope", Description: "Apply to every channel", Type: ArgString, Choices: []string{"all"}}
+
+	userCooldown := ScuzzyCooldown{User: 10 * time.Second}
+	channelCooldown := ScuzzyCooldown{Channel: 15 * time.Second}
+	roleCooldown := ScuzzyCooldown{User: 30 * time.Second, Global: 2 * time.Second}
+
+	ephemeral := ScuzzyResponse{Ephemeral: true}
+	tidy := ScuzzyResponse{DeleteInvocation: true}
 
 	// Misc Commands
-	c.RegisterCommand("help", "Show Help Text", false, c.handleHelp)
-	c.RegisterCommand("info", "Show Bot Info", false, c.handleInfo)
-	c.RegisterCommand("md", "Show common Discord MarkDown formatting", false, c.handleMarkdownInfo)
-	c.RegisterCommand("userinfo", "Display a users information", false, c.handleUserInfo)
-	c.RegisterCommand("serverinfo", "Display the current servers information", false, c.handleServerInfo)
-	c.RegisterCommand("no", "", false, c.handleCat)
+	register(ScuzzyCommand{Name: "help", Category: "Misc", Examples: []string{"help", "help role join"}, Description: "Show Help Text", Response: ScuzzyResponse{Ephemeral: true, DeleteInvocation: true}, Cooldown: userCooldown, Handler: c.handleHelp,
+		Arguments: []ScuzzyArgument{{Name: "command", Description: "Command to show details for", Type: ArgRest}}})
+	register(ScuzzyCommand{Name: "info", Category: "Misc", Description: "Show Bot Info", Cooldown: channelCooldown, Handler: c.handleInfo})
+	register(ScuzzyCommand{Name: "md", Category: "Misc", Description: "Show common Discord MarkDown formatting", Cooldown: channelCooldown, Response: ScuzzyResponse{DeleteInvocation: true, DeleteAfter: 15 * time.Second}, Handler: c.handleMarkdownInfo,
+		Arguments: []ScuzzyArgument{{Name: "stay", Description: "Keep the message (admins only)", Type: ArgString, Choices: []string{"stay"}}}})
+	register(ScuzzyCommand{Name: "userinfo", Category: "Misc", Examples: []string{"userinfo", "userinfo @user"}, Description: "Display a users information", Cooldown: userCooldown, Handler: c.handleUserInfo,
+		Arguments: []ScuzzyArgument{{Name: "user", Description: "User to look up", Type: ArgUser}}})
+	register(ScuzzyCommand{Name: "serverinfo", Category: "Misc", Description: "Display the current servers information", Cooldown: channelCooldown, Handler: c.handleServerInfo})
+	register(ScuzzyCommand{Name: "no", Category: "Misc", Description: "No.", Hidden: true, Cooldown: channelCooldown, Response: tidy, Handler: c.handleCat})
 
 	// User Settings
-	c.RegisterCommand("colours", "Display available colour roles", false, c.handleUserColors)
-	c.RegisterCommand("colors", "", false, c.handleUserColors)
-	c.RegisterCommand("colour", "Set a colour role for yourself", false, c.handleUserColor)
-	c.RegisterCommand("color", "", false, c.handleUserColor)
-	c.RegisterCommand("listroles", "List user joinable roles", false, c.handleListCustomRoles)
-	c.RegisterCommand("joinrole", "Join an available role for yourself", false, c.handleJoinCustomRole)
-	c.RegisterCommand("leaverole", "Leave an available role", false, c.handleLeaveCustomRole)
+	register(ScuzzyCommand{Name: "colours", Category: "User Settings", Aliases: []string{"colors"}, Description: "Display available colour roles", Cooldown: channelCooldown, Handler: c.handleUserColors})
+	register(ScuzzyCommand{Name: "colour", Category: "User Settings", Examples: []string{"colour red"}, Aliases: []string{"color"}, Description: "Set a colour role for yourself", Cooldown: roleCooldown, Response: tidy, Handler: c.handleUserColor, Arguments: []ScuzzyArgument{colorArg}})
+	register(ScuzzyCommand{Name: "role", Category: "User Settings", Description: "Manage joinable roles", Subcommands: []ScuzzyCommand{
+		{Name: "list", Aliases: []string{"ls"}, Description: "List user joinable roles", Cooldown: channelCooldown, Handler: c.handleListCustomRoles},
+		{Name: "join", Examples: []string{"role join pineapple"}, Description: "Join an available role for yourself", Cooldown: roleCooldown, Response: tidy, Handler: c.handleJoinCustomRole, Arguments: []ScuzzyArgument{roleArg}},
+		{Name: "leave", Description: "Leave an available role", Cooldown: roleCooldown, Response: tidy, Handler: c.handleLeaveCustomRole, Arguments: []ScuzzyArgument{roleArg}},
+		{Name: "add", Description: "Add a joinable role", Tier: permissions.TierAdmin, Permissions: discordgo.PermissionManageRoles, Response: ephemeral, Handler: c.handleAddCustomRole,
+			Arguments: []ScuzzyArgument{
+				{Name: "short_name", Description: "Name users join the role with", Type: ArgString, Required: true},
+				{Name: "role", Description: "Role to make joinable", Type: ArgRole, Required: true},
+			}},
+	}})
 
 	// Conversion Helpers
-	c.RegisterCommand("ctof", "Convert Celsius to Farenheit", false, c.handleCtoF)
-	c.RegisterCommand("ftoc", "Convert Farenheit to Celsius", false, c.handleFtoC)
-	c.RegisterCommand("metofe", "Convert Meters to Feet", false, c.handleMetersToFeet)
-	c.RegisterCommand("fetome", "Convert Feet to Meters", false, c.handleFeetToMeters)
-	c.RegisterCommand("cmtoin", "Convert Centimeters to Inches", false, c.handleCentimeterToInch)
-	c.RegisterCommand("intocm", "Convert Inches to Centimeters", false, c.handleInchToCentimeter)
-	c.RegisterCommand("google4u", "Displays a letmegooglethat link", false, c.handleGoogle4U)
+	register(ScuzzyCommand{Name: "ctof", Category: "Conversions", Examples: []string{"ctof 21.5"}, Description: "Convert Celsius to Farenheit", Cooldown: userCooldown, Handler: c.handleCtoF, Arguments: []ScuzzyArgument{temperatureArg}})
+	register(ScuzzyCommand{Name: "ftoc", Category: "Conversions", Description: "Convert Farenheit to Celsius", Cooldown: userCooldown, Handler: c.handleFtoC, Arguments: []ScuzzyArgument{temperatureArg}})
+	register(ScuzzyCommand{Name: "metofe", Category: "Conversions", Description: "Convert Meters to Feet", Cooldown: userCooldown, Handler: c.handleMetersToFeet, Arguments: []ScuzzyArgument{distanceArg}})
+	register(ScuzzyCommand{Name: "fetome", Category: "Conversions", Description: "Convert Feet to Meters", Cooldown: userCooldown, Handler: c.handleFeetToMeters, Arguments: []ScuzzyArgument{distanceArg}})
+	register(ScuzzyCommand{Name: "cmtoin", Category: "Conversions", Description: "Convert Centimeters to Inches", Cooldown: userCooldown, Handler: c.handleCentimeterToInch, Arguments: []ScuzzyArgument{distanceArg}})
+	register(ScuzzyCommand{Name: "intocm", Category: "Conversions", Description: "Convert Inches to Centimeters", Cooldown: userCooldown, Handler: c.handleInchToCentimeter, Arguments: []ScuzzyArgument{distanceArg}})
+	register(ScuzzyCommand{Name: "google4u", Category: "Conversions", Examples: []string{"google4u how to flash a bash bunny"}, Description: "Displays a letmegooglethat link", Cooldown: userCooldown, Response: tidy, Handler: c.handleGoogle4U,
+		Arguments: []ScuzzyArgument{{Name: "query", Description: "Text to google", Type: ArgRest, Required: true}}})
 
 	// Admin Commands
-	c.RegisterCommand("ping", "Ping Scuzzy", true, c.handlePing)
-	c.RegisterCommand("rules", "Display the Server Rules", true, c.handleRules)
-	c.RegisterCommand("status", "Set Bot Status", true, c.handleSetStatus)
-	c.RegisterCommand("purge", "Purge Channel Messages", true, c.handlePurgeChannel)
-	c.RegisterCommand("kick", "Kick a User", true, c.handleKickUser)
-	c.RegisterCommand("ban", "Ban a User", true, c.handleBanUser)
-	c.RegisterCommand("slow", "Set Channel Slow Mode", true, c.handleSetSlowmode)
-	c.RegisterCommand("unslow", "Unset Channel Slow Mode", true, c.handleUnsetSlowmode)
-	c.RegisterCommand("ignore", "Add a user to Scuzzy's ignore list", true, c.handleIgnoreUser)
-	c.RegisterCommand("unignore", "Remove a user from Scuzzy's ignore list", true, c.handleUnIgnoreUser)
-	c.RegisterCommand("setconfig", "Set Configuration", true, c.handleSetConfig)
-	c.RegisterCommand("getconfig", "Print Configuration", true, c.handleGetConfig)
-	c.RegisterCommand("saveconfig", "Save Configuration to Disk", true, c.handleSaveConfig)
-	c.RegisterCommand("reloadconfig", "Reload Configuration", true, c.handleReloadConfig)
-	c.RegisterCommand("addrole", "Add a joinable role", true, c.handleAddCustomRole)
-}
-
-func (c *Commands) ProcessCommand(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	cKey := c.Config.CommandKey
-	cCmd := strings.Split(m.Content, " ")[0]
+	register(ScuzzyCommand{Name: "ping", Category: "Admin", Description: "Ping Scuzzy", Tier: permissions.TierModerator, Response: ScuzzyResponse{Ephemeral: true, DeleteInvocation: true, DeleteAfter: 5 * time.Second}, Handler: c.handlePing})
+	register(ScuzzyCommand{Name: "stats", Category: "Admin", Description: "Show command usage statistics", Tier: permissions.TierAdmin, Response: ephemeral, Handler: c.handleStats})
+	register(ScuzzyCommand{Name: "rules", Category: "Admin", Description: "Display the Server Rules", Tier: permissions.TierModerator, Handler: c.handleRules})
+	register(ScuzzyCommand{Name: "status", Category: "Admin", Description: "Set Bot Status", Tier: permissions.TierAdmin, Response: ephemeral, Handler: c.handleSetStatus,
+		Arguments: []ScuzzyArgument{{Name: "status", Description: "Status text", Type: ArgRest, Required: true}}})
+	register(ScuzzyCommand{Name: "purge", Category: "Moderation", Examples: []string{"purge 20", "purge 50 @user links", "purge 200 bots before 123456789012345678", "purge 100 contains \"free nitro\""}, Description: "Purge Channel Messages", Tier: permissions.TierModerator, Permissions: discordgo.PermissionManageMessages, Response: ScuzzyResponse{Ephemeral: true, DeleteInvocation: true, DeleteAfter: 10 * time.Second}, Timeout: 5 * time.Minute, Handler: c.handlePurgeChannel,
+		Arguments: []ScuzzyArgument{{Name: "count", Description: "Number of messages to purge, up to 1000", Type: ArgInt, Required: true}, {Name: "filters", Description: "Only purge messages matching: @user, bots, links, attachments, contains, regex, before, after", Type: ArgRest}}})
+	register(ScuzzyCommand{Name: "kick", Category: "Moderation", Examples: []string{"kick @user Spamming"}, Description: "Kick a User", Tier: permissions.TierModerator, Permissions: discordgo.PermissionKickMembers, Handler: c.handleKickUser, Arguments: []ScuzzyArgument{userArg, reasonArg}})
+	register(ScuzzyCommand{Name: "ban", Category: "Moderation", Examples: []string{"ban 123456789012345678 Raid account", "ban @user 7d Spamming"}, Description: "Ban a User", Tier: permissions.TierModerator, Permissions: discordgo.PermissionBanMembers, Handler: c.handleBanUser,
+		Arguments: []ScuzzyArgument{userArg, {Name: "duration", Description: "How long to ban for, permanent if left out", Type: ArgDuration}, {Name: "days", Description: "Days of their messages to delete, up to 7", Type: ArgInt}, reasonArg}})
+	register(ScuzzyCommand{Name: "timeout", Category: "Moderation", Examples: []string{"timeout @user 10m Spamming", "mute @user 1d"}, Aliases: []string{"mute"}, Description: "Stop a User talking for a while", Tier: permissions.TierModerator, Permissions: discordgo.PermissionModerateMembers, Handler: c.handleTimeoutUser,
+		Arguments: []ScuzzyArgument{userArg, {Name: "duration", Description: "How long for, e.g. 10m, 2h or 1d", Type: ArgDuration, Required: true}, reasonArg}})
+	register(ScuzzyCommand{Name: "untimeout", Category: "Moderation", Aliases: []string{"unmute"}, Description: "Let a timed out User talk again", Tier: permissions.TierModerator, Permissions: discordgo.PermissionModerateMembers, Handler: c.handleRemoveTimeout, Arguments: []ScuzzyArgument{userArg, reasonArg}})
+	register(ScuzzyCommand{Name: "antiraid", Category: "Moderation", Examples: []string{"antiraid", "antiraid on", "antiraid off"}, Description: "Show raid mode, or turn it on or off", Tier: permissions.TierModerator, Permissions: discordgo.PermissionManageServer, Handler: c.handleAntiRaid,
+		Arguments: []ScuzzyArgument{{Name: "mode", Description: "Turn raid mode on or off", Type: ArgString, Choices: []string{"on", "off"}}}})
+	register(ScuzzyCommand{Name: "unban", Category: "Moderation", Examples: []string{"unban 123456789012345678 Appealed"}, Description: "Unban a User", Tier: permissions.TierModerator, Permissions: discordgo.PermissionBanMembers, Handler: c.handleUnbanUser, Arguments: []ScuzzyArgument{userArg, reasonArg}})
+	register(ScuzzyCommand{Name: "massban", Category: "Moderation", Examples: []string{"massban 123456789012345678 234567890123456789 Raid accounts"}, Description: "Ban a list of user IDs, or an attached file of them", Tier: permissions.TierModerator, Permissions: discordgo.PermissionBanMembers, Handler: c.handleMassban,
+		Arguments: []ScuzzyArgument{{Name: "users", Description: "User IDs or mentions", Type: ArgUser, Variadic: true}, reasonArg}})
+	register(ScuzzyCommand{Name: "bans", Category: "Moderation", Examples: []string{"bans", "bans spam"}, Description: "List or search the server's bans", Tier: permissions.TierModerator, Permissions: discordgo.PermissionBanMembers, Handler: c.handleListBans,
+		Arguments: []ScuzzyArgument{{Name: "query", Description: "User ID, name or reason to search for", Type: ArgRest}}})
+	register(ScuzzyCommand{Name: "warn", Category: "Moderation", Examples: []string{"warn @user Spamming", "warn @user 30d Spamming"}, Description: "Warn a User", Tier: permissions.TierModerator, Handler: c.handleWarnUser,
+		Arguments: []ScuzzyArgument{userArg, {Name: "reason", Description: "Reason for the warning, starting with how long it counts for if it expires, e.g. 30d", Type: ArgRest, Required: true}}})
+	register(ScuzzyCommand{Name: "warnings", Category: "Moderation", Examples: []string{"warnings @user"}, Description: "List a User's active warnings", Tier: permissions.TierModerator, Handler: c.handleListWarnings, Arguments: []ScuzzyArgument{userArg}})
+	register(ScuzzyCommand{Name: "delwarn", Category: "Moderation", Examples: []string{"delwarn 12"}, Description: "Delete a warning", Tier: permissions.TierModerator, Handler: c.handleDeleteWarning,
+		Arguments: []ScuzzyArgument{{Name: "id", Description: "Warning number", Type: ArgInt, Required: true}}})
+	register(ScuzzyCommand{Name: "clearwarns", Category: "Moderation", Description: "Delete all of a User's warnings", Tier: permissions.TierModerator, Handler: c.handleClearWarnings, Arguments: []ScuzzyArgument{userArg}})
+	register(ScuzzyCommand{Name: "case", Category: "Moderation", Examples: []string{"case 42"}, Description: "Show a moderation case", Tier: permissions.TierModerator, Handler: c.handleCase,
+		Arguments: []ScuzzyArgument{{Name: "id", Description: "Case number", Type: ArgInt, Required: true}}})
+	register(ScuzzyCommand{Name: "reason", Category: "Moderation", Examples: []string{"reason 42 Spamming invite links"}, Description: "Change a case's reason or add evidence links", Tier: permissions.TierModerator, Handler: c.handleCaseReason,
+		Arguments: []ScuzzyArgument{{Name: "id", Description: "Case number", Type: ArgInt, Required: true}, {Name: "reason", Description: "New reason, message links are kept as evidence", Type: ArgRest, Required: true}}})
+	register(ScuzzyCommand{Name: "cases", Category: "Moderation", Examples: []string{"cases @user"}, Description: "List a User's moderation cases", Tier: permissions.TierModerator, Handler: c.handleListCases, Arguments: []ScuzzyArgument{userArg}})
+	register(ScuzzyCommand{Name: "slow", Category: "Moderation", Examples: []string{"slow 5", "slow 30 all"}, Description: "Set Channel Slow Mode", Tier: permissions.TierModerator, Permissions: discordgo.PermissionManageChannels, Handler: c.handleSetSlowmode,
+		Arguments: []ScuzzyArgument{{Name: "seconds", Description: "Seconds between messages", Type: ArgInt, Required: true}, allArg}})
+	register(ScuzzyCommand{Name: "unslow", Category: "Moderation", Description: "Unset Channel Slow Mode", Tier: permissions.TierModerator, Permissions: discordgo.PermissionManageChannels, Handler: c.handleUnsetSlowmode, Arguments: []ScuzzyArgument{allArg}})
+	register(ScuzzyCommand{Name: "ignore", Category: "Admin", Description: "Add a user to Scuzzy's ignore list", Tier: permissions.TierAdmin, Response: ephemeral, Handler: c.handleIgnoreUser, Arguments: []ScuzzyArgument{userArg}})
+	register(ScuzzyCommand{Name: "unignore", Category: "Admin", Description: "Remove a user from Scuzzy's ignore list", Tier: permissions.TierAdmin, Response: ephemeral, Handler: c.handleUnIgnoreUser, Arguments: []ScuzzyArgument{userArg}})
+	register(ScuzzyCommand{Name: "restrict", Category: "Admin", Description: "Manage where commands can be used", Tier: permissions.TierAdmin, Response: ephemeral, Subcommands: []ScuzzyCommand{
+		{Name: "list", Aliases: []string{"ls"}, Description: "List command restrictions", Response: ephemeral, Handler: c.handleListRestrictions},
+		{Name: "add", Examples: []string{"restrict add colour white #bots", "restrict add * black @Muted"}, Description: "Restrict a command to or from a channel, category or role", Response: ephemeral, Handler: c.handleAddRestriction,
+			Arguments: []ScuzzyArgument{restrictCommandArg, {Name: "mode", Description: "Allow only these targets, or deny them", Type: ArgString, Required: true, Choices: []string{"white", "black"}}, restrictChannelArg, restrictRoleArg}},
+		{Name: "remove", Aliases: []string{"rm"}, Description: "Remove a command restriction", Response: ephemeral, Handler: c.handleRemoveRestriction,
+			Arguments: []ScuzzyArgument{restrictCommandArg, restrictChannelArg, restrictRoleArg}},
+	}})
+	register(ScuzzyCommand{Name: "tag", Category: "Tags", Description: "Custom text commands", Subcommands: []ScuzzyCommand{
+		{Name: "list", Aliases: []string{"ls"}, Description: "List custom commands", Cooldown: channelCooldown, Handler: c.handleListTags},
+		{Name: "add", Examples: []string{"tag add vpn Please read the VPN guide {user}", "tag add vpn {\"title\": \"VPN\", \"description\": \"See {channel}\"}"}, Description: "Create a custom command from text or embed JSON", Tier: permissions.TierAdmin, Handler: c.handleAddTag,
+			Arguments: []ScuzzyArgument{tagNameArg, tagContentArg}},
+		{Name: "edit", Description: "Change a custom command", Tier: permissions.TierAdmin, Handler: c.handleEditTag, Arguments: []ScuzzyArgument{tagNameArg, tagContentArg}},
+		{Name: "delete", Aliases: []string{"rm"}, Description: "Delete a custom command", Tier: permissions.TierAdmin, Handler: c.handleDeleteTag, Arguments: []ScuzzyArgument{tagNameArg}},
+	}})
+	register(ScuzzyCommand{Name: "config", Category: "Admin", Description: "Manage Configuration", Tier: permissions.TierAdmin, Response: ephemeral, Subcommands: []ScuzzyCommand{
+		{Name: "get", Description: "Print Configuration", Response: ephemeral, Handler: c.handleGetConfig,
+			Arguments: []ScuzzyArgument{{Name: "key", Description: "Configuration key", Type: ArgString}}},
+		{Name: "set", Examples: []string{"config set status_text .help"}, Description: "Set Configuration", Response: ephemeral, Handler: c.handleSetConfig,
+			Arguments: []ScuzzyArgument{configKeyArg, {Name: "value", Description: "New value", Type: ArgRest, Required: true}}},
+		{Name: "save", Description: "Save Configuration to Disk", Response: ephemeral, Handler: c.handleSaveConfig},
+		{Name: "reload", Description: "Reload Configuration", Response: ephemeral, Handler: c.handleReloadConfig},
+	}})
+
+	return err
+}
 
+func (c *Commands) ProcessCommand(s discord.Session, m *discordgo.MessageCreate) error {
 	// Ignore the bot itself
-	if m.Author.ID == s.State.User.ID {
+	if m.Author.ID == s.GetState().User.ID {
 		return nil
 	}
 
-	// Ignore anything not starting with the command prefix
-	if !strings.HasPrefix(cCmd, cKey) {
+	// Ignore Direct Messages
+	if m.Member == nil {
 		return nil
 	}
 
-	// Ignore Direct Messages
-	if m.Member == nil {
+	guild, ok := c.Guild(m.GuildID)
+	if !ok {
 		return nil
 	}
 
-	// Ignore any users on the ignore list
-	if c.Permissions.CheckIgnoredUser(m.Author) {
-		log.Printf("[*] Ignoring command from ignored user.")
+	// Ignore anything not starting with a command prefix
+	invocation, ok := c.StripPrefix(s, guild.Config, m.Content)
+	if !ok {
 		return nil
 	}
 
-	cName := strings.Split(cCmd, cKey)[1]
+	fields := strings.Fields(invocation)
+	if cmd, ok := c.FindCommand(fields[0]); ok && guild.CommandEnabled(&cmd) {
+		sub := cmd.ResolveSubcommand(fields[1:])
+		return c.RunCommand(s, &ScuzzyContext{MessageCreate: m, Guild: guild, Command: sub, Invocation: invocation})
+	}
+	if tag, ok := c.FindTag(m.GuildID, fields[0]); ok {
+		return c.RunCommand(s, &ScuzzyContext{MessageCreate: m, Guild: guild, Command: c.tagCommand(tag), Invocation: invocation})
+	}
 
-	if cmd, ok := c.ScuzzyCommands[cName]; ok {
-		if cmd.AdminOnly && !c.Permissions.CheckAdminRole(m.Member) {
-			log.Printf("[*] User %s tried to run admin command %s\n", m.Author.Username, cName)
-			return nil
-		}
+	return c.handleUnknownCommand(s, &ScuzzyContext{MessageCreate: m, Guild: guild, Invocation: invocation}, fields[0])
+}
 
-		log.Printf("[*] Running command %s (Requested by %s)\n", cName, m.Author.Username)
+func (c *Commands) RunCommand(s discord.Session, m *ScuzzyContext) error {
+	cName := m.Command.Path
+	if m.Interaction == nil {
+		m.Response = c.commandResponse(m.Config, m.Command)
+	}
 
-		err := cmd.Handler(s, m)
+	err := c.buildChain(m.Command.Handler)(s, m)
+	if err != nil {
+		eMsg := c.CreateDefinedEmbed("Error ("+cName+")", err.Error(), "error", m.Author)
+		_, err = c.SendCannedEmbed(s, m, "error", map[string]string{"command": cName, "error": err.Error()}, eMsg)
 		if err != nil {
-			log.Printf("[!] Command %s (Requested by %s) had error: '%s'\n", cName, m.Author.Username, err.Error())
-
-			eMsg := c.CreateDefinedEmbed("Error ("+cName+")", err.Error(), "error", m.Author)
-			_, err = s.ChannelMessageSendEmbed(m.ChannelID, eMsg)
-			if err != nil {
-				return err
-			}
+			return err
 		}
 	}
 
 	return nil
 }
 
-func (c *Commands) ProcessMessageDelete(s *discordgo.Session, m *discordgo.MessageDelete) error {
+func (c *Commands) ProcessMessageDelete(s discord.Session, m *discordgo.MessageDelete) error {
+	guild, ok := c.Guild(m.GuildID)
+	if !ok {
+		return nil
+	}
+
 	msgChannelID := m.ChannelID
 
 	if m.BeforeDelete == nil {
@@ -136,7 +396,7 @@ func (c *Commands) ProcessMessageDelete(s *discordgo.Session, m *discordgo.Messa
 	msg += "`Message` - " + msgContent + "\n"
 
 	embed := c.CreateDefinedEmbed("Deleted Message", msg, "", msgAuthor)
-	_, err := s.ChannelMessageSendEmbed(c.Config.LoggingChannel, embed)
+	_, err := s.ChannelMessageSendEmbed(guild.Config.LoggingChannel, embed)
 	if err != nil {
 		return err
 	}
@@ -144,7 +404,12 @@ func (c *Commands) ProcessMessageDelete(s *discordgo.Session, m *discordgo.Messa
 	return nil
 }
 
-func (c *Commands) ProcessMessageDeleteBulk(s *discordgo.Session, m *discordgo.MessageDeleteBulk) error {
+func (c *Commands) ProcessMessageDeleteBulk(s discord.Session, m *discordgo.MessageDeleteBulk) error {
+	guild, ok := c.Guild(m.GuildID)
+	if !ok {
+		return nil
+	}
+
 	msgChannelID := m.ChannelID
 
 	msg := "`Channel` - <#" + msgChannelID + ">\n"
@@ -154,7 +419,7 @@ func (c *Commands) ProcessMessageDeleteBulk(s *discordgo.Session, m *discordgo.M
 	}
 
 	embed := c.CreateDefinedEmbed("Deleted Bulk Messages", msg, "", nil)
-	_, err := s.ChannelMessageSendEmbed(c.Config.LoggingChannel, embed)
+	_, err := s.ChannelMessageSendEmbed(guild.Config.LoggingChannel, embed)
 	if err != nil {
 		return err
 	}
@@ -162,21 +427,66 @@ func (c *Commands) ProcessMessageDeleteBulk(s *discordgo.Session, m *discordgo.M
 	return nil
 }
 
-func (c *Commands) ProcessUserJoin(s *discordgo.Session, m *discordgo.GuildMemberAdd) error {
-	userChannel, err := s.UserChannelCreate(m.User.ID)
+func (c *Commands) ProcessUserJoin(s discord.Session, m *discordgo.GuildMemberAdd) error {
+	guild, ok := c.Guild(m.GuildID)
+	if !ok {
+		return nil
+	}
+
+	// Mutes go back on whatever happens with raid detection, and raids are tracked even if they don't
+	err := c.restoreMute(s, m.GuildID, m.User.ID)
 	if err != nil {
-		log.Print("[!] Error (User Join): " + err.Error())
-		return err
+		c.logGuildError(s, m.GuildID, "Error (User Join)", err)
 	}
 
-	_, err = s.ChannelMessageSend(userChannel.ID, c.Config.WelcomeText)
+	raid, err := c.checkRaid(s, m.GuildID, guild.Config)
 	if err != nil {
-		log.Print("[!] Error (User Join): " + err.Error())
-		return err
+		c.logGuildError(s, m.GuildID, "Error (Raid Mode)", err)
 	}
 
-	for _, roleID := range c.Config.JoinRoleIDs {
-		err = s.GuildMemberRoleAdd(c.Config.GuildID, m.User.ID, roleID)
+	// Members joining during a raid are welcomed once it is over
+	if raid {
+		err = c.handleRaidJoin(s, m.GuildID, guild.Config, m.User.ID)
+		if err != nil {
+			log.Print("[!] Error (User Join): " + err.Error())
+			return err
+		}
+
+		return nil
+	}
+
+	return c.welcomeMember(s, m.GuildID, m.Member)
+}
+
+// welcomeMember sends a new member the welcome message and gives them the join roles.
+func (c *Commands) welcomeMember(s discord.Session, guildID string, member *discordgo.Member) error {
+	guild, ok := c.Guild(guildID)
+	if !ok {
+		return nil
+	}
+
+	if guild.templates.welcome != nil {
+		userChannel, err := s.UserChannelCreate(member.User.ID)
+		if err != nil {
+			log.Print("[!] Error (User Join): " + err.Error())
+			return err
+		}
+
+		msg, err := guild.templates.welcome.Render(c.templateData(s, guildID, userChannel.ID, member.User, member, nil))
+		if err != nil {
+			log.Print("[!] Error (User Join): " + err.Error())
+			return err
+		}
+
+		_, err = s.ChannelMessageSendComplex(userChannel.ID, msg)
+		if err != nil {
+			log.Print("[!] Error (User Join): " + err.Error())
+			return err
+		}
+	}
+
+	for _, roleID := range guild.Config.JoinRoleIDs {
+		err := s.GuildMemberRoleAdd(guildID, member.User.ID, roleID)
 		if err != nil {
 			log.Print("[!] Error (User Join)" + err.Error())
 			return err
@@ -187,6 +497,70 @@ func (c *Commands) ProcessUserJoin(s *discordgo.Session, m *discordgo.GuildMembe
 }
 
 func (c *Commands) ProcessMessage(s *discordgo.Session, m interface{}) {
+	c.HandleEvent(discord.Wrap(s), m)
+}
+
+// HandleEvent queues a gateway event for the worker pool. ProcessMessage feeds it live events.
+// Messages are dropped when every worker is busy, but joins wait for one: they put back mutes.
+func (c *Commands) HandleEvent(s discord.Session, m interface{}) {
+	job := func() { c.dispatchEvent(s, m) }
+
+	if _, ok := m.(*discordgo.GuildMemberAdd); ok {
+		if !c.workers.SubmitWait(job) {
+			log.Printf("[!] Dropping %T event, the worker pool has stopped\n", m)
+		}
+		return
+	}
+
+	if !c.workers.Submit(job) {
+		log.Printf("[!] Dropping %T event, all workers are busy\n", m)
+	}
+}
+
+// Wait blocks until every queued event and scheduled job has been handled.
+func (c *Commands) Wait() {
+	c.workers.Wait()
+	c.taskWorkers.Wait()
+}
+
+// Shutdown cancels scheduled jobs and waits for running handlers to finish.
+func (c *Commands) Shutdown() {
+	c.scheduler.Stop()
+	c.taskWorkers.Stop()
+	c.workers.Stop()
+}
+
+func eventGuildID(m interface{}) string {
+	switch e := m.(type) {
+	case *discordgo.MessageCreate:
+		return e.GuildID
+	case *discordgo.MessageDelete:
+		return e.GuildID
+	case *discordgo.MessageDeleteBulk:
+		return e.GuildID
+	case *discordgo.InteractionCreate:
+		return e.GuildID
+	case *discordgo.GuildCreate:
+		return e.ID
+	case *discordgo.GuildMemberAdd:
+		return e.GuildID
+	}
+
+	return ""
+}
+
+// recoverEvent reports a panicking event handler to the guild's logging channel instead of
+// taking the bot down.
+func (c *Commands) recoverEvent(s discord.Session, m interface{}) {
+	if r := recover(); r != nil {
+		log.Printf("[!] Handler for %T panicked: %v\n%s", m, r, debug.Stack())
+		c.logGuildError(s, eventGuildID(m), fmt.Sprintf("Panic (%T)", m), fmt.Errorf("%v", r))
+	}
+}
+
+func (c *Commands) dispatchEvent(s discord.Session, m interface{}) {
+	defer c.recoverEvent(s, m)
+
 	switch m.(type) {
 	case *discordgo.MessageCreate:
 		// Pass Messages to the command processor
@@ -199,21 +573,27 @@ func (c *Commands) ProcessMessage(s *discordgo.Session, m interface{}) {
 		// Log deleted messages to the logging channel.
 		err := c.ProcessMessageDelete(s, m.(*discordgo.MessageDelete))
 		if err != nil {
-			eMsg := c.CreateDefinedEmbed("Error (Message Deleted)", err.Error(), "error", nil)
-			_, err = s.ChannelMessageSendEmbed(c.Config.LoggingChannel, eMsg)
-			if err != nil {
-				log.Println("[!] Error " + err.Error())
-			}
+			c.logGuildError(s, m.(*discordgo.MessageDelete).GuildID, "Error (Message Deleted)", err)
 		}
 		break
 	case *discordgo.MessageDeleteBulk:
 		err := c.ProcessMessageDeleteBulk(s, m.(*discordgo.MessageDeleteBulk))
 		if err != nil {
-			eMsg := c.CreateDefinedEmbed("Error (Message Bulk Deleted)", err.Error(), "error", nil)
-			_, err = s.ChannelMessageSendEmbed(c.Config.LoggingChannel, eMsg)
-			if err != nil {
-				log.Println("[!] Error " + err.Error())
-			}
+			c.logGuildError(s, m.(*discordgo.MessageDeleteBulk).GuildID, "Error (Message Bulk Deleted)", err)
+		}
+		break
+	case *discordgo.InteractionCreate:
+		// Pass Slash Commands to the same command handlers
+		err := c.ProcessInteraction(s, m.(*discordgo.InteractionCreate))
+		if err != nil {
+			log.Println("[!] Error (Interaction): " + err.Error())
+		}
+		break
+	case *discordgo.GuildCreate:
+		// Load the guild's configuration as it becomes available
+		err := c.ProcessGuildCreate(s, m.(*discordgo.GuildCreate))
+		if err != nil {
+			log.Println("[!] Error (Guild Create): " + err.Error())
 		}
 		break
 	case *discordgo.GuildMemberAdd:
@@ -224,4 +604,7 @@ func (c *Commands) ProcessMessage(s *discordgo.Session, m interface{}) {
 		}
 		break
 	}
+
+	// Modules see events after Scuzzy has handled them
+	c.dispatchModuleEvent(s, eventGuildID(m), m)
 }
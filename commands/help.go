@@ -0,0 +1,301 @@
+package commands
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/discord"
+	"github.com/foxtrot/scuzzy/permissions"
+)
+
+// helpPageLength keeps each help page well inside Discord's 4096 character embed limit.
+const helpPageLength = 1800
+
+// helpButtonPrefix starts the custom ID of the help menu's page buttons, "help:<page>:<user ID>".
+const helpButtonPrefix = "help:"
+
+type helpPage struct {
+	Category string
+	Body     string
+}
+
+func (c *Commands) commandHelp(s discord.Session, m *ScuzzyContext, cmd *ScuzzyCommand) string {
+	if cmd.Hidden {
+		return ""
+	}
+
+	help := ""
+	if canRun, _ := c.CanRunCommand(s, m, cmd); canRun {
+		if cmd.Path != cmd.Name {
+			help += "↳ "
+		}
+		help += "`" + cmd.Path + "`"
+		if len(cmd.Aliases) > 0 {
+			help += " (`" + strings.Join(cmd.Aliases, "`, `") + "`)"
+		}
+		help += " - " + cmd.Description + "\n"
+	}
+
+	for k := range cmd.Subcommands {
+		help += c.commandHelp(s, m, &cmd.Subcommands[k])
+	}
+
+	return help
+}
+
+// helpPages groups the commands a user can run by category, splitting long categories over several pages.
+func (c *Commands) helpPages(s discord.Session, m *ScuzzyContext) []helpPage {
+	keys := make([]int, 0, len(c.ScuzzyCommandsByIndex))
+	for k := range c.ScuzzyCommandsByIndex {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	var categories []string
+	lines := make(map[string][]string)
+	for _, k := range keys {
+		cmd := c.ScuzzyCommandsByIndex[k]
+		if !m.CommandEnabled(&cmd) {
+			continue
+		}
+		help := c.commandHelp(s, m, &cmd)
+		if len(help) == 0 {
+			continue
+		}
+
+		if _, ok := lines[cmd.Category]; !ok {
+			categories = append(categories, cmd.Category)
+		}
+		lines[cmd.Category] = append(lines[cmd.Category], help)
+	}
+
+	var pages []helpPage
+	for _, category := range categories {
+		page := helpPage{Category: category}
+		for _, help := range lines[category] {
+			if len(page.Body)+len(help) > helpPageLength {
+				pages = append(pages, page)
+				page = helpPage{Category: category}
+			}
+			page.Body += help
+		}
+		pages = append(pages, page)
+	}
+
+	return pages
+}
+
+func (c *Commands) helpPageEmbed(s discord.Session, m *ScuzzyContext, pages []helpPage, page int) *discordgo.MessageEmbed {
+	keys := commandKeys(m.Config)
+
+	title := "Help"
+	desc := ""
+	if len(pages) > 0 {
+		title += " - " + pages[page].Category
+		desc = pages[page].Body
+	}
+	desc += "\nUse `" + commandKey(m.Config) + "help <command>` for details on a command.\n"
+	desc += "All commands are prefixed with `" + strings.Join(keys, "`, `") + "` or <@" + s.GetState().User.ID + ">\n"
+
+	embed := c.CreateDefinedEmbed(title, desc, "", m.Author)
+	if len(pages) > 1 {
+		embed.Footer.Text = "Page " + strconv.Itoa(page+1) + "/" + strconv.Itoa(len(pages)) + " - " + embed.Footer.Text
+	}
+
+	return embed
+}
+
+// pageButtons builds the buttons that page through a paged reply. Their custom IDs are
+// "<prefix><page>:<key>", key holding whatever is needed to rebuild the page.
+func pageButtons(prefix string, page int, total int, key string) []discordgo.MessageComponent {
+	if total <= 1 {
+		return nil
+	}
+
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Previous",
+					Style:    discordgo.SecondaryButton,
+					CustomID: prefix + strconv.Itoa(page-1) + ":" + key,
+					Disabled: page == 0,
+				},
+				discordgo.Button{
+					Label:    "Next",
+					Style:    discordgo.SecondaryButton,
+					CustomID: prefix + strconv.Itoa(page+1) + ":" + key,
+					Disabled: page == total-1,
+				},
+			},
+		},
+	}
+}
+
+func formatCooldown(cd ScuzzyCooldown) string {
+	var parts []string
+	if cd.User > 0 {
+		parts = append(parts, cd.User.String()+" per user")
+	}
+	if cd.Channel > 0 {
+		parts = append(parts, cd.Channel.String()+" per channel")
+	}
+	if cd.Global > 0 {
+		parts = append(parts, cd.Global.String()+" server-wide")
+	}
+	if len(parts) == 0 {
+		return "None"
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+func formatAccess(access permissions.CommandAccess) string {
+	msg := "Everyone"
+	if access.Tier > permissions.TierEveryone {
+		tier := access.Tier.String()
+		msg = strings.ToUpper(tier[:1]) + tier[1:] + " tier"
+	}
+	if access.Permissions != 0 {
+		msg += ", with `" + strings.Join(permissions.PermissionNames(access.Permissions), "`, `") + "`"
+	}
+	if len(access.AllowRoles) > 0 {
+		msg += "\nAlso allowed: <@&" + strings.Join(access.AllowRoles, ">, <@&") + ">"
+	}
+	if len(access.DenyRoles) > 0 {
+		msg += "\nDenied: <@&" + strings.Join(access.DenyRoles, ">, <@&") + ">"
+	}
+
+	return msg
+}
+
+// commandDetails describes a single command: usage, arguments, aliases, examples, access and cooldown.
+func (c *Commands) commandDetails(m *ScuzzyContext, cmd *ScuzzyCommand) *discordgo.MessageEmbed {
+	key := commandKey(m.Config)
+
+	desc := cmd.Description + "\n\n"
+	desc += "**Usage**: `" + c.CommandUsage(m.Config, cmd) + "`\n"
+
+	if len(cmd.Arguments) > 0 {
+		desc += "\n**Arguments**\n"
+		for _, arg := range cmd.Arguments {
+			opt := "optional"
+			if arg.Required {
+				opt = "required"
+			}
+			desc += "`" + arg.Name + "` (" + arg.Type.String() + ", " + opt + ") - " + arg.Description
+			if choices := arg.GuildChoices(m.Config); len(choices) > 0 {
+				desc += ". One of `" + strings.Join(choices, "`, `") + "`"
+			}
+			desc += "\n"
+		}
+	}
+
+	if len(cmd.Subcommands) > 0 {
+		desc += "\n**Subcommands**\n"
+		for _, sub := range cmd.Subcommands {
+			if sub.Hidden {
+				continue
+			}
+			desc += "`" + sub.Path + "` - " + sub.Description + "\n"
+		}
+	}
+
+	if len(cmd.Aliases) > 0 {
+		desc += "\n**Aliases**: `" + strings.Join(cmd.Aliases, "`, `") + "`\n"
+	}
+
+	if len(cmd.Examples) > 0 {
+		desc += "\n**Examples**\n"
+		for _, example := range cmd.Examples {
+			desc += "`" + key + example + "`\n"
+		}
+	}
+
+	access := m.Permissions.CommandAccess(c.commandMatcher(cmd), permissions.CommandAccess{
+		Tier:        cmd.Tier,
+		Permissions: cmd.Permissions,
+	})
+	desc += "\n**Access**: " + formatAccess(access) + "\n"
+	desc += "**Cooldown**: " + formatCooldown(c.commandCooldown(m.Config, cmd)) + "\n"
+
+	return c.CreateDefinedEmbed("Help ("+key+cmd.Path+")", desc, "", m.Author)
+}
+
+func (c *Commands) handleHelp(s discord.Session, m *ScuzzyContext) error {
+	// Nothing left once the prefix is gone, e.g. `help .`, falls back to the listing
+	fields := strings.Fields(trimCommandKey(m.Config, m.Args.String("command")))
+	if len(fields) > 0 {
+		cmd, ok := c.FindCommand(fields[0])
+		if !ok || cmd.Hidden || !m.CommandEnabled(&cmd) {
+			return errors.New("Unknown command `" + fields[0] + "`")
+		}
+		sub := cmd.ResolveSubcommand(fields[1:])
+
+		_, err := c.SendEmbed(s, m, c.commandDetails(m, sub))
+		if err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	pages := c.helpPages(s, m)
+
+	_, err := c.SendEmbedComponents(s, m, c.helpPageEmbed(s, m, pages, 0), pageButtons(helpButtonPrefix, 0, len(pages), m.Author.ID))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// handleHelpButton turns the page of a help menu. Only the user who asked for help can page it.
+func (c *Commands) handleHelpButton(s discord.Session, i *discordgo.InteractionCreate, guild *Guild) error {
+	parts := strings.Split(strings.TrimPrefix(i.MessageComponentData().CustomID, helpButtonPrefix), ":")
+	if len(parts) != 2 {
+		return errors.New("Malformed help button")
+	}
+	page, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return err
+	}
+
+	if parts[1] != i.Member.User.ID {
+		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "This help menu belongs to someone else, use `" + commandKey(guild.Config) + "help` to get your own.",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+	}
+
+	m := &ScuzzyContext{
+		MessageCreate: &discordgo.MessageCreate{
+			Message: &discordgo.Message{
+				ChannelID: i.ChannelID,
+				GuildID:   i.GuildID,
+				Author:    i.Member.User,
+				Member:    i.Member,
+			},
+		},
+		Guild: guild,
+	}
+
+	pages := c.helpPages(s, m)
+	if page < 0 || page >= len(pages) {
+		page = 0
+	}
+
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{c.helpPageEmbed(s, m, pages, page)},
+			Components: pageButtons(helpButtonPrefix, page, len(pages), i.Member.User.ID),
+		},
+	})
+}
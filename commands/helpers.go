@@ -1,9 +1,11 @@
 package commands
 
 import (
+	"time"
+
 	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/discord"
 	"github.com/foxtrot/scuzzy/models"
-	"time"
 )
 
 func (c *Commands) CreateDefinedEmbed(title string, desc string, status string, user *discordgo.User) *discordgo.MessageEmbed {
@@ -96,3 +98,76 @@ func (c *Commands) CreateCustomEmbed(embedData *models.CustomEmbed) *discordgo.M
 
 	return &msg
 }
+
+// requestOptions ties requests made for a command to its deadline.
+func (m *ScuzzyContext) requestOptions() []discordgo.RequestOption {
+	if m.Context == nil {
+		return nil
+	}
+
+	return []discordgo.RequestOption{discordgo.WithContext(m.Context)}
+}
+
+// SendComplex sends a reply following the command's response policy. The other Send helpers use it.
+func (c *Commands) SendComplex(s discord.Session, m *ScuzzyContext, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	var r *discordgo.Message
+	var err error
+
+	if m.Interaction != nil {
+		r, err = s.FollowupMessageCreate(m.Interaction, true, &discordgo.WebhookParams{
+			Content:         data.Content,
+			Embeds:          data.Embeds,
+			Components:      data.Components,
+			AllowedMentions: data.AllowedMentions,
+			Flags:           m.responseFlags(),
+		}, m.requestOptions()...)
+	} else {
+		var channelID string
+		channelID, err = c.replyChannel(s, m)
+		if err != nil {
+			return nil, err
+		}
+
+		r, err = s.ChannelMessageSendComplex(channelID, data, m.requestOptions()...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.applyResponse(s, m, r)
+
+	return r, nil
+}
+
+func (c *Commands) SendMessage(s discord.Session, m *ScuzzyContext, content string) (*discordgo.Message, error) {
+	return c.SendComplex(s, m, &discordgo.MessageSend{
+		Content: content,
+	})
+}
+
+func (c *Commands) SendEmbed(s discord.Session, m *ScuzzyContext, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return c.SendComplex(s, m, &discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{embed},
+	})
+}
+
+// SendEmbedComponents sends an embed along with message components such as buttons.
+func (c *Commands) SendEmbedComponents(s discord.Session, m *ScuzzyContext, embed *discordgo.MessageEmbed, components []discordgo.MessageComponent) (*discordgo.Message, error) {
+	return c.SendComplex(s, m, &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{embed},
+		Components: components,
+	})
+}
+
+func (c *Commands) DeleteMessage(s discord.Session, m *ScuzzyContext, messageID string) error {
+	if m.Interaction != nil {
+		// There is no invoking message to delete for slash commands
+		if messageID == m.ID {
+			return nil
+		}
+
+		return s.FollowupMessageDelete(m.Interaction, messageID, m.requestOptions()...)
+	}
+
+	return s.ChannelMessageDelete(m.ChannelID, messageID, m.requestOptions()...)
+}
@@ -0,0 +1,249 @@
+package commands
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/discord"
+	"github.com/foxtrot/scuzzy/models"
+	"github.com/foxtrot/scuzzy/permissions"
+)
+
+func (c *Commands) buildApplicationCommand(conf *models.Configuration, cmd ScuzzyCommand) *discordgo.ApplicationCommand {
+	// Discord requires a description, hidden commands fall back to their name
+	desc := cmd.Description
+	if len(desc) == 0 {
+		desc = cmd.Name
+	}
+
+	dmPermission := false
+	appCmd := &discordgo.ApplicationCommand{
+		Name:         cmd.Name,
+		Description:  desc,
+		DMPermission: &dmPermission,
+	}
+
+	// Hide staff commands from regular members by default, the command's access rules still apply
+	if cmd.Tier > permissions.TierEveryone || cmd.Permissions != 0 {
+		var staffPermissions int64 = discordgo.PermissionManageMessages
+		if cmd.Permissions != 0 {
+			staffPermissions = cmd.Permissions
+		}
+		appCmd.DefaultMemberPermissions = &staffPermissions
+	}
+
+	appCmd.Options = buildApplicationCommandOptions(conf, &cmd)
+
+	return appCmd
+}
+
+func buildApplicationCommandOptions(conf *models.Configuration, cmd *ScuzzyCommand) []*discordgo.ApplicationCommandOption {
+	var opts []*discordgo.ApplicationCommandOption
+
+	// Subcommands and groups are options of their parent
+	for k := range cmd.Subcommands {
+		sub := &cmd.Subcommands[k]
+
+		optType := discordgo.ApplicationCommandOptionSubCommand
+		if len(sub.Subcommands) > 0 {
+			optType = discordgo.ApplicationCommandOptionSubCommandGroup
+		}
+
+		opts = append(opts, &discordgo.ApplicationCommandOption{
+			Type:        optType,
+			Name:        sub.Name,
+			Description: sub.Description,
+			Options:     buildApplicationCommandOptions(conf, sub),
+		})
+	}
+
+	for _, arg := range cmd.Arguments {
+		optType := arg.Type.OptionType()
+		// Slash options hold one value, variadic arguments take theirs as text
+		if arg.Variadic {
+			optType = discordgo.ApplicationCommandOptionString
+		}
+
+		opt := &discordgo.ApplicationCommandOption{
+			Type:        optType,
+			Name:        arg.Name,
+			Description: arg.Description,
+			Required:    arg.Required,
+		}
+
+		for k, choice := range arg.GuildChoices(conf) {
+			// Discord allows at most 25 choices per option
+			if k == 25 {
+				break
+			}
+			opt.Choices = append(opt.Choices, &discordgo.ApplicationCommandOptionChoice{
+				Name:  choice,
+				Value: choice,
+			})
+		}
+
+		opts = append(opts, opt)
+	}
+
+	return opts
+}
+
+// PublishCommands overwrites a guild's slash commands with the registered commands.
+func (c *Commands) PublishCommands(s discord.Session, g *Guild) error {
+	keys := make([]int, 0, len(c.ScuzzyCommandsByIndex))
+	for k := range c.ScuzzyCommandsByIndex {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	var appCmds []*discordgo.ApplicationCommand
+	for _, k := range keys {
+		cmd := c.ScuzzyCommandsByIndex[k]
+		if !g.CommandEnabled(&cmd) {
+			continue
+		}
+		appCmds = append(appCmds, c.buildApplicationCommand(g.Config, cmd))
+	}
+
+	_, err := s.ApplicationCommandBulkOverwrite(s.GetState().User.ID, g.Config.GuildID, appCmds)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[*] Published %d slash commands to %s\n", len(appCmds), g.Config.GuildName)
+
+	return nil
+}
+
+func interactionOptionString(opt *discordgo.ApplicationCommandInteractionDataOption) string {
+	switch opt.Type {
+	case discordgo.ApplicationCommandOptionUser:
+		return "<@" + opt.UserValue(nil).ID + ">"
+	case discordgo.ApplicationCommandOptionChannel:
+		return "<#" + opt.ChannelValue(nil).ID + ">"
+	case discordgo.ApplicationCommandOptionRole:
+		return "<@&" + opt.RoleValue(nil, "").ID + ">"
+	case discordgo.ApplicationCommandOptionInteger:
+		return strconv.FormatInt(opt.IntValue(), 10)
+	case discordgo.ApplicationCommandOptionNumber:
+		return strconv.FormatFloat(opt.FloatValue(), 'f', -1, 64)
+	case discordgo.ApplicationCommandOptionBoolean:
+		return strconv.FormatBool(opt.BoolValue())
+	default:
+		return opt.StringValue()
+	}
+}
+
+func (c *Commands) ProcessInteraction(s discord.Session, i *discordgo.InteractionCreate) error {
+	if i.Type != discordgo.InteractionApplicationCommand && i.Type != discordgo.InteractionMessageComponent {
+		return nil
+	}
+
+	// Ignore Direct Messages
+	if i.Member == nil {
+		return nil
+	}
+
+	guild, ok := c.Guild(i.GuildID)
+	if !ok {
+		return nil
+	}
+
+	if i.Type == discordgo.InteractionMessageComponent {
+		return c.ProcessComponent(s, i, guild)
+	}
+
+	data := i.ApplicationCommandData()
+	topCmd, ok := c.ScuzzyCommands[data.Name]
+	if !ok || !guild.CommandEnabled(&topCmd) {
+		return nil
+	}
+
+	// Walk down to the invoked subcommand
+	cmd := &topCmd
+	options := data.Options
+	for len(options) == 1 && (options[0].Type == discordgo.ApplicationCommandOptionSubCommand || options[0].Type == discordgo.ApplicationCommandOptionSubCommandGroup) {
+		sub := cmd.FindSubcommand(options[0].Name)
+		if sub == nil {
+			return nil
+		}
+		cmd = sub
+		options = options[0].Options
+	}
+
+	response := c.commandResponse(guild.Config, cmd)
+	var flags discordgo.MessageFlags
+	if response.Ephemeral || response.DM {
+		flags = discordgo.MessageFlagsEphemeral
+	}
+
+	// Acknowledge straight away, handlers may take longer than Discord's response window
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: flags,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Rebuild the equivalent text command so it is parsed like any other
+	opts := make(map[string]*discordgo.ApplicationCommandInteractionDataOption)
+	for _, opt := range options {
+		opts[opt.Name] = opt
+	}
+
+	args := []string{cmd.Path}
+	for _, arg := range cmd.Arguments {
+		opt, ok := opts[arg.Name]
+		if !ok {
+			continue
+		}
+
+		v := interactionOptionString(opt)
+		if arg.Type != ArgRest && !arg.Variadic && strings.ContainsAny(v, " \t\n") {
+			v = "\"" + v + "\""
+		}
+		args = append(args, v)
+	}
+
+	m := &discordgo.MessageCreate{
+		Message: &discordgo.Message{
+			ID:        i.ID,
+			ChannelID: i.ChannelID,
+			GuildID:   i.GuildID,
+			Content:   commandKey(guild.Config) + strings.Join(args, " "),
+			Author:    i.Member.User,
+			Member:    i.Member,
+		},
+	}
+
+	return c.RunCommand(s, &ScuzzyContext{
+		MessageCreate: m,
+		Guild:         guild,
+		Command:       cmd,
+		Interaction:   i.Interaction,
+		Response:      response,
+		Invocation:    strings.Join(args, " "),
+	})
+}
+
+// ProcessComponent handles button presses on messages Scuzzy sent.
+func (c *Commands) ProcessComponent(s discord.Session, i *discordgo.InteractionCreate, guild *Guild) error {
+	customID := i.MessageComponentData().CustomID
+
+	switch {
+	case strings.HasPrefix(customID, helpButtonPrefix):
+		return c.handleHelpButton(s, i, guild)
+	case strings.HasPrefix(customID, massbanButtonPrefix):
+		return c.handleMassbanButton(s, i, guild)
+	case strings.HasPrefix(customID, caseButtonPrefix):
+		return c.handleCasesButton(s, i, guild)
+	}
+
+	return nil
+}
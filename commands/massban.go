@@ -0,0 +1,340 @@
+package commands
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/discord"
+)
+
+const (
+	massbanButtonPrefix = "massban:"
+	// maxMassban is the most users one massban can take.
+	maxMassban = 1000
+	// maxMassbanFile is the largest ID list attachment read.
+	maxMassbanFile = 1 << 20
+	// massbanExpiry is how long a massban waits to be confirmed.
+	massbanExpiry = 5 * time.Minute
+	// massbanProgress is how many bans go by between progress updates.
+	massbanProgress = 10
+)
+
+// pendingMassban is a massban waiting for its moderator to confirm it.
+type pendingMassban struct {
+	GuildID     string
+	ModeratorID string
+	Reason      string
+	UserIDs     []string
+	Expires     time.Time
+}
+
+// parseMassbanFile picks every ID or mention out of an attached list, ignoring anything else.
+func parseMassbanFile(text string) []string {
+	var ids []string
+
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return unicode.IsSpace(r) || r == ',' || r == ';'
+	})
+	for _, field := range fields {
+		if id, ok := mentionID(field, userMentionRegex); ok {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+func fetchAttachment(m *ScuzzyContext, a *discordgo.MessageAttachment) (string, error) {
+	if a.Size > maxMassbanFile {
+		return "", errors.New("`" + a.Filename + "` is too big, attach at most 1MB of IDs.")
+	}
+
+	req, err := http.NewRequestWithContext(m.Context, http.MethodGet, a.URL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New("Couldn't download `" + a.Filename + "`: " + resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxMassbanFile))
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+func massbanButtons(id string) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Ban Them",
+					Style:    discordgo.DangerButton,
+					CustomID: massbanButtonPrefix + "confirm:" + id,
+				},
+				discordgo.Button{
+					Label:    "Cancel",
+					Style:    discordgo.SecondaryButton,
+					CustomID: massbanButtonPrefix + "cancel:" + id,
+				},
+			},
+		},
+	}
+}
+
+func listUserIDs(ids []string, max int) string {
+	msg := ""
+	for k, id := range ids {
+		if k == max {
+			msg += "...and `" + strconv.Itoa(len(ids)-max) + "` more\n"
+			break
+		}
+		msg += "<@" + id + "> `" + id + "`\n"
+	}
+
+	return msg
+}
+
+func (c *Commands) handleMassban(s discord.Session, m *ScuzzyContext) error {
+	var ids []string
+	for _, user := range m.Args.Users("users") {
+		ids = append(ids, user.ID)
+	}
+	reason := m.Args.String("reason")
+
+	if m.Message != nil {
+		for _, a := range m.Message.Attachments {
+			text, err := fetchAttachment(m, a)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, parseMassbanFile(text)...)
+		}
+	}
+
+	// Never the moderator or Scuzzy, nobody they can't moderate, and each user once
+	seen := map[string]bool{m.Author.ID: true, s.GetState().User.ID: true}
+	var targets []string
+	protected := 0
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if member, err := s.GetState().Member(m.GuildID, id); err == nil {
+			if ok, _ := m.Permissions.CheckTarget(s, m.GuildID, m.Member, member); !ok {
+				protected++
+				continue
+			}
+		}
+		targets = append(targets, id)
+	}
+
+	if len(targets) == 0 && protected > 0 {
+		return errors.New("You can't moderate any of those users.")
+	}
+	if len(targets) == 0 {
+		return errors.New("Give me user IDs or mentions to ban, or attach a text file of IDs.")
+	}
+	if len(targets) > maxMassban {
+		return errors.New("You can only massban up to " + strconv.Itoa(maxMassban) + " users at a time.")
+	}
+
+	id := newTaskID()
+	c.massbanMu.Lock()
+	for k, mb := range c.massbans {
+		if time.Now().After(mb.Expires) {
+			delete(c.massbans, k)
+		}
+	}
+	c.massbans[id] = pendingMassban{
+		GuildID:     m.GuildID,
+		ModeratorID: m.Author.ID,
+		Reason:      reason,
+		UserIDs:     targets,
+		Expires:     time.Now().Add(massbanExpiry),
+	}
+	c.massbanMu.Unlock()
+
+	msg := "About to ban `" + strconv.Itoa(len(targets)) + "` users, whether or not they are in the server:\n"
+	msg += listUserIDs(targets, 20)
+	if protected > 0 {
+		msg += "Skipping `" + strconv.Itoa(protected) + "` users you can't moderate.\n"
+	}
+	if len(reason) > 0 {
+		msg += "Reason: `" + reason + "`\n"
+	}
+	msg += "\nConfirm within " + formatDuration(massbanExpiry) + "."
+
+	eMsg := c.CreateDefinedEmbed("Massban", msg, "error", m.Author)
+	_, err := c.SendEmbedComponents(s, m, eMsg, massbanButtons(id))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// handleMassbanButton confirms or cancels a massban. Only the moderator who started it can.
+func (c *Commands) handleMassbanButton(s discord.Session, i *discordgo.InteractionCreate, guild *Guild) error {
+	parts := strings.Split(strings.TrimPrefix(i.MessageComponentData().CustomID, massbanButtonPrefix), ":")
+	if len(parts) != 2 {
+		return errors.New("Malformed massban button")
+	}
+
+	c.massbanMu.Lock()
+	mb, ok := c.massbans[parts[1]]
+	if ok && mb.ModeratorID != i.Member.User.ID {
+		c.massbanMu.Unlock()
+		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "This massban belongs to someone else.",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+	}
+	delete(c.massbans, parts[1])
+	c.massbanMu.Unlock()
+
+	status := ""
+	switch {
+	case !ok || time.Now().After(mb.Expires):
+		status = "This massban has expired, nobody was banned."
+	case parts[0] != "confirm":
+		status = "Massban cancelled, nobody was banned."
+	}
+	if len(status) > 0 {
+		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Embeds:     []*discordgo.MessageEmbed{c.CreateDefinedEmbed("Massban", status, "", i.Member.User)},
+				Components: []discordgo.MessageComponent{},
+			},
+		})
+	}
+
+	total := strconv.Itoa(len(mb.UserIDs))
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{c.CreateDefinedEmbed("Massban", "Massban confirmed, banning `"+total+"` users.", "", i.Member.User)},
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Progress goes in a message of its own, the interaction's token expires long before a big list is done
+	run := &massbanRun{pendingMassban: mb, ChannelID: i.ChannelID, Moderator: i.Member.User}
+	msg, err := s.ChannelMessageSendEmbed(run.ChannelID, c.massbanProgress(run))
+	if err != nil {
+		return err
+	}
+	run.MessageID = msg.ID
+
+	log.Printf("[*] Massbanning %d users in %s for %s\n", len(mb.UserIDs), mb.GuildID, run.Moderator.Username)
+	c.scheduler.After(0, func() {
+		c.runMassban(s, run)
+	})
+
+	return nil
+}
+
+// massbanRun is a confirmed massban working through its list.
+type massbanRun struct {
+	pendingMassban
+
+	// ChannelID and MessageID are the message progress is posted in.
+	ChannelID string
+	MessageID string
+	Moderator *discordgo.User
+
+	Done   int
+	Failed []string
+}
+
+func (c *Commands) massbanProgress(run *massbanRun) *discordgo.MessageEmbed {
+	total := strconv.Itoa(len(run.UserIDs))
+	banned := strconv.Itoa(run.Done - len(run.Failed))
+
+	if run.Done < len(run.UserIDs) {
+		msg := "Banned `" + banned + "` of `" + total + "` users so far..."
+		return c.CreateDefinedEmbed("Massban", msg, "", run.Moderator)
+	}
+
+	msg := "Banned `" + banned + "` of `" + total + "` users.\n"
+	status := "success"
+	if len(run.Failed) > 0 {
+		msg += "\nCouldn't ban:\n" + listUserIDs(run.Failed, 20)
+		status = "error"
+	}
+
+	return c.CreateDefinedEmbed("Massban", msg, status, run.Moderator)
+}
+
+// runMassban bans the next few users of a massban, updates its progress and schedules the rest, so a
+// long list doesn't hold up a worker. Requests go one at a time through discordgo's rate limiter, so
+// a long list is paced out rather than rejected, and every ban opens its own case.
+func (c *Commands) runMassban(s discord.Session, run *massbanRun) {
+	reason := "Massban"
+	if len(run.Reason) > 0 {
+		reason += ": " + run.Reason
+	}
+
+	end := run.Done + massbanProgress
+	if end > len(run.UserIDs) {
+		end = len(run.UserIDs)
+	}
+	for _, id := range run.UserIDs[run.Done:end] {
+		err := c.Actions.BanUser(s, run.GuildID, id, run.ModeratorID, 0, reason)
+		if err != nil {
+			log.Println("[!] Error (Massban): " + id + ": " + err.Error())
+			run.Failed = append(run.Failed, id)
+		}
+	}
+	run.Done = end
+
+	if run.Done < len(run.UserIDs) {
+		_, err := s.ChannelMessageEditEmbed(run.ChannelID, run.MessageID, c.massbanProgress(run))
+		if err != nil {
+			log.Println("[!] Error (Massban): " + err.Error())
+		}
+
+		c.scheduler.After(0, func() {
+			c.runMassban(s, run)
+		})
+		return
+	}
+
+	// The report is posted fresh rather than edited in so the moderator sees it
+	err := s.ChannelMessageDelete(run.ChannelID, run.MessageID)
+	if err != nil && !isUnknownMessage(err) {
+		log.Println("[!] Error (Massban): " + err.Error())
+	}
+	_, err = s.ChannelMessageSendComplex(run.ChannelID, &discordgo.MessageSend{
+		Content: "<@" + run.ModeratorID + ">",
+		Embeds:  []*discordgo.MessageEmbed{c.massbanProgress(run)},
+	})
+	if err != nil {
+		log.Println("[!] Error (Massban): " + err.Error())
+	}
+}
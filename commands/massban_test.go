@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// press clicks a button on a message Scuzzy sent, as member.
+func (b *testBot) press(member *discordgo.Member, customID string) *discordgo.InteractionResponse {
+	b.t.Helper()
+
+	b.nextID++
+	i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		ID:        "interaction-" + customID,
+		Type:      discordgo.InteractionMessageComponent,
+		GuildID:   testGuildID,
+		ChannelID: testChannelID,
+		Member:    member,
+		Data:      discordgo.MessageComponentInteractionData{CustomID: customID, ComponentType: discordgo.ButtonComponent},
+	}}
+	b.s.Calls = nil
+	b.HandleEvent(b.s, i)
+	b.Wait()
+
+	calls := b.s.CallsTo("InteractionRespond")
+	if len(calls) != 1 {
+		b.t.Fatalf("expected one response to the button, got %d", len(calls))
+	}
+
+	return calls[0].Args[1].(*discordgo.InteractionResponse)
+}
+
+// startMassban runs a massban and returns its ID from the confirm button.
+func (b *testBot) startMassban(content string) string {
+	b.t.Helper()
+
+	b.run(content)
+	msg := b.lastSent()
+	if len(msg.Components) == 0 {
+		b.t.Fatalf("expected confirm buttons, got %+v", msg)
+	}
+	button := msg.Components[0].(discordgo.ActionsRow).Components[0].(discordgo.Button)
+
+	return strings.TrimPrefix(button.CustomID, massbanButtonPrefix+"confirm:")
+}
+
+func TestMassbanConfirm(t *testing.T) {
+	b := newTestBot(t, nil)
+	other := b.addMember("500000000000000003", "other", testAdminRole)
+
+	id := b.startMassban(".massban 700000000000000001 <@700000000000000002> " + testUserID + " " + other.User.ID + " " + testAdminID + " Raid")
+	b.massbanMu.Lock()
+	mb := b.massbans[id]
+	b.massbanMu.Unlock()
+	if len(mb.UserIDs) != 3 || mb.Reason != "Raid" {
+		t.Fatalf("expected three users, skipping the moderator and other admins, got %+v", mb)
+	}
+
+	// Only the moderator who started it can confirm it
+	resp := b.press(b.member(testUserID), massbanButtonPrefix+"confirm:"+id)
+	if resp.Data.Flags != discordgo.MessageFlagsEphemeral || len(b.s.Bans[testGuildID]) != 0 {
+		t.Fatalf("someone else confirmed the massban: %+v", resp.Data)
+	}
+
+	b.press(b.admin, massbanButtonPrefix+"confirm:"+id)
+	if !eventually(t, func() bool {
+		b.s.Lock()
+		defer b.s.Unlock()
+		return len(b.s.Bans[testGuildID]) == 3
+	}) {
+		t.Fatalf("expected three bans, got %v", b.s.Bans[testGuildID])
+	}
+	b.Wait()
+
+	for _, uID := range mb.UserIDs {
+		cases, _ := b.UserCases(testGuildID, uID)
+		if len(cases) != 1 || cases[0].Reason != "Massban: Raid" {
+			t.Errorf("expected a case for %s, got %+v", uID, cases)
+		}
+	}
+
+	// Confirming twice does nothing
+	resp = b.press(b.admin, massbanButtonPrefix+"confirm:"+id)
+	if !strings.Contains(resp.Data.Embeds[0].Description, "expired") {
+		t.Errorf("expected the massban to be gone, got %+v", resp.Data.Embeds[0])
+	}
+}
+
+func TestMassbanCancel(t *testing.T) {
+	b := newTestBot(t, nil)
+
+	id := b.startMassban(".massban 700000000000000001 700000000000000002")
+	resp := b.press(b.admin, massbanButtonPrefix+"cancel:"+id)
+	if !strings.Contains(resp.Data.Embeds[0].Description, "cancelled") || len(resp.Data.Components) != 0 {
+		t.Errorf("expected the massban to be cancelled, got %+v", resp.Data)
+	}
+
+	// Confirmations that come too late are refused
+	id = b.startMassban(".massban 700000000000000001")
+	b.massbanMu.Lock()
+	mb := b.massbans[id]
+	mb.Expires = time.Now().Add(-time.Second)
+	b.massbans[id] = mb
+	b.massbanMu.Unlock()
+	resp = b.press(b.admin, massbanButtonPrefix+"confirm:"+id)
+	if !strings.Contains(resp.Data.Embeds[0].Description, "expired") {
+		t.Errorf("expected the massban to have expired, got %+v", resp.Data.Embeds[0])
+	}
+
+	if len(b.s.Bans[testGuildID]) != 0 {
+		t.Errorf("nobody should have been banned, got %v", b.s.Bans[testGuildID])
+	}
+}
@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/discord"
+	"github.com/foxtrot/scuzzy/models"
+	"github.com/foxtrot/scuzzy/templates"
+)
+
+// cannedMessages are Scuzzy's stock replies, which guilds can override under "messages".
+var cannedMessages = []string{
+	"ping", "no", "error", "cooldown", "unknown_command", "colour_set", "role_join", "role_leave",
+	"kick", "ban", "unban", "timeout", "untimeout", "warn", "warn_dm", "delwarn", "clearwarns",
+	"ignore", "unignore", "slowmode", "purge",
+}
+
+func init() {
+	templates.RegisterMessages(cannedMessages...)
+}
+
+// guildTemplates are a guild's message templates, compiled once each time its configuration is set.
+type guildTemplates struct {
+	welcome  *templates.Template
+	rules    *templates.Template
+	messages map[string]*templates.Template
+}
+
+// compileTemplate compiles a configured template, returning nil if there is nothing to send. Templates
+// are validated when the configuration loads, so errors here are only logged.
+func compileTemplate(name string, mt models.MessageTemplate) *templates.Template {
+	if !hasTemplate(mt) {
+		return nil
+	}
+
+	t, err := templates.Compile(mt)
+	if err != nil {
+		log.Println("[!] Error (Template " + name + "): " + err.Error())
+		return nil
+	}
+
+	return t
+}
+
+func compileTemplates(conf *models.Configuration) guildTemplates {
+	gt := guildTemplates{
+		welcome:  compileTemplate("welcome_text", conf.WelcomeText),
+		rules:    compileTemplate("rules_text", conf.RulesText),
+		messages: make(map[string]*templates.Template),
+	}
+	for key, mt := range conf.Messages {
+		if t := compileTemplate("messages."+key, mt); t != nil {
+			gt.messages[key] = t
+		}
+	}
+
+	return gt
+}
+
+// templateData gathers what a message template can refer to.
+func (c *Commands) templateData(s discord.Session, guildID string, channelID string, user *discordgo.User, member *discordgo.Member, vars map[string]string) templates.Data {
+	d := templates.Data{
+		User:   user,
+		Member: member,
+		Vars:   vars,
+	}
+
+	if g, err := s.GetState().Guild(guildID); err == nil {
+		d.Guild = g
+		d.MemberCount = g.MemberCount
+	}
+	if ch, err := s.GetState().Channel(channelID); err == nil {
+		d.Channel = ch
+	}
+
+	return d
+}
+
+func (c *Commands) contextTemplateData(s discord.Session, m *ScuzzyContext, vars map[string]string) templates.Data {
+	return c.templateData(s, m.GuildID, m.ChannelID, m.Author, m.Member, vars)
+}
+
+// SendCanned sends one of Scuzzy's stock replies, or the guild's override of it under "messages".
+// vars are available to the override as .Vars.
+func (c *Commands) SendCanned(s discord.Session, m *ScuzzyContext, key string, vars map[string]string, fallback *discordgo.MessageSend) (*discordgo.Message, error) {
+	t, ok := m.templates.messages[key]
+	if !ok {
+		return c.SendComplex(s, m, fallback)
+	}
+
+	data, err := t.Render(c.contextTemplateData(s, m, vars))
+	if err != nil {
+		return nil, err
+	}
+
+	return c.SendComplex(s, m, data)
+}
+
+// SendCannedEmbed is SendCanned for replies that default to an embed.
+func (c *Commands) SendCannedEmbed(s discord.Session, m *ScuzzyContext, key string, vars map[string]string, fallback *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return c.SendCanned(s, m, key, vars, &discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{fallback},
+	})
+}
+
+// hasTemplate reports whether a template has anything to send.
+func hasTemplate(mt models.MessageTemplate) bool {
+	return len(strings.TrimSpace(mt.Content)) > 0 || mt.Embed != nil
+}
+
+// NotifyUser DMs a user one of Scuzzy's stock notices, or the guild's override of it under "messages".
+func (c *Commands) NotifyUser(s discord.Session, guildID string, user *discordgo.User, key string, vars map[string]string, fallback *discordgo.MessageEmbed) error {
+	data := &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{fallback}}
+
+	if guild, ok := c.Guild(guildID); ok {
+		if t, ok := guild.templates.messages[key]; ok {
+			var err error
+			data, err = t.Render(c.templateData(s, guildID, "", user, nil, vars))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	userChannel, err := s.UserChannelCreate(user.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.ChannelMessageSendComplex(userChannel.ID, data)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package commands
+
+import "testing"
+
+func TestCannedMessageOverride(t *testing.T) {
+	b := newTestBot(t, map[string]interface{}{
+		"messages": map[string]interface{}{
+			"ping": "Pong from {{.Guild.Name}}",
+		},
+		"welcome_text": map[string]interface{}{"embed": map[string]interface{}{"title": "Welcome {{.User.Username}}", "description": "Read {{channel \"general\"}}"}},
+	})
+
+	b.run(".ping")
+	if msg := b.lastSent(); msg.Content != "Pong from Test" || len(msg.Embeds) != 0 {
+		t.Errorf("expected the override, got %+v", msg)
+	}
+
+	b.join("500000000000000003")
+	dms := b.s.Sent["dm-500000000000000003"]
+	if len(dms) != 1 || len(dms[0].Embeds) != 1 || dms[0].Embeds[0].Title != "Welcome new" || dms[0].Embeds[0].Description != "Read <#"+testChannelID+">" {
+		t.Errorf("expected the welcome embed, got %+v", dms)
+	}
+}
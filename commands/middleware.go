@@ -0,0 +1,273 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/foxtrot/scuzzy/discord"
+	"github.com/foxtrot/scuzzy/permissions"
+)
+
+// ScuzzyMiddleware wraps a handler with behaviour that runs around every command.
+type ScuzzyMiddleware func(next ScuzzyHandler) ScuzzyHandler
+
+func (c *Commands) Use(mw ...ScuzzyMiddleware) {
+	c.middleware = append(c.middleware, mw...)
+}
+
+func (c *Commands) buildChain(h ScuzzyHandler) ScuzzyHandler {
+	// The first middleware registered is the outermost
+	for k := len(c.middleware) - 1; k >= 0; k-- {
+		h = c.middleware[k](h)
+	}
+
+	return h
+}
+
+func (c *Commands) RecoverMiddleware(next ScuzzyHandler) ScuzzyHandler {
+	return func(s discord.Session, m *ScuzzyContext) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("[!] Command %s panicked: %v\n%s", m.Command.Path, r, debug.Stack())
+				c.logGuildError(s, m.GuildID, "Panic ("+m.Command.Path+")", fmt.Errorf("%v\nRequested by <@%s> in <#%s>", r, m.Author.ID, m.ChannelID))
+				err = errors.New("Something went wrong running this command.")
+			}
+		}()
+
+		return next(s, m)
+	}
+}
+
+// TimeoutMiddleware gives the command a context that is cancelled once its Timeout passes.
+// Requests made through the Send helpers are abandoned at the deadline.
+func (c *Commands) TimeoutMiddleware(next ScuzzyHandler) ScuzzyHandler {
+	return func(s discord.Session, m *ScuzzyContext) error {
+		timeout := m.Command.Timeout
+		if timeout <= 0 {
+			timeout = defaultCommandTimeout
+		}
+
+		parent := m.Context
+		if parent == nil {
+			parent = context.Background()
+		}
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		defer cancel()
+
+		// Hand the original context back so the error reply isn't cut off as well
+		m.Context = ctx
+		defer func() {
+			m.Context = parent
+		}()
+
+		err := next(s, m)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return errors.New("This command took too long and was stopped.")
+		}
+
+		return err
+	}
+}
+
+func (c *Commands) LoggingMiddleware(next ScuzzyHandler) ScuzzyHandler {
+	return func(s discord.Session, m *ScuzzyContext) error {
+		log.Printf("[*] Running command %s (Requested by %s)\n", m.Command.Path, m.Author.Username)
+
+		err := next(s, m)
+		if err != nil {
+			log.Printf("[!] Command %s (Requested by %s) had error: '%s'\n", m.Command.Path, m.Author.Username, err.Error())
+		}
+
+		return err
+	}
+}
+
+func (c *Commands) IgnoreMiddleware(next ScuzzyHandler) ScuzzyHandler {
+	return func(s discord.Session, m *ScuzzyContext) error {
+		if m.Permissions.CheckIgnoredUser(m.Author) {
+			log.Printf("[*] Ignoring command from ignored user.")
+
+			// Slash commands have already been acknowledged and need an answer
+			if m.Interaction != nil {
+				return errors.New("You are not permitted to use Scuzzy.")
+			}
+			return nil
+		}
+
+		return next(s, m)
+	}
+}
+
+// commandMatcher reports whether a command name from the config refers to cmd, through any alias.
+func (c *Commands) commandMatcher(cmd *ScuzzyCommand) func(string) bool {
+	return func(name string) bool {
+		found, ok := c.ResolveCommand(name)
+		return ok && found.Path == cmd.Path
+	}
+}
+
+func (c *Commands) CanRunCommand(s discord.Session, m *ScuzzyContext, cmd *ScuzzyCommand) (bool, string) {
+	access := m.Permissions.CommandAccess(c.commandMatcher(cmd), permissions.CommandAccess{
+		Tier:        cmd.Tier,
+		Permissions: cmd.Permissions,
+	})
+
+	return m.Permissions.CheckCommandAccess(s, access, m.Member, m.Author.ID, m.ChannelID)
+}
+
+func (c *Commands) PermissionMiddleware(next ScuzzyHandler) ScuzzyHandler {
+	return func(s discord.Session, m *ScuzzyContext) error {
+		ok, reason := c.CanRunCommand(s, m, m.Command)
+		if !ok {
+			log.Printf("[*] User %s was denied command %s: %s\n", m.Author.Username, m.Command.Path, reason)
+
+			// Stay quiet for text commands so staff commands aren't advertised
+			if m.Interaction != nil {
+				return errors.New(reason)
+			}
+			return nil
+		}
+
+		return next(s, m)
+	}
+}
+
+func (c *Commands) checkCommandRestrictions(s discord.Session, m *ScuzzyContext) bool {
+	// Restrictions may be configured against the command path, name or any of its aliases
+	return m.Permissions.CheckCommandRestrictions(s, m.Command.Names(), m.Member, m.ChannelID)
+}
+
+func (c *Commands) RestrictionMiddleware(next ScuzzyHandler) ScuzzyHandler {
+	return func(s discord.Session, m *ScuzzyContext) error {
+		if !c.checkCommandRestrictions(s, m) {
+			return errors.New("This command is not allowed here.")
+		}
+
+		return next(s, m)
+	}
+}
+
+func (c *Commands) CooldownMiddleware(next ScuzzyHandler) ScuzzyHandler {
+	return func(s discord.Session, m *ScuzzyContext) error {
+		ok, err := c.CheckCooldown(s, m)
+		if !ok {
+			return err
+		}
+
+		err = next(s, m)
+
+		// Don't penalise users for typos
+		var argErr *ArgumentError
+		if errors.As(err, &argErr) {
+			c.ResetCooldown(m)
+		}
+
+		return err
+	}
+}
+
+func (c *Commands) ArgumentMiddleware(next ScuzzyHandler) ScuzzyHandler {
+	return func(s discord.Session, m *ScuzzyContext) error {
+		args, err := c.ParseArguments(s, m)
+		if err != nil {
+			return err
+		}
+		m.Args = args
+
+		return next(s, m)
+	}
+}
+
+type CommandMetrics struct {
+	Calls     int
+	Errors    int
+	TotalTime time.Duration
+}
+
+type Metrics struct {
+	sync.Mutex
+	commands map[string]*CommandMetrics
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		commands: make(map[string]*CommandMetrics),
+	}
+}
+
+func (mt *Metrics) Record(name string, took time.Duration, err error) {
+	mt.Lock()
+	defer mt.Unlock()
+
+	cm, ok := mt.commands[name]
+	if !ok {
+		cm = &CommandMetrics{}
+		mt.commands[name] = cm
+	}
+
+	cm.Calls++
+	cm.TotalTime += took
+	if err != nil {
+		cm.Errors++
+	}
+}
+
+func (mt *Metrics) Snapshot() map[string]CommandMetrics {
+	mt.Lock()
+	defer mt.Unlock()
+
+	snap := make(map[string]CommandMetrics)
+	for k, v := range mt.commands {
+		snap[k] = *v
+	}
+
+	return snap
+}
+
+func (c *Commands) MetricsMiddleware(next ScuzzyHandler) ScuzzyHandler {
+	return func(s discord.Session, m *ScuzzyContext) error {
+		t := time.Now()
+		err := next(s, m)
+		c.Metrics.Record(m.Command.Path, time.Since(t), err)
+
+		return err
+	}
+}
+
+func (c *Commands) handleStats(s discord.Session, m *ScuzzyContext) error {
+	snap := c.Metrics.Snapshot()
+
+	names := make([]string, 0, len(snap))
+	for k := range snap {
+		names = append(names, k)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return snap[names[i]].Calls > snap[names[j]].Calls
+	})
+
+	msg := ""
+	for _, name := range names {
+		cm := snap[name]
+		avg := cm.TotalTime / time.Duration(cm.Calls)
+		msg += fmt.Sprintf("`%s` - %d calls, %d errors, %s avg\n", name, cm.Calls, cm.Errors, avg.Round(time.Millisecond))
+	}
+	if len(msg) == 0 {
+		msg = "No commands have been run yet."
+	}
+	msg += "\nTotal commands tracked: `" + strconv.Itoa(len(names)) + "`"
+
+	eMsg := c.CreateDefinedEmbed("Command Stats", msg, "", m.Author)
+	_, err := c.SendEmbed(s, m, eMsg)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
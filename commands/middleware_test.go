@@ -0,0 +1,36 @@
+package commands
+
+import "testing"
+
+func TestDeniedCommandsNotCounted(t *testing.T) {
+	b := newTestBot(t, map[string]interface{}{"ignored_users": []string{testUserID}})
+	bob := b.member(testUserID)
+
+	b.runAs(bob, ".ping")
+	b.runAs(bob, ".info")
+	if snap := b.Metrics.Snapshot(); len(snap) != 0 {
+		t.Errorf("denied and ignored commands shouldn't be counted, got %v", snap)
+	}
+
+	b.run(".ping")
+	if snap := b.Metrics.Snapshot(); snap["ping"].Calls != 1 {
+		t.Errorf("expected one ping counted, got %v", snap)
+	}
+}
+
+func TestCommandPermissionAliases(t *testing.T) {
+	b := newTestBot(t, map[string]interface{}{
+		"command_permissions": []map[string]interface{}{{"command": "colors", "tier": "admin"}},
+	})
+	bob := b.member(testUserID)
+
+	b.runAs(bob, ".colours")
+	if snap := b.Metrics.Snapshot(); snap["colours"].Calls != 0 {
+		t.Errorf("an override on an alias should apply to the command, got %v", snap)
+	}
+
+	b.run(".colours")
+	if snap := b.Metrics.Snapshot(); snap["colours"].Calls != 1 {
+		t.Errorf("expected admins to pass the override, got %v", snap)
+	}
+}
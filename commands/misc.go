@@ -4,42 +4,68 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/url"
-	"os"
 	"reflect"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/config"
+	"github.com/foxtrot/scuzzy/discord"
 	"github.com/foxtrot/scuzzy/models"
+	"github.com/foxtrot/scuzzy/templates"
 )
 
-func (c *Commands) handleSetConfig(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	configArgs := strings.Split(m.Content, " ")
+func (c *Commands) handleSetConfig(s discord.Session, m *ScuzzyContext) error {
+	configKey := m.Args.String("key")
+	configVal := m.Args.String("value")
 
-	if len(configArgs) != 3 {
-		return errors.New("Invalid arguments supplied. Usage: " + c.Config.CommandKey + "setconfig <key> <value>")
+	err := c.UpdateConfig(s, m, func(conf *models.Configuration) error {
+		err := setConfigValue(conf, configKey, configVal)
+		if err != nil {
+			return err
+		}
+
+		return config.Validate(conf)
+	})
+	if err != nil {
+		return err
 	}
 
-	configKey := configArgs[1]
-	configVal := configArgs[2]
+	msgE := c.CreateDefinedEmbed("Set Configuration", "Successfully set property '"+configKey+"'!", "success", m.Author)
+	_, err = c.SendEmbed(s, m, msgE)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
 
-	rt := reflect.TypeOf(c.Config)
+// setConfigValue sets the configuration field with the JSON name key.
+func setConfigValue(conf *models.Configuration, configKey string, configVal string) error {
+	rt := reflect.TypeOf(*conf)
 	for i := 0; i < rt.NumField(); i++ {
 		x := rt.Field(i)
 		tagVal := strings.Split(x.Tag.Get("json"), ",")[0]
 		tagName := x.Name
 
 		if tagVal == configKey {
-			prop := reflect.ValueOf(&c.Config).Elem().FieldByName(tagName)
+			prop := reflect.ValueOf(conf).Elem().FieldByName(tagName)
 
 			switch prop.Interface().(type) {
 			case string:
 				prop.SetString(configVal)
 				break
+			case models.MessageTemplate:
+				// Setting text replaces any embed, embeds are edited in the config file
+				mt := models.MessageTemplate{Content: configVal}
+				_, err := templates.Compile(mt)
+				if err != nil {
+					return err
+				}
+				prop.Set(reflect.ValueOf(mt))
+				break
 			case int:
 				intVal, err := strconv.ParseInt(configVal, 10, 64)
 				if err != nil {
@@ -65,12 +91,6 @@ func (c *Commands) handleSetConfig(s *discordgo.Session, m *discordgo.MessageCre
 				return errors.New("Unsupported key value type")
 			}
 
-			msgE := c.CreateDefinedEmbed("Set Configuration", "Successfully set property '"+configKey+"'!", "success", m.Author)
-			_, err := s.ChannelMessageSendEmbed(m.ChannelID, msgE)
-			if err != nil {
-				return err
-			}
-
 			return nil
 		}
 	}
@@ -78,23 +98,22 @@ func (c *Commands) handleSetConfig(s *discordgo.Session, m *discordgo.MessageCre
 	return errors.New("Unknown key specified")
 }
 
-func (c *Commands) handleGetConfig(s *discordgo.Session, m *discordgo.MessageCreate) error {
+func (c *Commands) handleGetConfig(s discord.Session, m *ScuzzyContext) error {
 	//TODO: Handle printing of slices (check the Type, loop accordingly)
 
-	configArgs := strings.Split(m.Content, " ")
 	configKey := "all"
-	if len(configArgs) == 2 {
-		configKey = configArgs[1]
+	if m.Args.Has("key") {
+		configKey = m.Args.String("key")
 	}
 
 	msg := ""
 
-	rt := reflect.TypeOf(*c.Config)
+	rt := reflect.TypeOf(*m.Config)
 	for i := 0; i < rt.NumField(); i++ {
 		x := rt.Field(i)
 		tagVal := strings.Split(x.Tag.Get("json"), ",")[0]
 		tagName := x.Name
-		prop := reflect.ValueOf(c.Config).Elem().FieldByName(tagName)
+		prop := reflect.ValueOf(m.Config).Elem().FieldByName(tagName)
 
 		if configKey == "all" {
 			switch prop.Interface().(type) {
@@ -106,6 +125,9 @@ func (c *Commands) handleGetConfig(s *discordgo.Session, m *discordgo.MessageCre
 					msg += "`" + tagName + "` - `" + prop.String() + "`\n"
 				}
 				break
+			case models.MessageTemplate:
+				msg += "`" + tagName + "` - Template\n"
+				break
 			default:
 				// Ignore non strings for now...
 				msg += "`" + tagName + "` - Skipped Value\n"
@@ -116,13 +138,19 @@ func (c *Commands) handleGetConfig(s *discordgo.Session, m *discordgo.MessageCre
 				switch prop.Interface().(type) {
 				case string:
 					msg += "`" + tagName + "` - `" + prop.String() + "`\n"
+				case models.MessageTemplate:
+					j, err := json.Marshal(prop.Interface())
+					if err != nil {
+						return err
+					}
+					msg += "`" + tagName + "` - `" + string(j) + "`\n"
 				default:
 					// Ignore non strings for now...
 					msg += "`" + tagName + "` - Skipped Value\n"
 				}
 
 				eMsg := c.CreateDefinedEmbed("Get Configuration", msg, "success", m.Author)
-				_, err := s.ChannelMessageSendEmbed(m.ChannelID, eMsg)
+				_, err := c.SendEmbed(s, m, eMsg)
 				if err != nil {
 					return err
 				}
@@ -137,7 +165,7 @@ func (c *Commands) handleGetConfig(s *discordgo.Session, m *discordgo.MessageCre
 	}
 
 	eMsg := c.CreateDefinedEmbed("Get Configuration", msg, "success", m.Author)
-	_, err := s.ChannelMessageSendEmbed(m.ChannelID, eMsg)
+	_, err := c.SendEmbed(s, m, eMsg)
 	if err != nil {
 		return err
 	}
@@ -145,24 +173,21 @@ func (c *Commands) handleGetConfig(s *discordgo.Session, m *discordgo.MessageCre
 	return nil
 }
 
-func (c *Commands) handleReloadConfig(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	fBuf, err := ioutil.ReadFile(c.Config.ConfigPath)
+func (c *Commands) handleReloadConfig(s discord.Session, m *ScuzzyContext) error {
+	guild, err := c.ReloadGuild(s, m.GuildID)
 	if err != nil {
 		return err
 	}
+	m.Guild = guild
 
-	conf := &models.Configuration{}
-
-	err = json.Unmarshal(fBuf, &conf)
+	// Modules may have been turned on or off
+	err = c.PublishCommands(s, guild)
 	if err != nil {
 		return err
 	}
 
-	c.Config = conf
-	c.Permissions.Config = conf
-
 	eMsg := c.CreateDefinedEmbed("Reload Configuration", "Successfully reloaded configuration from disk", "success", m.Author)
-	_, err = s.ChannelMessageSendEmbed(m.ChannelID, eMsg)
+	_, err = c.SendEmbed(s, m, eMsg)
 	if err != nil {
 		return err
 	}
@@ -170,19 +195,14 @@ func (c *Commands) handleReloadConfig(s *discordgo.Session, m *discordgo.Message
 	return nil
 }
 
-func (c *Commands) handleSaveConfig(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	j, err := json.Marshal(c.Config)
-	if err != nil {
-		return err
-	}
-
-	err = ioutil.WriteFile(c.Config.ConfigPath, j, os.ModePerm)
+func (c *Commands) handleSaveConfig(s discord.Session, m *ScuzzyContext) error {
+	err := c.Store.Save(m.Config)
 	if err != nil {
 		return err
 	}
 
 	eMsg := c.CreateDefinedEmbed("Save Configuration", "Saved runtime configuration successfully", "success", m.Author)
-	_, err = s.ChannelMessageSendEmbed(m.ChannelID, eMsg)
+	_, err = c.SendEmbed(s, m, eMsg)
 	if err != nil {
 		return err
 	}
@@ -190,13 +210,8 @@ func (c *Commands) handleSaveConfig(s *discordgo.Session, m *discordgo.MessageCr
 	return nil
 }
 
-func (c *Commands) handleCat(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	_, err := s.ChannelMessageSend(m.ChannelID, "https://giphy.com/gifs/cat-cute-no-rCxogJBzaeZuU")
-	if err != nil {
-		return err
-	}
-
-	err = s.ChannelMessageDelete(m.ChannelID, m.ID)
+func (c *Commands) handleCat(s discord.Session, m *ScuzzyContext) error {
+	_, err := c.SendCanned(s, m, "no", nil, &discordgo.MessageSend{Content: "https://giphy.com/gifs/cat-cute-no-rCxogJBzaeZuU"})
 	if err != nil {
 		return err
 	}
@@ -204,23 +219,9 @@ func (c *Commands) handleCat(s *discordgo.Session, m *discordgo.MessageCreate) e
 	return nil
 }
 
-func (c *Commands) handlePing(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	var r *discordgo.Message
-	var err error
-
+func (c *Commands) handlePing(s discord.Session, m *ScuzzyContext) error {
 	msg := c.CreateDefinedEmbed("Ping", "Pong", "success", m.Author)
-	r, err = s.ChannelMessageSendEmbed(m.ChannelID, msg)
-	if err != nil {
-		return err
-	}
-
-	time.Sleep(5 * time.Second)
-
-	err = s.ChannelMessageDelete(m.ChannelID, r.ID)
-	if err != nil {
-		return err
-	}
-	err = s.ChannelMessageDelete(m.ChannelID, m.ID)
+	_, err := c.SendCannedEmbed(s, m, "ping", nil, msg)
 	if err != nil {
 		return err
 	}
@@ -228,12 +229,12 @@ func (c *Commands) handlePing(s *discordgo.Session, m *discordgo.MessageCreate)
 	return nil
 }
 
-func (c *Commands) handleInfo(s *discordgo.Session, m *discordgo.MessageCreate) error {
+func (c *Commands) handleInfo(s discord.Session, m *ScuzzyContext) error {
 	desc := "**Source**:   https://github.com/foxtrot/scuzzy\n"
 	desc += "**Language**: Go\n"
-	desc += "**Commands**: See `" + c.Config.CommandKey + "help`\n\n\n"
+	desc += "**Commands**: See `" + commandKey(m.Config) + "help`\n\n\n"
 
-	gm, err := s.GuildMember(c.Config.GuildID, s.State.User.ID)
+	gm, err := s.GuildMember(m.GuildID, s.GetState().User.ID)
 	if err != nil {
 		return err
 	}
@@ -262,7 +263,7 @@ func (c *Commands) handleInfo(s *discordgo.Session, m *discordgo.MessageCreate)
 
 	msg := c.CreateCustomEmbed(&d)
 
-	_, err = s.ChannelMessageSendEmbed(m.ChannelID, msg)
+	_, err = c.SendEmbed(s, m, msg)
 	if err != nil {
 		return err
 	}
@@ -270,60 +271,38 @@ func (c *Commands) handleInfo(s *discordgo.Session, m *discordgo.MessageCreate)
 	return nil
 }
 
-func (c *Commands) handleHelp(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	keys := make([]int, 0, len(c.ScuzzyCommands))
-	for _, cmd := range c.ScuzzyCommands {
-		keys = append(keys, cmd.Index)
-	}
-	sort.Ints(keys)
-
-	for _, k := range keys {
-		fmt.Println(k, c.ScuzzyCommandsByIndex[k])
-	}
-
-	desc := "**Available Commands**\n"
-	for _, k := range keys {
-		command := c.ScuzzyCommandsByIndex[k]
-
-		if !command.AdminOnly && command.Description != "" {
-			desc += "`" + command.Name + "` - " + command.Description + "\n"
+func (c *Commands) handleSubcommands(s discord.Session, m *ScuzzyContext) error {
+	var subs []string
+	for k := range m.Command.Subcommands {
+		sub := &m.Command.Subcommands[k]
+		if canRun, _ := c.CanRunCommand(s, m, sub); !canRun {
+			continue
 		}
+		subs = append(subs, sub.Name)
 	}
 
-	if c.Permissions.CheckAdminRole(m.Member) {
-		desc += "\n"
-		desc += "**Admin Commands**\n"
-		for _, k := range keys {
-			command := c.ScuzzyCommandsByIndex[k]
+	return errors.New("Unknown subcommand.\nUsage: `" + commandKey(m.Config) + m.Command.Path + " <" + strings.Join(subs, "|") + ">`")
+}
 
-			if command.AdminOnly {
-				desc += "`" + command.Name + "` - " + command.Description + "\n"
-			}
-		}
+func (c *Commands) handleRules(s discord.Session, m *ScuzzyContext) error {
+	if m.templates.rules == nil {
+		return errors.New("No rules have been configured.")
 	}
 
-	desc += "\n\nAll commands are prefixed with `" + c.Config.CommandKey + "`\n"
-
-	msg := c.CreateDefinedEmbed("Help", desc, "", m.Author)
-
-	_, err := s.ChannelMessageSendEmbed(m.ChannelID, msg)
-	if err != nil {
-		return err
-	}
-	err = s.ChannelMessageDelete(m.ChannelID, m.ID)
+	msg, err := m.templates.rules.Render(c.contextTemplateData(s, m, nil))
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
-
-func (c *Commands) handleRules(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	msg := c.Config.RulesText
-	embedTitle := "Rules (" + c.Config.GuildName + ")"
-	embed := c.CreateDefinedEmbed(embedTitle, msg, "success", m.Author)
+	// Plain text rules keep the standard rules embed
+	if len(msg.Embeds) == 0 {
+		embedTitle := "Rules (" + m.Config.GuildName + ")"
+		msg = &discordgo.MessageSend{
+			Embeds: []*discordgo.MessageEmbed{c.CreateDefinedEmbed(embedTitle, msg.Content, "success", m.Author)},
+		}
+	}
 
-	_, err := s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	_, err = c.SendComplex(s, m, msg)
 	if err != nil {
 		return err
 	}
@@ -331,14 +310,10 @@ func (c *Commands) handleRules(s *discordgo.Session, m *discordgo.MessageCreate)
 	return nil
 }
 
-func (c *Commands) handleMarkdownInfo(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	cleanup := true
-	args := strings.Split(m.Content, " ")
-
-	if len(args) == 2 {
-		if args[1] == "stay" && c.Permissions.CheckAdminRole(m.Member) {
-			cleanup = false
-		}
+func (c *Commands) handleMarkdownInfo(s discord.Session, m *ScuzzyContext) error {
+	// Admins can leave the reference up
+	if m.Args.String("stay") == "stay" && m.Permissions.CheckAdminRole(m.Member) {
+		m.Response = ScuzzyResponse{}
 	}
 
 	desc := "*Italic* text goes between `*single asterisks*`\n"
@@ -355,39 +330,16 @@ func (c *Commands) handleMarkdownInfo(s *discordgo.Session, m *discordgo.Message
 	desc += "Multi line quotes start with `>>>`\n"
 
 	msg := c.CreateDefinedEmbed("Discord Markdown", desc, "", m.Author)
-	r, err := s.ChannelMessageSendEmbed(m.ChannelID, msg)
+	_, err := c.SendEmbed(s, m, msg)
 	if err != nil {
 		return err
 	}
 
-	if cleanup {
-		time.Sleep(15 * time.Second)
-
-		err = s.ChannelMessageDelete(m.ChannelID, r.ID)
-		if err != nil {
-			return err
-		}
-		err = s.ChannelMessageDelete(m.ChannelID, m.ID)
-		if err != nil {
-			return err
-		}
-	}
-
 	return nil
 }
 
-func (c *Commands) handleCtoF(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	inS := strings.Split(m.Content, " ")
-
-	if len(inS) < 2 {
-		return errors.New("You did not specify a temperature")
-	}
-	in := inS[1]
-
-	inF, err := strconv.ParseFloat(in, 2)
-	if err != nil {
-		return errors.New("You did not specify a valid number")
-	}
+func (c *Commands) handleCtoF(s discord.Session, m *ScuzzyContext) error {
+	inF := m.Args.Float("temperature")
 
 	cels := (inF * 9.0 / 5.0) + 32.0
 	celsF := float64(cels)
@@ -395,7 +347,7 @@ func (c *Commands) handleCtoF(s *discordgo.Session, m *discordgo.MessageCreate)
 	msg := fmt.Sprintf("`%.1f°c` is `%.1f°f`", inF, celsF)
 
 	e := c.CreateDefinedEmbed("Celsius to Farenheit", msg, "", m.Author)
-	_, err = s.ChannelMessageSendEmbed(m.ChannelID, e)
+	_, err := c.SendEmbed(s, m, e)
 	if err != nil {
 		return err
 	}
@@ -403,18 +355,8 @@ func (c *Commands) handleCtoF(s *discordgo.Session, m *discordgo.MessageCreate)
 	return nil
 }
 
-func (c *Commands) handleFtoC(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	inS := strings.Split(m.Content, " ")
-
-	if len(inS) < 2 {
-		return errors.New("You did not specify a temperature")
-	}
-	in := inS[1]
-
-	inF, err := strconv.ParseFloat(in, 2)
-	if err != nil {
-		return errors.New("You did not specify a valid number")
-	}
+func (c *Commands) handleFtoC(s discord.Session, m *ScuzzyContext) error {
+	inF := m.Args.Float("temperature")
 
 	faren := (inF - 32) * 5 / 9
 	farenF := float64(faren)
@@ -422,7 +364,7 @@ func (c *Commands) handleFtoC(s *discordgo.Session, m *discordgo.MessageCreate)
 	msg := fmt.Sprintf("`%.1f°f` is `%.1f°c`", inF, farenF)
 
 	e := c.CreateDefinedEmbed("Farenheit to Celsius", msg, "", m.Author)
-	_, err = s.ChannelMessageSendEmbed(m.ChannelID, e)
+	_, err := c.SendEmbed(s, m, e)
 	if err != nil {
 		return err
 	}
@@ -430,18 +372,8 @@ func (c *Commands) handleFtoC(s *discordgo.Session, m *discordgo.MessageCreate)
 	return nil
 }
 
-func (c *Commands) handleMetersToFeet(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	inS := strings.Split(m.Content, " ")
-
-	if len(inS) < 2 {
-		return errors.New("You did not specify a distance")
-	}
-	in := inS[1]
-
-	inF, err := strconv.ParseFloat(in, 2)
-	if err != nil {
-		return errors.New("You did not specify a valid number")
-	}
+func (c *Commands) handleMetersToFeet(s discord.Session, m *ScuzzyContext) error {
+	inF := m.Args.Float("distance")
 
 	meters := inF * 3.28
 	metersF := float64(meters)
@@ -449,7 +381,7 @@ func (c *Commands) handleMetersToFeet(s *discordgo.Session, m *discordgo.Message
 	msg := fmt.Sprintf("`%.1fm` is `%.1fft`", inF, metersF)
 
 	e := c.CreateDefinedEmbed("Meters to Feet", msg, "", m.Author)
-	_, err = s.ChannelMessageSendEmbed(m.ChannelID, e)
+	_, err := c.SendEmbed(s, m, e)
 	if err != nil {
 		return err
 	}
@@ -457,18 +389,8 @@ func (c *Commands) handleMetersToFeet(s *discordgo.Session, m *discordgo.Message
 	return nil
 }
 
-func (c *Commands) handleFeetToMeters(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	inS := strings.Split(m.Content, " ")
-
-	if len(inS) < 2 {
-		return errors.New("You did not specify a distance")
-	}
-	in := inS[1]
-
-	inF, err := strconv.ParseFloat(in, 2)
-	if err != nil {
-		return errors.New("You did not specify a valid number")
-	}
+func (c *Commands) handleFeetToMeters(s discord.Session, m *ScuzzyContext) error {
+	inF := m.Args.Float("distance")
 
 	feet := inF / 3.28
 	feetF := float64(feet)
@@ -476,7 +398,7 @@ func (c *Commands) handleFeetToMeters(s *discordgo.Session, m *discordgo.Message
 	msg := fmt.Sprintf("`%.1fft` is `%.1fm`", inF, feetF)
 
 	e := c.CreateDefinedEmbed("Feet to Meters", msg, "", m.Author)
-	_, err = s.ChannelMessageSendEmbed(m.ChannelID, e)
+	_, err := c.SendEmbed(s, m, e)
 	if err != nil {
 		return err
 	}
@@ -484,18 +406,8 @@ func (c *Commands) handleFeetToMeters(s *discordgo.Session, m *discordgo.Message
 	return nil
 }
 
-func (c *Commands) handleCentimeterToInch(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	inS := strings.Split(m.Content, " ")
-
-	if len(inS) < 2 {
-		return errors.New("You did not specify a distance")
-	}
-	in := inS[1]
-
-	inF, err := strconv.ParseFloat(in, 2)
-	if err != nil {
-		return errors.New("You did not specify a valid number")
-	}
+func (c *Commands) handleCentimeterToInch(s discord.Session, m *ScuzzyContext) error {
+	inF := m.Args.Float("distance")
 
 	inch := inF / 2.54
 	inchF := float64(inch)
@@ -503,7 +415,7 @@ func (c *Commands) handleCentimeterToInch(s *discordgo.Session, m *discordgo.Mes
 	msg := fmt.Sprintf("`%.1fcm` is `%.1fin`", inF, inchF)
 
 	e := c.CreateDefinedEmbed("Centimeter To Inch", msg, "", m.Author)
-	_, err = s.ChannelMessageSendEmbed(m.ChannelID, e)
+	_, err := c.SendEmbed(s, m, e)
 	if err != nil {
 		return err
 	}
@@ -511,18 +423,8 @@ func (c *Commands) handleCentimeterToInch(s *discordgo.Session, m *discordgo.Mes
 	return nil
 }
 
-func (c *Commands) handleInchToCentimeter(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	inS := strings.Split(m.Content, " ")
-
-	if len(inS) < 2 {
-		return errors.New("You did not specify a distance")
-	}
-	in := inS[1]
-
-	inF, err := strconv.ParseFloat(in, 2)
-	if err != nil {
-		return errors.New("You did not specify a valid number")
-	}
+func (c *Commands) handleInchToCentimeter(s discord.Session, m *ScuzzyContext) error {
+	inF := m.Args.Float("distance")
 
 	cm := inF * 2.54
 	cmF := float64(cm)
@@ -530,7 +432,7 @@ func (c *Commands) handleInchToCentimeter(s *discordgo.Session, m *discordgo.Mes
 	msg := fmt.Sprintf("`%.1fin` is `%.1fcm`", inF, cmF)
 
 	e := c.CreateDefinedEmbed("Inch to Centimeter", msg, "", m.Author)
-	_, err = s.ChannelMessageSendEmbed(m.ChannelID, e)
+	_, err := c.SendEmbed(s, m, e)
 	if err != nil {
 		return err
 	}
@@ -538,30 +440,25 @@ func (c *Commands) handleInchToCentimeter(s *discordgo.Session, m *discordgo.Mes
 	return nil
 }
 
-func (c *Commands) handleUserInfo(s *discordgo.Session, m *discordgo.MessageCreate) error {
+func (c *Commands) handleUserInfo(s discord.Session, m *ScuzzyContext) error {
 	var (
 		mHandle   *discordgo.Member
 		requester *discordgo.Member
 		err       error
 	)
 
-	userSplit := strings.Split(m.Content, " ")
-
-	if len(userSplit) < 2 {
-		mHandle, err = s.GuildMember(c.Config.GuildID, m.Author.ID)
+	if !m.Args.Has("user") {
+		mHandle, err = s.GuildMember(m.GuildID, m.Author.ID)
 		requester = mHandle
 		if err != nil {
 			return err
 		}
 	} else {
-		idStr := strings.ReplaceAll(userSplit[1], "<@!", "")
-		idStr = strings.ReplaceAll(idStr, "<@", "")
-		idStr = strings.ReplaceAll(idStr, ">", "")
-		mHandle, err = s.GuildMember(c.Config.GuildID, idStr)
-		if err != nil {
-			return err
+		mHandle = m.Args.Member("user")
+		if mHandle == nil {
+			return errors.New("That user is not a member of this server.")
 		}
-		requester, err = s.GuildMember(c.Config.GuildID, m.Author.ID)
+		requester, err = s.GuildMember(m.GuildID, m.Author.ID)
 		if err != nil {
 			return err
 		}
@@ -579,11 +476,6 @@ func (c *Commands) handleUserInfo(s *discordgo.Session, m *discordgo.MessageCrea
 		rUserNick = "No Nickname"
 	}
 
-	rJoinTimeP, err := rJoinTime.Parse()
-	if err != nil {
-		return err
-	}
-
 	rRolesTidy := ""
 	if len(rRoles) == 0 {
 		rRolesTidy = "No Roles"
@@ -597,9 +489,15 @@ func (c *Commands) handleUserInfo(s *discordgo.Session, m *discordgo.MessageCrea
 	msg += "**User Name**: `" + rUsername + "`\n"
 	msg += "**User Nick**: `" + rUserNick + "`\n"
 	msg += "**User Discrim**: `#" + rUserDiscrim + "`\n"
-	msg += "**User Join**:  `" + rJoinTimeP.String() + "`\n"
+	msg += "**User Join**:  `" + rJoinTime.String() + "`\n"
 	msg += "**User Roles**: " + rRolesTidy + "\n"
 
+	warnings, err := c.UserWarnings(m.GuildID, rUserID)
+	if err != nil {
+		return err
+	}
+	msg += "**Warnings**: `" + strconv.Itoa(len(warnings)) + "`\n"
+
 	embedData := models.CustomEmbed{
 		URL:            "",
 		Title:          "User Info (" + rUsername + ")",
@@ -623,7 +521,7 @@ func (c *Commands) handleUserInfo(s *discordgo.Session, m *discordgo.MessageCrea
 	}
 
 	embed := c.CreateCustomEmbed(&embedData)
-	_, err = s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	_, err = c.SendEmbed(s, m, embed)
 	if err != nil {
 		return err
 	}
@@ -631,26 +529,26 @@ func (c *Commands) handleUserInfo(s *discordgo.Session, m *discordgo.MessageCrea
 	return nil
 }
 
-func (c *Commands) handleServerInfo(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	g, err := s.Guild(c.Config.GuildID)
+func (c *Commands) handleServerInfo(s discord.Session, m *ScuzzyContext) error {
+	g, err := s.Guild(m.GuildID)
 	if err != nil {
 		return err
 	}
 
-	sID := c.Config.GuildID
-	sName := c.Config.GuildName
+	sID := m.GuildID
+	sName := m.Config.GuildName
 
-	chans, _ := s.GuildChannels(c.Config.GuildID)
+	chans, _ := s.GuildChannels(m.GuildID)
 	sChannels := strconv.Itoa(len(chans))
 	sEmojis := strconv.Itoa(len(g.Emojis))
 	sRoles := strconv.Itoa(len(g.Roles))
 	sRegion := g.Region
 
-	iID, _ := strconv.Atoi(c.Config.GuildID)
+	iID, _ := strconv.Atoi(m.GuildID)
 	createdMSecs := ((iID / 4194304) + 1420070400000) / 1000
 	sCreatedAt := time.Unix(int64(createdMSecs), 0).Format(time.RFC1123)
 
-	sIconURL := g.IconURL()
+	sIconURL := g.IconURL("256")
 
 	user := m.Author
 
@@ -686,32 +584,23 @@ func (c *Commands) handleServerInfo(s *discordgo.Session, m *discordgo.MessageCr
 
 	msg := c.CreateCustomEmbed(&embedData)
 
-	_, err = s.ChannelMessageSendEmbed(m.ChannelID, msg)
+	_, err = c.SendEmbed(s, m, msg)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
-func (c *Commands) handleGoogle4U(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	args := strings.Split(m.Content, " ")
-
-	if len(args) < 2 {
-		return errors.New("You did not specify anything to google")
-	}
-
-	input := m.Content[strings.Index(m.Content, " "):len(m.Content)]
+func (c *Commands) handleGoogle4U(s discord.Session, m *ScuzzyContext) error {
+	input := m.Args.String("query")
 
 	desc := "https://letmegooglethat.com/?q=" + url.QueryEscape(input)
 
 	msg := c.CreateDefinedEmbed("Google", desc, "", m.Author)
-	_, err := s.ChannelMessageSendEmbed(m.ChannelID, msg)
-	if err != nil {
-		return err
-	}
-	err = s.ChannelMessageDelete(m.ChannelID, m.ID)
+	_, err := c.SendEmbed(s, m, msg)
 	if err != nil {
 		return err
 	}
+
 	return nil
 }
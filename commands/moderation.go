@@ -3,28 +3,19 @@ package commands
 import (
 	"errors"
 	"strconv"
-	"strings"
-	"time"
 
 	"github.com/bwmarrin/discordgo"
-	"github.com/foxtrot/scuzzy/actions"
+	"github.com/foxtrot/scuzzy/discord"
+	"github.com/foxtrot/scuzzy/models"
 )
 
-func (c *Commands) handleSetSlowmode(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	slowmodeSplit := strings.Split(m.Content, " ")
-	if len(slowmodeSplit) < 2 {
-		return errors.New("You must supply at least an amount of time")
-	}
+func (c *Commands) handleSetSlowmode(s discord.Session, m *ScuzzyContext) error {
+	slowModeTime := m.Args.Int("seconds")
+	slowmodeTimeStr := strconv.Itoa(slowModeTime)
 
-	slowmodeTimeStr := slowmodeSplit[1]
-	slowModeTime, err := strconv.Atoi(slowmodeTimeStr)
-	if err != nil {
-		return err
-	}
-
-	if len(slowmodeSplit) == 3 {
-		if slowmodeSplit[2] == "all" {
-			channels, err := s.GuildChannels(c.Config.GuildID)
+	if m.Args.Has("scope") {
+		if m.Args.String("scope") == "all" {
+			channels, err := s.GuildChannels(m.GuildID)
 			if err != nil {
 				return err
 			}
@@ -33,7 +24,7 @@ func (c *Commands) handleSetSlowmode(s *discordgo.Session, m *discordgo.MessageC
 				currPos := channel.Position
 				s.ChannelEditComplex(channel.ID, &discordgo.ChannelEdit{
 					Position:         currPos,
-					RateLimitPerUser: slowModeTime,
+					RateLimitPerUser: &slowModeTime,
 				})
 			}
 		}
@@ -46,7 +37,7 @@ func (c *Commands) handleSetSlowmode(s *discordgo.Session, m *discordgo.MessageC
 		currPos := currChan.Position
 		_, err = s.ChannelEditComplex(m.ChannelID, &discordgo.ChannelEdit{
 			Position:         currPos,
-			RateLimitPerUser: slowModeTime,
+			RateLimitPerUser: &slowModeTime,
 		})
 		if err != nil {
 			return err
@@ -54,7 +45,7 @@ func (c *Commands) handleSetSlowmode(s *discordgo.Session, m *discordgo.MessageC
 	}
 
 	msg := c.CreateDefinedEmbed("Slow Mode", "Successfully set Slow Mode to `"+slowmodeTimeStr+"`.", "success", m.Author)
-	_, err = s.ChannelMessageSendEmbed(m.ChannelID, msg)
+	_, err := c.SendCannedEmbed(s, m, "slowmode", map[string]string{"seconds": slowmodeTimeStr}, msg)
 	if err != nil {
 		return err
 	}
@@ -62,14 +53,12 @@ func (c *Commands) handleSetSlowmode(s *discordgo.Session, m *discordgo.MessageC
 	return nil
 }
 
-func (c *Commands) handleUnsetSlowmode(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	slowmodeSplit := strings.Split(m.Content, " ")
-
+func (c *Commands) handleUnsetSlowmode(s discord.Session, m *ScuzzyContext) error {
 	secs := 0
 
-	if len(slowmodeSplit) == 2 {
-		if slowmodeSplit[1] == "all" {
-			channels, err := s.GuildChannels(c.Config.GuildID)
+	if m.Args.Has("scope") {
+		if m.Args.String("scope") == "all" {
+			channels, err := s.GuildChannels(m.GuildID)
 			if err != nil {
 				return err
 			}
@@ -78,7 +67,7 @@ func (c *Commands) handleUnsetSlowmode(s *discordgo.Session, m *discordgo.Messag
 				currPos := channel.Position
 				s.ChannelEditComplex(channel.ID, &discordgo.ChannelEdit{
 					Position:         currPos,
-					RateLimitPerUser: secs,
+					RateLimitPerUser: &secs,
 				})
 			}
 		}
@@ -91,7 +80,7 @@ func (c *Commands) handleUnsetSlowmode(s *discordgo.Session, m *discordgo.Messag
 		currPos := currChan.Position
 		_, err = s.ChannelEditComplex(m.ChannelID, &discordgo.ChannelEdit{
 			Position:         currPos,
-			RateLimitPerUser: secs,
+			RateLimitPerUser: &secs,
 		})
 		if err != nil {
 			return err
@@ -99,7 +88,7 @@ func (c *Commands) handleUnsetSlowmode(s *discordgo.Session, m *discordgo.Messag
 	}
 
 	msg := c.CreateDefinedEmbed("Slow Mode", "Successfully unset Slow Mode", "success", m.Author)
-	_, err := s.ChannelMessageSendEmbed(m.ChannelID, msg)
+	_, err := c.SendCannedEmbed(s, m, "slowmode", map[string]string{"seconds": "0"}, msg)
 	if err != nil {
 		return err
 	}
@@ -107,84 +96,28 @@ func (c *Commands) handleUnsetSlowmode(s *discordgo.Session, m *discordgo.Messag
 	return nil
 }
 
-func (c *Commands) handlePurgeChannel(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	purgeSplit := strings.SplitN(m.Content, " ", 2)
-	if len(purgeSplit) < 2 {
-		return errors.New("No message count supplied")
-	}
-
-	msgCount, err := strconv.Atoi(purgeSplit[1])
-	if err != nil {
-		return nil
-	}
-
-	if msgCount > 100 {
-		return errors.New("You may only purge upto 100 messages at a time.")
-	}
-
-	chanMsgs, err := s.ChannelMessages(m.ChannelID, msgCount, "", "", "")
-	if err != nil {
-		return err
-	}
-
-	msg := c.CreateDefinedEmbed("Purge Channel", "Purging `"+purgeSplit[1]+"` messages.", "", m.Author)
-	r, err := s.ChannelMessageSendEmbed(m.ChannelID, msg)
-	if err != nil {
-		return err
-	}
-
-	var delMsgs []string
-	for _, v := range chanMsgs {
-		delMsgs = append(delMsgs, v.ID)
-	}
-
-	err = s.ChannelMessagesBulkDelete(m.ChannelID, delMsgs)
-	if err != nil {
-		return err
-	}
-
-	err = s.ChannelMessageDelete(m.ChannelID, r.ID)
-	msg = c.CreateDefinedEmbed("Purge Channel", "Purged `"+purgeSplit[1]+"` messages!", "success", m.Author)
-	msgS, err := s.ChannelMessageSendEmbed(m.ChannelID, msg)
-	if err != nil {
-		return err
-	}
-
-	time.Sleep(time.Second * 10)
-
-	err = s.ChannelMessageDelete(m.ChannelID, msgS.ID)
-	if err != nil {
-		return err
+// checkTarget stops moderators acting on members at or above their own tier or role.
+func (c *Commands) checkTarget(s discord.Session, m *ScuzzyContext, target *discordgo.Member) error {
+	ok, reason := m.Permissions.CheckTarget(s, m.GuildID, m.Member, target)
+	if !ok {
+		return errors.New(reason)
 	}
 
 	return nil
 }
 
-func (c *Commands) handleKickUser(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	var (
-		mHandle    *discordgo.Member
-		kickReason string
-		err        error
-	)
-
-	args := strings.Split(m.Content, " ")
-	if len(args) < 2 {
-		return errors.New("You must specify a user to kick.")
-	}
-	if len(args) == 3 {
-		kickReason = strings.Join(args[2:], " ")
+func (c *Commands) handleKickUser(s discord.Session, m *ScuzzyContext) error {
+	mHandle := m.Args.Member("user")
+	if mHandle == nil {
+		return errors.New("That user is not a member of this server.")
 	}
-
-	member := args[1]
-	idStr := strings.ReplaceAll(member, "<@!", "")
-	idStr = strings.ReplaceAll(idStr, "<@", "")
-	idStr = strings.ReplaceAll(idStr, ">", "")
-	mHandle, err = s.GuildMember(c.Config.GuildID, idStr)
+	err := c.checkTarget(s, m, mHandle)
 	if err != nil {
 		return err
 	}
+	kickReason := moderationReason(m)
 
-	err = actions.KickUser(s, c.Config.GuildID, mHandle.User.ID, kickReason)
+	err = c.Actions.KickUser(s, m.GuildID, mHandle.User.ID, m.Author.ID, kickReason)
 	if err != nil {
 		return err
 	}
@@ -195,7 +128,7 @@ func (c *Commands) handleKickUser(s *discordgo.Session, m *discordgo.MessageCrea
 	}
 
 	embed := c.CreateDefinedEmbed("Kick User", msg, "success", m.Author)
-	_, err = s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	_, err = c.SendCannedEmbed(s, m, "kick", map[string]string{"target": "<@" + mHandle.User.ID + ">", "reason": kickReason}, embed)
 	if err != nil {
 		return err
 	}
@@ -203,42 +136,32 @@ func (c *Commands) handleKickUser(s *discordgo.Session, m *discordgo.MessageCrea
 	return nil
 }
 
-func (c *Commands) handleBanUser(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	var (
-		mHandle   *discordgo.User
-		banReason string
-		err       error
-	)
+func (c *Commands) handleBanUser(s discord.Session, m *ScuzzyContext) error {
+	mHandle := m.Args.User("user")
+	banReason := moderationReason(m)
+	banLength := m.Args.Duration("duration")
 
-	args := strings.Split(m.Content, " ")
-	if len(args) < 2 {
-		return errors.New("You must specify a user to ban.")
-	}
-	if len(args) == 3 {
-		banReason = strings.Join(args[2:], " ")
-	}
-
-	member := args[1]
-	idStr := strings.ReplaceAll(member, "<@!", "")
-	idStr = strings.ReplaceAll(idStr, "<@", "")
-	idStr = strings.ReplaceAll(idStr, ">", "")
-	mHandle, err = s.User(idStr)
+	err := c.checkTarget(s, m, m.Args.Member("user"))
 	if err != nil {
 		return err
 	}
 
-	err = actions.BanUser(s, c.Config.GuildID, mHandle.ID, banReason)
+	err = c.Actions.TempBanUser(s, m.GuildID, mHandle.ID, m.Author.ID, banLength, m.Args.Int("days"), banReason)
 	if err != nil {
 		return err
 	}
 
-	msg := "User `" + mHandle.Username + "#" + mHandle.Discriminator + "` was banned.\n"
+	msg := "User `" + mHandle.Username + "#" + mHandle.Discriminator + "` was banned"
+	if banLength > 0 {
+		msg += " for `" + formatDuration(banLength) + "`"
+	}
+	msg += ".\n"
 	if len(banReason) > 0 {
 		msg += "Reason: `" + banReason + "`\n"
 	}
 
 	embed := c.CreateDefinedEmbed("Ban User", msg, "success", m.Author)
-	_, err = s.ChannelMessageSendEmbed(m.ChannelID, embed)
+	_, err = c.SendCannedEmbed(s, m, "ban", map[string]string{"target": "<@" + mHandle.ID + ">", "reason": banReason}, embed)
 	if err != nil {
 		return err
 	}
@@ -246,21 +169,22 @@ func (c *Commands) handleBanUser(s *discordgo.Session, m *discordgo.MessageCreat
 	return nil
 }
 
-func (c *Commands) handleIgnoreUser(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	ignArgs := strings.Split(m.Content, " ")
-	if len(ignArgs) < 2 {
-		return errors.New("You did not specify a user.")
-	}
-
-	member := ignArgs[1]
-	idStr := strings.ReplaceAll(member, "<@!", "")
-	idStr = strings.ReplaceAll(idStr, "<@", "")
-	idStr = strings.ReplaceAll(idStr, ">", "")
+func (c *Commands) handleIgnoreUser(s discord.Session, m *ScuzzyContext) error {
+	idStr := m.Args.User("user").ID
 
-	c.Config.IgnoredUsers = append(c.Config.IgnoredUsers, idStr)
+	err := c.UpdateConfig(s, m, func(conf *models.Configuration) error {
+		if hasID(conf.IgnoredUsers, idStr) {
+			return errors.New("<@" + idStr + "> is already being ignored.")
+		}
+		conf.IgnoredUsers = append(conf.IgnoredUsers, idStr)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
 	eMsg := c.CreateDefinedEmbed("Ignore User", "<@!"+idStr+"> is now being ignored.", "success", m.Author)
-	_, err := s.ChannelMessageSendEmbed(m.ChannelID, eMsg)
+	_, err = c.SendCannedEmbed(s, m, "ignore", map[string]string{"target": "<@" + idStr + ">"}, eMsg)
 	if err != nil {
 		return err
 	}
@@ -273,26 +197,24 @@ func (c *Commands) handleIgnoreUser(s *discordgo.Session, m *discordgo.MessageCr
 	return nil
 }
 
-func (c *Commands) handleUnIgnoreUser(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	ignArgs := strings.Split(m.Content, " ")
-	if len(ignArgs) < 2 {
-		return errors.New("You did not specify a user.")
-	}
-
-	member := ignArgs[1]
-	idStr := strings.ReplaceAll(member, "<@!", "")
-	idStr = strings.ReplaceAll(idStr, "<@", "")
-	idStr = strings.ReplaceAll(idStr, ">", "")
+func (c *Commands) handleUnIgnoreUser(s discord.Session, m *ScuzzyContext) error {
+	idStr := m.Args.User("user").ID
 
-	for k, v := range c.Config.IgnoredUsers {
-		if v == idStr {
-			c.Config.IgnoredUsers[k] = c.Config.IgnoredUsers[len(c.Config.IgnoredUsers)-1]
-			c.Config.IgnoredUsers = c.Config.IgnoredUsers[:len(c.Config.IgnoredUsers)-1]
+	err := c.UpdateConfig(s, m, func(conf *models.Configuration) error {
+		for k, v := range conf.IgnoredUsers {
+			if v == idStr {
+				conf.IgnoredUsers[k] = conf.IgnoredUsers[len(conf.IgnoredUsers)-1]
+				conf.IgnoredUsers = conf.IgnoredUsers[:len(conf.IgnoredUsers)-1]
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	eMsg := c.CreateDefinedEmbed("Unignore User", "<@!"+idStr+"> is not being ignored.", "success", m.Author)
-	_, err := s.ChannelMessageSendEmbed(m.ChannelID, eMsg)
+	_, err = c.SendCannedEmbed(s, m, "unignore", map[string]string{"target": "<@" + idStr + ">"}, eMsg)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,22 @@
+package commands
+
+import "testing"
+
+func TestModerationHierarchy(t *testing.T) {
+	b := newTestBot(t, nil)
+	other := b.addMember("500000000000000003", "other", testAdminRole)
+
+	for _, cmd := range []string{".kick <@" + other.User.ID + ">", ".ban <@" + other.User.ID + ">", ".timeout <@" + other.User.ID + "> 10m"} {
+		b.run(cmd)
+	}
+	for _, method := range []string{"GuildMemberDeleteWithReason", "GuildBanCreateWithReason", "GuildMemberTimeout"} {
+		if len(b.s.CallsTo(method)) != 0 {
+			t.Errorf("admins shouldn't be able to act on other admins, got %s", method)
+		}
+	}
+
+	b.run(".kick <@" + testUserID + ">")
+	if b.member(testUserID) != nil {
+		t.Error("admins should be able to kick regular members")
+	}
+}
@@ -0,0 +1,182 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/foxtrot/scuzzy/discord"
+)
+
+// ModuleEventHandler receives every gateway event for guilds its module is enabled in, such as
+// *discordgo.GuildMemberAdd. Handlers pick out the events they care about.
+type ModuleEventHandler func(s discord.Session, g *Guild, event interface{}) error
+
+// Module is a set of commands and event handlers shipped as its own package. Packages register
+// their modules from init with RegisterModule, and guilds turn them on under "modules" in their config:
+//
+//	"modules": { "<name>": { "enabled": true, "config": { ... } } }
+type Module interface {
+	// Name identifies the module in guild configs.
+	Name() string
+	// Commands are registered alongside the built in commands. c gives handlers access to the bot's helpers.
+	Commands(c *Commands) []ScuzzyCommand
+	// EventHandlers are called after Scuzzy's own handling of each event.
+	EventHandlers(c *Commands) []ModuleEventHandler
+	// NewConfig returns a pointer to an empty config section, each guild's "config" is decoded into
+	// its own copy. Modules without settings return nil.
+	NewConfig() interface{}
+}
+
+var (
+	modulesMu sync.Mutex
+	modules   = make(map[string]Module)
+)
+
+// RegisterModule makes a module available to load. It is meant to be called from a package's init.
+func RegisterModule(mod Module) {
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+
+	if _, ok := modules[mod.Name()]; ok {
+		panic("commands: module '" + mod.Name() + "' registered twice")
+	}
+	modules[mod.Name()] = mod
+}
+
+// Modules lists every module compiled in, by name.
+func Modules() []Module {
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+
+	var mods []Module
+	for _, mod := range modules {
+		mods = append(mods, mod)
+	}
+	sort.Slice(mods, func(i, j int) bool {
+		return mods[i].Name() < mods[j].Name()
+	})
+
+	return mods
+}
+
+type loadedModule struct {
+	Module
+	handlers []ModuleEventHandler
+}
+
+// LoadModule registers a module's commands and event handlers. Call it after RegisterHandlers.
+func (c *Commands) LoadModule(mod Module) error {
+	name := mod.Name()
+	for _, lm := range c.modules {
+		if lm.Name() == name {
+			return errors.New("Module '" + name + "' is already loaded")
+		}
+	}
+
+	// Nothing is registered unless every command is well defined, and no name or alias is taken
+	// twice, which would silently send one command's invocations to the other
+	cmds := mod.Commands(c)
+	taken := make(map[string]string)
+	for k, cmd := range cmds {
+		err := c.prepareCommand(&cmds[k], nil)
+		if err != nil {
+			return errors.New("Module '" + name + "': " + err.Error())
+		}
+
+		for _, n := range append([]string{cmd.Name}, cmd.Aliases...) {
+			n = strings.ToLower(n)
+			if existing, ok := c.FindCommand(n); ok {
+				return errors.New("Module '" + name + "' command '" + cmd.Name + "' clashes with the existing command '" + existing.Name + "' over '" + n + "'")
+			}
+			if other, ok := taken[n]; ok {
+				return errors.New("Module '" + name + "' commands '" + other + "' and '" + cmd.Name + "' both use '" + n + "'")
+			}
+			taken[n] = cmd.Name
+		}
+	}
+
+	log.Printf("[*] Loading Module '%s'\n", name)
+	for _, cmd := range cmds {
+		cmd.Module = name
+		if len(cmd.Category) == 0 {
+			cmd.Category = name
+		}
+		err := c.RegisterCommand(cmd)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.modules = append(c.modules, loadedModule{
+		Module:   mod,
+		handlers: mod.EventHandlers(c),
+	})
+
+	return nil
+}
+
+// moduleConfigs decodes each loaded module's section of a guild's config. Modules whose section
+// doesn't decode are left disabled for the guild.
+func (c *Commands) moduleConfigs(g *Guild) map[string]interface{} {
+	configs := make(map[string]interface{})
+
+	for _, lm := range c.modules {
+		mc, ok := g.Config.Modules[lm.Name()]
+		if !ok || !mc.Enabled {
+			continue
+		}
+
+		conf := lm.NewConfig()
+		if conf != nil && len(mc.Config) > 0 {
+			err := json.Unmarshal(mc.Config, conf)
+			if err != nil {
+				log.Printf("[!] Module %s disabled in %s, invalid config: %s\n", lm.Name(), g.Config.GuildID, err.Error())
+				continue
+			}
+		}
+		configs[lm.Name()] = conf
+	}
+
+	return configs
+}
+
+// ModuleEnabled reports whether a guild has turned a module on.
+func (g *Guild) ModuleEnabled(name string) bool {
+	_, ok := g.modules[name]
+	return ok
+}
+
+// ModuleConfig returns a guild's config section for a module, as returned by its NewConfig.
+func (g *Guild) ModuleConfig(name string) interface{} {
+	return g.modules[name]
+}
+
+// CommandEnabled reports whether a command is available in a guild. Built in commands always are.
+func (g *Guild) CommandEnabled(cmd *ScuzzyCommand) bool {
+	return len(cmd.Module) == 0 || g.ModuleEnabled(cmd.Module)
+}
+
+// dispatchModuleEvent passes an event to the handlers of every module its guild has enabled.
+func (c *Commands) dispatchModuleEvent(s discord.Session, guildID string, event interface{}) {
+	guild, ok := c.Guild(guildID)
+	if !ok {
+		return
+	}
+
+	for _, lm := range c.modules {
+		if !guild.ModuleEnabled(lm.Name()) {
+			continue
+		}
+
+		for _, handler := range lm.handlers {
+			err := handler(s, guild, event)
+			if err != nil {
+				c.logGuildError(s, guildID, "Error ("+lm.Name()+")", err)
+			}
+		}
+	}
+}
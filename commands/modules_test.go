@@ -0,0 +1,100 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/discord"
+)
+
+type greetConfig struct {
+	Greeting string `json:"greeting"`
+}
+
+// greetModule replies to `hello` with its guild's greeting and counts member joins.
+type greetModule struct {
+	cmds   []ScuzzyCommand
+	greets []string
+	joins  int
+}
+
+func (g *greetModule) Name() string { return "greet" }
+
+func (g *greetModule) Commands(c *Commands) []ScuzzyCommand {
+	if g.cmds != nil {
+		return g.cmds
+	}
+
+	return []ScuzzyCommand{{Name: "hello", Aliases: []string{"hi"}, Description: "Say hello", Handler: func(s discord.Session, m *ScuzzyContext) error {
+		g.greets = append(g.greets, m.ModuleConfig("greet").(*greetConfig).Greeting)
+		return nil
+	}}}
+}
+
+func (g *greetModule) EventHandlers(c *Commands) []ModuleEventHandler {
+	return []ModuleEventHandler{func(s discord.Session, guild *Guild, event interface{}) error {
+		if _, ok := event.(*discordgo.GuildMemberAdd); ok {
+			g.joins++
+		}
+		return nil
+	}}
+}
+
+func (g *greetModule) NewConfig() interface{} { return &greetConfig{} }
+
+func TestLoadModule(t *testing.T) {
+	b := newTestBot(t, nil)
+	mod := &greetModule{}
+	b.Commands = b.start(mod)
+
+	// Guilds that haven't enabled the module don't see it
+	b.run(".hello")
+	b.join("500000000000000003")
+	if len(mod.greets) != 0 || mod.joins != 0 {
+		t.Fatalf("module ran in a guild without it enabled: %v, %d joins", mod.greets, mod.joins)
+	}
+
+	b = newTestBot(t, map[string]interface{}{
+		"modules": map[string]interface{}{"greet": map[string]interface{}{"enabled": true, "config": map[string]string{"greeting": "howdy"}}},
+	})
+	mod = &greetModule{}
+	b.Commands = b.start(mod)
+
+	b.run(".hi")
+	b.join("500000000000000003")
+	if len(mod.greets) != 1 || mod.greets[0] != "howdy" {
+		t.Errorf("expected the command to run with the guild's config, got %v", mod.greets)
+	}
+	if mod.joins != 1 {
+		t.Errorf("expected the event handler to see one join, got %d", mod.joins)
+	}
+
+	if err := b.LoadModule(mod); err == nil {
+		t.Error("loading a module twice should fail")
+	}
+}
+
+func TestLoadModuleRejected(t *testing.T) {
+	b := newTestBot(t, nil)
+	handler := func(s discord.Session, m *ScuzzyContext) error { return nil }
+
+	tests := map[string][]ScuzzyCommand{
+		"built in name":  {{Name: "ping", Handler: handler}},
+		"built in alias": {{Name: "pong", Aliases: []string{"colors"}, Handler: handler}},
+		"own clash":      {{Name: "one", Aliases: []string{"same"}, Handler: handler}, {Name: "two", Aliases: []string{"same"}, Handler: handler}},
+		"bad arguments": {{Name: "three", Handler: handler}, {Name: "four", Handler: handler, Arguments: []ScuzzyArgument{
+			{Name: "first", Type: ArgString},
+			{Name: "second", Type: ArgString, Required: true},
+		}}},
+	}
+	for name, cmds := range tests {
+		if err := b.LoadModule(&greetModule{cmds: cmds}); err == nil {
+			t.Errorf("%s: expected the load to fail", name)
+		}
+		for _, cmd := range cmds {
+			if found, ok := b.FindCommand(cmd.Name); ok && found.Module == "greet" {
+				t.Errorf("%s: command %s registered from a failed load", name, cmd.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/actions"
+	"github.com/foxtrot/scuzzy/discord"
+)
+
+// unmuteTask is the data of the scheduled end of a role based mute.
+type unmuteTask struct {
+	UserID string `json:"user_id"`
+	RoleID string `json:"role_id"`
+}
+
+func unmuteTaskData(task ScheduledTask) unmuteTask {
+	var ut unmuteTask
+	json.Unmarshal(task.Data, &ut)
+
+	return ut
+}
+
+func isUnknownMember(err error) bool {
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Message != nil {
+		return restErr.Message.Code == discordgo.ErrCodeUnknownMember
+	}
+
+	return false
+}
+
+// muteRole returns the role a guild mutes members with, or "" when it uses Discord's timeouts.
+func (c *Commands) muteRole(guildID string) (string, error) {
+	guild, ok := c.Guild(guildID)
+	if !ok || guild.Config.MuteMode != "role" {
+		return "", nil
+	}
+	if len(guild.Config.MuteRoleID) == 0 {
+		return "", errors.New("`mute_mode` is `role` but no `mute_role_id` is configured.")
+	}
+
+	return guild.Config.MuteRoleID, nil
+}
+
+// TimeoutUser stops a member talking for a while, with Discord's timeouts or the guild's mute role.
+func (c *Commands) TimeoutUser(s discord.Session, guildID string, userID string, moderatorID string, d time.Duration, reason string) error {
+	role, err := c.muteRole(guildID)
+	if err != nil {
+		return err
+	}
+
+	if len(role) == 0 {
+		return c.Actions.TimeoutUser(s, guildID, userID, moderatorID, d, reason)
+	}
+	if d <= 0 {
+		return errors.New("Mutes need a duration.")
+	}
+
+	return c.Actions.MuteUser(s, guildID, userID, role, moderatorID, d, reason)
+}
+
+// RemoveTimeout lets a member talk again, however they were timed out.
+func (c *Commands) RemoveTimeout(s discord.Session, guildID string, userID string, moderatorID string, reason string) error {
+	role, err := c.muteRole(guildID)
+	if err != nil {
+		return err
+	}
+
+	if len(role) == 0 {
+		return c.Actions.RemoveTimeout(s, guildID, userID, moderatorID, reason)
+	}
+
+	return c.Actions.UnmuteUser(s, guildID, userID, role, moderatorID, reason)
+}
+
+// trackMutes schedules the end of role based mutes. It observes the actions package.
+func (c *Commands) trackMutes(s discord.Session, a actions.Action) {
+	if a.Type != actions.ActionTimeout && a.Type != actions.ActionUntimeout {
+		return
+	}
+
+	// A new timeout or an unmute replaces whatever was pending for the user
+	_, err := c.CancelTasks(a.GuildID, "unmute", func(task ScheduledTask) bool {
+		return unmuteTaskData(task).UserID == a.UserID
+	})
+	if err != nil {
+		c.logGuildError(s, a.GuildID, "Error (Mutes)", err)
+		return
+	}
+
+	// Discord lifts its own timeouts
+	if a.Type != actions.ActionTimeout || len(a.RoleID) == 0 || a.Duration <= 0 {
+		return
+	}
+
+	_, err = c.ScheduleTask(s, a.GuildID, "unmute", time.Now().Add(a.Duration), unmuteTask{UserID: a.UserID, RoleID: a.RoleID})
+	if err != nil {
+		c.logGuildError(s, a.GuildID, "Error (Mutes)", err)
+	}
+}
+
+func (c *Commands) runUnmuteTask(s discord.Session, guildID string, task ScheduledTask) error {
+	ut := unmuteTaskData(task)
+
+	err := c.Actions.UnmuteUser(s, guildID, ut.UserID, ut.RoleID, s.GetState().User.ID, "Mute expired")
+	// Left the server, restoreMute won't find the task if they come back
+	if err != nil && !isUnknownMember(err) {
+		return err
+	}
+
+	return nil
+}
+
+// restoreMute gives the mute role back to members who leave and rejoin while muted.
+func (c *Commands) restoreMute(s discord.Session, guildID string, userID string) error {
+	for _, task := range c.Tasks(guildID, "unmute") {
+		ut := unmuteTaskData(task)
+		if ut.UserID == userID {
+			return s.GuildMemberRoleAdd(guildID, userID, ut.RoleID, discordgo.WithAuditLogReason("Rejoined while muted"))
+		}
+	}
+
+	return nil
+}
+
+func (c *Commands) handleTimeoutUser(s discord.Session, m *ScuzzyContext) error {
+	mHandle := m.Args.Member("user")
+	if mHandle == nil {
+		return errors.New("That user is not a member of this server.")
+	}
+	length := m.Args.Duration("duration")
+	reason := moderationReason(m)
+
+	err := c.checkTarget(s, m, mHandle)
+	if err != nil {
+		return err
+	}
+
+	err = c.TimeoutUser(s, m.GuildID, mHandle.User.ID, m.Author.ID, length, reason)
+	if err != nil {
+		return err
+	}
+
+	msg := "User `" + mHandle.User.Username + "#" + mHandle.User.Discriminator + "` was timed out for `" + formatDuration(length) + "`.\n"
+	if len(reason) > 0 {
+		msg += "Reason: `" + reason + "`\n"
+	}
+
+	embed := c.CreateDefinedEmbed("Timeout User", msg, "success", m.Author)
+	_, err = c.SendCannedEmbed(s, m, "timeout", map[string]string{"target": "<@" + mHandle.User.ID + ">", "reason": reason, "duration": formatDuration(length)}, embed)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Commands) handleRemoveTimeout(s discord.Session, m *ScuzzyContext) error {
+	mHandle := m.Args.Member("user")
+	if mHandle == nil {
+		return errors.New("That user is not a member of this server.")
+	}
+	reason := moderationReason(m)
+
+	err := c.RemoveTimeout(s, m.GuildID, mHandle.User.ID, m.Author.ID, reason)
+	if err != nil {
+		return err
+	}
+
+	msg := "User `" + mHandle.User.Username + "#" + mHandle.User.Discriminator + "` can talk again.\n"
+	if len(reason) > 0 {
+		msg += "Reason: `" + reason + "`\n"
+	}
+
+	embed := c.CreateDefinedEmbed("Remove Timeout", msg, "success", m.Author)
+	_, err = c.SendCannedEmbed(s, m, "untimeout", map[string]string{"target": "<@" + mHandle.User.ID + ">", "reason": reason}, embed)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestRoleMute(t *testing.T) {
+	b := newTestBot(t, map[string]interface{}{"mute_mode": "role", "mute_role_id": testMuteRole})
+
+	b.run(".mute <@" + testUserID + "> 60d Spamming")
+
+	if !hasRole(b.member(testUserID), testMuteRole) {
+		t.Fatal("expected the mute role to be given")
+	}
+	if len(b.s.CallsTo("GuildMemberTimeout")) != 0 {
+		t.Error("role mutes shouldn't use Discord's timeouts")
+	}
+	tasks := b.Tasks(testGuildID, "unmute")
+	if len(tasks) != 1 || unmuteTaskData(tasks[0]).UserID != testUserID {
+		t.Fatalf("expected an unmute to be scheduled, got %+v", tasks)
+	}
+
+	// Leaving and rejoining doesn't shake off the mute
+	err := b.s.State.MemberRemove(b.member(testUserID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejoined := b.join(testUserID)
+	if !hasRole(rejoined, testMuteRole) {
+		t.Error("the mute role should be put back on rejoining")
+	}
+
+	err = b.runUnmuteTask(b.s, testGuildID, tasks[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Wait()
+	if hasRole(rejoined, testMuteRole) {
+		t.Error("the mute role should be taken off once the mute expires")
+	}
+	if len(b.Tasks(testGuildID, "unmute")) != 0 {
+		t.Error("unmuting should cancel the unmute task")
+	}
+
+	// Members who left since are skipped
+	err = b.s.State.MemberRemove(rejoined)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.s.Errors["GuildMemberRoleRemove"] = &discordgo.RESTError{Message: &discordgo.APIErrorMessage{Code: discordgo.ErrCodeUnknownMember}}
+	if err := b.runUnmuteTask(b.s, testGuildID, tasks[0]); err != nil {
+		t.Errorf("unmuting a member who left: %v", err)
+	}
+}
+
+func TestRoleMuteWithoutRole(t *testing.T) {
+	b := newTestBot(t, map[string]interface{}{"mute_mode": "role"})
+
+	b.run(".mute <@" + testUserID + "> 1h Spamming")
+
+	if len(b.s.CallsTo("GuildMemberRoleAdd")) != 0 || len(b.s.CallsTo("GuildMemberTimeout")) != 0 {
+		t.Error("nothing should happen without a mute role")
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	b := newTestBot(t, nil)
+
+	b.run(".timeout <@" + testUserID + "> 2h Spamming")
+
+	until := b.member(testUserID).CommunicationDisabledUntil
+	if until == nil {
+		t.Fatal("expected a timeout")
+	}
+	if d := time.Until(*until); d < 2*time.Hour-time.Minute || d > 2*time.Hour {
+		t.Errorf("timed out for %v, want 2h", d)
+	}
+	if len(b.Tasks(testGuildID, "unmute")) != 0 {
+		t.Error("Discord lifts its own timeouts, nothing should be scheduled")
+	}
+
+	b.run(".untimeout <@" + testUserID + ">")
+	if b.member(testUserID).CommunicationDisabledUntil != nil {
+		t.Error("expected the timeout to be lifted")
+	}
+}
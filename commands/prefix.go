@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/foxtrot/scuzzy/discord"
+	"github.com/foxtrot/scuzzy/models"
+)
+
+// commandKeys lists the keys a guild has configured, command_key first, in the order they are set.
+func commandKeys(conf *models.Configuration) []string {
+	var keys []string
+	for _, key := range append([]string{conf.CommandKey}, conf.CommandKeys...) {
+		if len(key) > 0 {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// commandKey is the key replies show commands with, which is the first one configured.
+func commandKey(conf *models.Configuration) string {
+	keys := commandKeys(conf)
+	if len(keys) == 0 {
+		return ""
+	}
+
+	return keys[0]
+}
+
+// longestFirst orders keys so "!!" is tried before "!" and isn't mistaken for it.
+func longestFirst(keys []string) []string {
+	sort.SliceStable(keys, func(i, j int) bool {
+		return len(keys[i]) > len(keys[j])
+	})
+
+	return keys
+}
+
+// trimCommandKey removes whichever configured key a command name starts with, e.g. in `help .ping`.
+func trimCommandKey(conf *models.Configuration, name string) string {
+	for _, key := range longestFirst(commandKeys(conf)) {
+		if strings.HasPrefix(name, key) {
+			return strings.TrimPrefix(name, key)
+		}
+	}
+
+	return name
+}
+
+// commandPrefixes lists every prefix a guild answers to. Mentioning the bot always works,
+// configured keys are tried longest first.
+func commandPrefixes(s discord.Session, conf *models.Configuration) []string {
+	keys := longestFirst(commandKeys(conf))
+
+	botID := s.GetState().User.ID
+	return append([]string{"<@" + botID + ">", "<@!" + botID + ">"}, keys...)
+}
+
+// StripPrefix returns the message content following a command prefix, or false if it has none.
+func (c *Commands) StripPrefix(s discord.Session, conf *models.Configuration, content string) (string, bool) {
+	for _, prefix := range commandPrefixes(s, conf) {
+		if len(content) < len(prefix) || !strings.EqualFold(content[:len(prefix)], prefix) {
+			continue
+		}
+
+		rest := strings.TrimLeftFunc(content[len(prefix):], unicode.IsSpace)
+		if len(rest) == 0 {
+			return "", false
+		}
+
+		return rest, true
+	}
+
+	return "", false
+}
@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/foxtrot/scuzzy/models"
+)
+
+func TestCommandKeys(t *testing.T) {
+	conf := &models.Configuration{CommandKeys: []string{"!", "!!"}}
+
+	if key := commandKey(conf); key != "!" {
+		t.Errorf("commandKey = %q, want the first of command_keys", key)
+	}
+	if name := trimCommandKey(conf, "!!ping"); name != "ping" {
+		t.Errorf("trimCommandKey = %q, want the longest key trimmed", name)
+	}
+
+	conf.CommandKey = "."
+	if keys := commandKeys(conf); len(keys) != 3 || keys[0] != "." {
+		t.Errorf("commandKeys = %v, want command_key first", keys)
+	}
+}
+
+func TestRepliesUseConfiguredKey(t *testing.T) {
+	b := newTestBot(t, map[string]interface{}{"command_key": "", "command_keys": []string{"!"}})
+
+	b.run("!pnig")
+	sent := b.s.Sent[testChannelID]
+	if len(sent) != 1 || len(sent[0].Embeds) == 0 || !strings.Contains(sent[0].Embeds[0].Description, "`!ping`") {
+		t.Fatalf("expected a suggestion using the configured key, got %v", sent)
+	}
+
+	b.run("!help")
+	sent = b.s.Sent[testChannelID]
+	if len(sent) != 2 || len(sent[1].Embeds) == 0 || !strings.Contains(sent[1].Embeds[0].Description, "`!help <command>`") {
+		t.Errorf("expected the help listing to use the configured key, got %v", sent)
+	}
+}
@@ -0,0 +1,355 @@
+package commands
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/discord"
+)
+
+const (
+	// maxPurge is the most messages one purge can delete.
+	maxPurge = 1000
+	// maxPurgeScan is the most messages one purge looks through for ones matching its filters.
+	maxPurgeScan = 5000
+	// purgePage is how many messages are fetched, and bulk deleted, at a time.
+	purgePage = 100
+	// bulkDeleteAge is how old a message can be and still be bulk deleted, less a little leeway.
+	bulkDeleteAge = 14*24*time.Hour - time.Hour
+	// maxPurgeOld is the most messages too old to bulk delete one purge deletes. They go one request
+	// at a time, slowly rate limited, so any more wouldn't finish inside the command's timeout.
+	maxPurgeOld = 100
+)
+
+var linkRegex = regexp.MustCompile(`(?i)https?://\S+|discord\.gg/\S+`)
+
+// purgeFilter decides which messages a purge deletes. A message has to match every filter given.
+type purgeFilter struct {
+	Users       map[string]bool
+	Bots        bool
+	Contains    []string
+	Regex       *regexp.Regexp
+	Links       bool
+	Attachments bool
+	// Before and After limit the purge to messages between two message IDs.
+	Before string
+	After  string
+}
+
+// parsePurgeFilter reads a purge's filters, e.g. `user @bob contains "free nitro" links`.
+func parsePurgeFilter(text string) (*purgeFilter, error) {
+	f := &purgeFilter{Users: make(map[string]bool)}
+
+	tokens := tokenizeArguments(text)
+	for k := 0; k < len(tokens); k++ {
+		name := strings.ToLower(tokens[k].Value)
+
+		switch name {
+		case "bots", "bot":
+			f.Bots = true
+			continue
+		case "links", "link":
+			f.Links = true
+			continue
+		case "attachments", "attachment", "files", "file":
+			f.Attachments = true
+			continue
+		}
+
+		// Mentioning a user on its own is the same as `user @user`
+		if id, ok := mentionID(tokens[k].Value, userMentionRegex); ok {
+			f.Users[id] = true
+			continue
+		}
+
+		if name != "user" && name != "contains" && name != "regex" && name != "before" && name != "after" {
+			return nil, errors.New("Unknown purge filter `" + tokens[k].Value + "`.")
+		}
+		if k+1 >= len(tokens) {
+			return nil, errors.New("The `" + name + "` filter needs a value.")
+		}
+		k++
+		value := tokens[k].Value
+
+		switch name {
+		case "user":
+			id, ok := mentionID(value, userMentionRegex)
+			if !ok {
+				return nil, errors.New("`" + value + "` is not a user mention or ID.")
+			}
+			f.Users[id] = true
+		case "contains":
+			f.Contains = append(f.Contains, strings.ToLower(value))
+		case "regex":
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return nil, errors.New("Invalid regex `" + value + "`: " + err.Error())
+			}
+			f.Regex = re
+		case "before", "after":
+			id, ok := purgeMessageID(value)
+			if !ok {
+				return nil, errors.New("`" + value + "` is not a message ID or link.")
+			}
+			if name == "before" {
+				f.Before = id
+			} else {
+				f.After = id
+			}
+		}
+	}
+
+	return f, nil
+}
+
+// purgeMessageID reads a message ID, or the ID on the end of a message link.
+func purgeMessageID(raw string) (string, bool) {
+	if messageLinkRegex.MatchString(raw) {
+		raw = raw[strings.LastIndex(raw, "/")+1:]
+	}
+	if snowflakeRegex.MatchString(raw) {
+		return raw, true
+	}
+
+	return "", false
+}
+
+func (f *purgeFilter) match(msg *discordgo.Message) bool {
+	if len(f.Users) > 0 && (msg.Author == nil || !f.Users[msg.Author.ID]) {
+		return false
+	}
+	if f.Bots && (msg.Author == nil || !msg.Author.Bot) {
+		return false
+	}
+	if f.Links && !linkRegex.MatchString(msg.Content) {
+		return false
+	}
+	if f.Attachments && len(msg.Attachments) == 0 {
+		return false
+	}
+	if f.Regex != nil && !f.Regex.MatchString(msg.Content) {
+		return false
+	}
+
+	content := strings.ToLower(msg.Content)
+	for _, text := range f.Contains {
+		if !strings.Contains(content, text) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// snowflakeBefore reports whether the snowflake a is older than b.
+func snowflakeBefore(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+
+	return a < b
+}
+
+// collectPurge pages back through the channel for up to count messages matching the filter,
+// newest first. The command's own message is left for its response policy to deal with.
+func (c *Commands) collectPurge(s discord.Session, m *ScuzzyContext, f *purgeFilter, count int) ([]*discordgo.Message, error) {
+	var msgs []*discordgo.Message
+
+	before := f.Before
+	for scanned := 0; scanned < maxPurgeScan; {
+		page, err := s.ChannelMessages(m.ChannelID, purgePage, before, "", "", m.requestOptions()...)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, msg := range page {
+			scanned++
+			if len(f.After) > 0 && !snowflakeBefore(f.After, msg.ID) {
+				return msgs, nil
+			}
+			if msg.ID == m.ID || !f.match(msg) {
+				continue
+			}
+
+			msgs = append(msgs, msg)
+			if len(msgs) == count {
+				return msgs, nil
+			}
+		}
+
+		if len(page) < purgePage {
+			break
+		}
+		before = page[len(page)-1].ID
+	}
+
+	return msgs, nil
+}
+
+// deletePurge deletes msgs, bulk deleting where Discord allows it and falling back to deleting
+// one at a time for messages too old to bulk delete, up to maxPurgeOld of them. The messages deleted
+// are returned even on error, along with how many old ones were left over the limit.
+func deletePurge(s discord.Session, m *ScuzzyContext, msgs []*discordgo.Message) ([]*discordgo.Message, int, error) {
+	var recent, old []*discordgo.Message
+	for _, msg := range msgs {
+		created, err := discordgo.SnowflakeTimestamp(msg.ID)
+		if err == nil && time.Since(created) < bulkDeleteAge {
+			recent = append(recent, msg)
+		} else {
+			old = append(old, msg)
+		}
+	}
+
+	var deleted []*discordgo.Message
+	for len(recent) > 0 {
+		n := purgePage
+		if n > len(recent) {
+			n = len(recent)
+		}
+		chunk := recent[:n]
+		recent = recent[n:]
+
+		// Bulk deletes have to be of at least 2 messages
+		if len(chunk) == 1 {
+			old = append(old, chunk[0])
+			continue
+		}
+
+		var ids []string
+		for _, msg := range chunk {
+			ids = append(ids, msg.ID)
+		}
+
+		err := s.ChannelMessagesBulkDelete(m.ChannelID, ids, m.requestOptions()...)
+		if err != nil {
+			return deleted, 0, err
+		}
+		deleted = append(deleted, chunk...)
+	}
+
+	// The newest are kept, so purging again carries on from where this one stopped
+	skipped := 0
+	if len(old) > maxPurgeOld {
+		skipped = len(old) - maxPurgeOld
+		old = old[:maxPurgeOld]
+	}
+
+	for _, msg := range old {
+		err := s.ChannelMessageDelete(m.ChannelID, msg.ID, m.requestOptions()...)
+		if err != nil && !isUnknownMessage(err) {
+			return deleted, skipped, err
+		}
+		deleted = append(deleted, msg)
+	}
+
+	return deleted, skipped, nil
+}
+
+// purgeTranscript writes out the deleted messages, oldest first.
+func purgeTranscript(msgs []*discordgo.Message) string {
+	sorted := append([]*discordgo.Message(nil), msgs...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return snowflakeBefore(sorted[i].ID, sorted[j].ID)
+	})
+
+	var b strings.Builder
+	for _, msg := range sorted {
+		author := "Unknown"
+		if msg.Author != nil {
+			author = msg.Author.Username + "#" + msg.Author.Discriminator + " (" + msg.Author.ID + ")"
+		}
+
+		b.WriteString("[" + msg.Timestamp.UTC().Format("2006-01-02 15:04:05") + "] " + author + ": " + msg.Content + "\n")
+		for _, a := range msg.Attachments {
+			b.WriteString("    Attachment: " + a.URL + "\n")
+		}
+	}
+
+	return b.String()
+}
+
+// logPurge posts a summary of a purge, with a transcript of what it deleted, to the logging channel.
+func (c *Commands) logPurge(s discord.Session, m *ScuzzyContext, deleted []*discordgo.Message) {
+	if len(m.Config.LoggingChannel) == 0 || len(deleted) == 0 {
+		return
+	}
+
+	msg := "`Channel` - <#" + m.ChannelID + ">\n"
+	msg += "`Moderator` - <@" + m.Author.ID + ">\n"
+	msg += "`Messages` - " + strconv.Itoa(len(deleted)) + "\n"
+	if filters := strings.TrimSpace(m.Args.String("filters")); len(filters) > 0 {
+		msg += "`Filters` - " + filters + "\n"
+	}
+
+	embed := c.CreateDefinedEmbed("Channel Purged", msg, "", m.Author)
+	_, err := s.ChannelMessageSendComplex(m.Config.LoggingChannel, &discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{embed},
+		Files: []*discordgo.File{{
+			Name:        "purge-" + m.ChannelID + "-" + time.Now().UTC().Format("20060102-150405") + ".txt",
+			ContentType: "text/plain",
+			Reader:      strings.NewReader(purgeTranscript(deleted)),
+		}},
+	})
+	if err != nil {
+		log.Println("[!] Error (Purge Transcript): " + err.Error())
+	}
+}
+
+func (c *Commands) handlePurgeChannel(s discord.Session, m *ScuzzyContext) error {
+	msgCount := m.Args.Int("count")
+
+	if msgCount < 1 {
+		return errors.New("You must purge at least 1 message.")
+	}
+	if msgCount > maxPurge {
+		return errors.New("You may only purge up to " + strconv.Itoa(maxPurge) + " messages at a time.")
+	}
+
+	f, err := parsePurgeFilter(m.Args.String("filters"))
+	if err != nil {
+		return err
+	}
+
+	chanMsgs, err := c.collectPurge(s, m, f, msgCount)
+	if err != nil {
+		return err
+	}
+	if len(chanMsgs) == 0 {
+		return errors.New("There are no messages to purge.")
+	}
+
+	msg := c.CreateDefinedEmbed("Purge Channel", "Purging `"+strconv.Itoa(len(chanMsgs))+"` messages.", "", m.Author)
+	r, err := c.SendEmbed(s, m, msg)
+	if err != nil {
+		return err
+	}
+
+	deleted, skipped, purgeErr := deletePurge(s, m, chanMsgs)
+	c.logPurge(s, m, deleted)
+
+	err = c.DeleteMessage(s, m, r.ID)
+	if err != nil && !isUnknownMessage(err) {
+		return err
+	}
+	if purgeErr != nil {
+		return purgeErr
+	}
+
+	text := "Purged `" + strconv.Itoa(len(deleted)) + "` messages!"
+	if skipped > 0 {
+		text += "\nLeft `" + strconv.Itoa(skipped) + "` messages too old to bulk delete, only " + strconv.Itoa(maxPurgeOld) + " of those go per purge. Purge again to carry on."
+	}
+	msg = c.CreateDefinedEmbed("Purge Channel", text, "success", m.Author)
+	_, err = c.SendCannedEmbed(s, m, "purge", map[string]string{"count": strconv.Itoa(len(deleted))}, msg)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package commands
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// snowflakeAt makes a message ID for a message sent at when.
+func snowflakeAt(when time.Time, seq int) string {
+	ms := when.UnixNano()/int64(time.Millisecond) - 1420070400000
+	return strconv.FormatInt(ms<<22+int64(seq), 10)
+}
+
+// post adds messages from member to the test channel's history, sent age ago. Returns their IDs, oldest first.
+func (b *testBot) post(member *discordgo.Member, age time.Duration, contents ...string) []string {
+	b.t.Helper()
+
+	// The channel history is the state's, which only keeps the newest messages
+	b.s.State.MaxMessageCount = maxPurgeScan
+
+	var ids []string
+	for _, content := range contents {
+		b.nextID++
+		msg := &discordgo.Message{
+			ID:        snowflakeAt(time.Now().Add(-age), b.nextID),
+			ChannelID: testChannelID,
+			GuildID:   testGuildID,
+			Content:   content,
+			Author:    member.User,
+		}
+		err := b.s.State.MessageAdd(msg)
+		if err != nil {
+			b.t.Fatal(err)
+		}
+		ids = append(ids, msg.ID)
+	}
+
+	return ids
+}
+
+func TestParsePurgeFilter(t *testing.T) {
+	link := "https://discord.com/channels/" + testGuildID + "/" + testChannelID + "/700000000000000009"
+	f, err := parsePurgeFilter(`<@` + testUserID + `> user 700000000000000002 bots links files contains "Free Nitro" regex "^a+$" before ` + link + ` after 700000000000000001`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Users) != 2 || !f.Users[testUserID] || !f.Users["700000000000000002"] {
+		t.Errorf("expected both users, got %v", f.Users)
+	}
+	if !f.Bots || !f.Links || !f.Attachments || f.Regex == nil {
+		t.Errorf("flags not set: %+v", f)
+	}
+	if len(f.Contains) != 1 || f.Contains[0] != "free nitro" {
+		t.Errorf("contains should be lowercased, got %v", f.Contains)
+	}
+	if f.Before != "700000000000000009" || f.After != "700000000000000001" {
+		t.Errorf("got before %q, after %q", f.Before, f.After)
+	}
+
+	invalid := []string{
+		"everyone",
+		"contains",
+		"user bob",
+		`regex "("`,
+		"before yesterday",
+	}
+	for _, text := range invalid {
+		if _, err := parsePurgeFilter(text); err == nil {
+			t.Errorf("%q: expected an error", text)
+		}
+	}
+}
+
+func TestPurgeFilterMatch(t *testing.T) {
+	user := &discordgo.User{ID: testUserID}
+	bot := &discordgo.User{ID: testBotID, Bot: true}
+
+	tests := []struct {
+		filter string
+		msg    *discordgo.Message
+		match  bool
+	}{
+		{"", &discordgo.Message{Author: user}, true},
+		{"<@" + testUserID + ">", &discordgo.Message{Author: user}, true},
+		{"<@" + testUserID + ">", &discordgo.Message{Author: bot}, false},
+		{"<@" + testUserID + ">", &discordgo.Message{}, false},
+		{"bots", &discordgo.Message{Author: bot}, true},
+		{"bots", &discordgo.Message{Author: user}, false},
+		{"links", &discordgo.Message{Author: user, Content: "join discord.gg/abc"}, true},
+		{"links", &discordgo.Message{Author: user, Content: "no links here"}, false},
+		{"attachments", &discordgo.Message{Author: user, Attachments: []*discordgo.MessageAttachment{{}}}, true},
+		{"attachments", &discordgo.Message{Author: user}, false},
+		{`contains "nitro"`, &discordgo.Message{Author: user, Content: "FREE NITRO"}, true},
+		{`contains "nitro" contains "steam"`, &discordgo.Message{Author: user, Content: "free nitro"}, false},
+		{`regex "^a+$"`, &discordgo.Message{Author: user, Content: "aaa"}, true},
+		{`regex "^a+$"`, &discordgo.Message{Author: user, Content: "aab"}, false},
+		{`bots links`, &discordgo.Message{Author: bot, Content: "hello"}, false},
+	}
+	for _, test := range tests {
+		f, err := parsePurgeFilter(test.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.match(test.msg) != test.match {
+			t.Errorf("%q on %q: expected %v", test.filter, test.msg.Content, test.match)
+		}
+	}
+}
+
+func TestCollectPurge(t *testing.T) {
+	b := newTestBot(t, nil)
+	bob := b.member(testUserID)
+
+	var ids []string
+	for k := 0; k < 250; k++ {
+		author := b.admin
+		if k%2 == 0 {
+			author = bob
+		}
+		ids = append(ids, b.post(author, time.Hour, "message "+strconv.Itoa(k))...)
+	}
+
+	m := &ScuzzyContext{MessageCreate: &discordgo.MessageCreate{Message: &discordgo.Message{ID: ids[249], ChannelID: testChannelID}}}
+	collect := func(filter string, count int) []*discordgo.Message {
+		t.Helper()
+
+		f, err := parsePurgeFilter(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs, err := b.collectPurge(b.s, m, f, count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msgs
+	}
+
+	// The invoking message is left alone
+	msgs := collect("", 10)
+	if len(msgs) != 10 || msgs[0].ID != ids[248] || msgs[9].ID != ids[239] {
+		t.Errorf("expected the ten before the command, newest first, got %d", len(msgs))
+	}
+
+	// Filters page back as far as they need to
+	msgs = collect("<@"+testUserID+">", 1000)
+	if len(msgs) != 125 {
+		t.Errorf("expected all of bob's messages, got %d", len(msgs))
+	}
+	for _, msg := range msgs {
+		if msg.Author.ID != testUserID {
+			t.Fatalf("collected someone else's message: %+v", msg)
+		}
+	}
+
+	msgs = collect("before "+ids[100]+" after "+ids[10], 1000)
+	if len(msgs) != 89 || msgs[0].ID != ids[99] || msgs[88].ID != ids[11] {
+		t.Errorf("expected the messages between, got %d", len(msgs))
+	}
+}
+
+func TestPurge(t *testing.T) {
+	b := newTestBot(t, nil)
+	bob := b.member(testUserID)
+
+	b.post(bob, time.Hour, "one", "two", "three")
+	b.post(b.admin, time.Hour, "keep")
+	b.run(".purge 10 <@" + testUserID + ">")
+
+	bulk := b.s.CallsTo("ChannelMessagesBulkDelete")
+	if len(bulk) != 1 || len(bulk[0].Args[1].([]string)) != 3 {
+		t.Fatalf("expected bob's messages bulk deleted, got %v", bulk)
+	}
+	if msgs, _ := b.s.ChannelMessages(testChannelID, 100, "", "", ""); !strings.Contains(messageContents(msgs), "keep") {
+		t.Error("the admin's message shouldn't be purged")
+	}
+	if sent := b.s.Sent[testLogID]; len(sent) != 1 || sent[0].Embeds[0].Title != "Channel Purged" {
+		t.Errorf("expected the purge logged, got %v", sent)
+	}
+
+	b.run(".purge 10 <@700000000000000001>")
+	if msg := b.lastSent(); !strings.Contains(msg.Embeds[0].Description, "no messages") {
+		t.Errorf("expected nothing to purge, got %+v", msg.Embeds[0])
+	}
+}
+
+func TestPurgeOld(t *testing.T) {
+	b := newTestBot(t, nil)
+	bob := b.member(testUserID)
+
+	old := make([]string, maxPurgeOld+20)
+	for k := range old {
+		old[k] = "old"
+	}
+	oldIDs := b.post(bob, 30*24*time.Hour, old...)
+	b.post(bob, time.Hour, "new", "new")
+
+	b.s.Calls = nil
+	b.run(".purge 1000 <@" + testUserID + ">")
+
+	isOld := make(map[string]bool)
+	for _, id := range oldIDs {
+		isOld[id] = true
+	}
+	deletes := 0
+	for _, call := range b.s.CallsTo("ChannelMessageDelete") {
+		if isOld[call.Args[1].(string)] {
+			deletes++
+		}
+	}
+	if deletes != maxPurgeOld {
+		t.Errorf("expected %d old messages deleted one at a time, got %d", maxPurgeOld, deletes)
+	}
+
+	// The oldest are left for the next purge
+	f, _ := parsePurgeFilter("<@" + testUserID + ">")
+	var left []*discordgo.Message
+	msgs, _ := b.s.ChannelMessages(testChannelID, 100, "", "", "")
+	for _, msg := range msgs {
+		if f.match(msg) {
+			left = append(left, msg)
+		}
+	}
+	if len(left) != 20 || left[0].ID != oldIDs[19] {
+		t.Errorf("expected the oldest 20 left, got %d", len(left))
+	}
+	if msg := b.lastSent(); !strings.Contains(msg.Embeds[0].Description, "Left `20`") {
+		t.Errorf("expected the skipped messages mentioned, got %q", msg.Embeds[0].Description)
+	}
+}
+
+func messageContents(msgs []*discordgo.Message) string {
+	var contents []string
+	for _, msg := range msgs {
+		contents = append(contents, msg.Content)
+	}
+
+	return strings.Join(contents, "\n")
+}
@@ -0,0 +1,383 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/discord"
+	"github.com/foxtrot/scuzzy/models"
+)
+
+const (
+	// raidEndTask is the kind of the scheduled task that ends raid mode. Raid mode is on while one is pending.
+	raidEndTask = "raidend"
+
+	defaultJoinFloodWindow = 10 * time.Second
+	defaultRaidCooldown    = 10 * time.Minute
+	raidReason             = "Raid protection"
+)
+
+// raidTask is the data of the scheduled end of raid mode.
+type raidTask struct {
+	StartedAt time.Time `json:"started_at"`
+	// PreviousLevel is the verification level to go back to, when raid mode raised it.
+	PreviousLevel *discordgo.VerificationLevel `json:"previous_level,omitempty"`
+	// Joined lists the members who joined during the raid and weren't kicked. They are welcomed
+	// and given the join roles once it ends.
+	Joined []string `json:"joined,omitempty"`
+}
+
+// raidState is a guild's recent joins, and when its raid mode was last written to storage.
+type raidState struct {
+	joins []time.Time
+	saved time.Time
+}
+
+func raidTaskData(task ScheduledTask) raidTask {
+	var rt raidTask
+	json.Unmarshal(task.Data, &rt)
+
+	return rt
+}
+
+func joinFloodWindow(conf *models.Configuration) time.Duration {
+	if conf.JoinFloodWindow <= 0 {
+		return defaultJoinFloodWindow
+	}
+
+	return time.Duration(conf.JoinFloodWindow) * time.Second
+}
+
+func raidCooldown(conf *models.Configuration) time.Duration {
+	if conf.RaidCooldown <= 0 {
+		return defaultRaidCooldown
+	}
+
+	return time.Duration(conf.RaidCooldown) * time.Second
+}
+
+func raidVerificationLevel(conf *models.Configuration) discordgo.VerificationLevel {
+	if conf.RaidVerificationLevel <= 0 || conf.RaidVerificationLevel > int(discordgo.VerificationLevelVeryHigh) {
+		return discordgo.VerificationLevelHigh
+	}
+
+	return discordgo.VerificationLevel(conf.RaidVerificationLevel)
+}
+
+var verificationLevelNames = map[discordgo.VerificationLevel]string{
+	discordgo.VerificationLevelNone:     "None",
+	discordgo.VerificationLevelLow:      "Low",
+	discordgo.VerificationLevelMedium:   "Medium",
+	discordgo.VerificationLevelHigh:     "High",
+	discordgo.VerificationLevelVeryHigh: "Very High",
+}
+
+// raidMode returns the pending end of a guild's raid mode, if it is in raid mode.
+func (c *Commands) raidMode(guildID string) (ScheduledTask, bool) {
+	tasks := c.Tasks(guildID, raidEndTask)
+	if len(tasks) == 0 {
+		return ScheduledTask{}, false
+	}
+
+	return tasks[0], true
+}
+
+// raidState returns a guild's raid detection state. The caller holds raidMu.
+func (c *Commands) raidState(guildID string) *raidState {
+	state, ok := c.raids[guildID]
+	if !ok {
+		state = &raidState{}
+		c.raids[guildID] = state
+	}
+
+	return state
+}
+
+// countJoin adds a join to the guild's sliding window and returns how many joins it holds.
+// The caller holds raidMu.
+func (c *Commands) countJoin(guildID string, window time.Duration) int {
+	state := c.raidState(guildID)
+	now := time.Now()
+
+	var joins []time.Time
+	for _, t := range state.joins {
+		if now.Sub(t) < window {
+			joins = append(joins, t)
+		}
+	}
+	state.joins = append(joins, now)
+
+	return len(state.joins)
+}
+
+// startRaid puts a guild into raid mode, raising its verification level and alerting staff.
+// Raising the level is best effort, raid mode starts even if the bot isn't allowed to.
+// The caller holds raidMu.
+func (c *Commands) startRaid(s discord.Session, guildID string, conf *models.Configuration, why string) error {
+	rt := raidTask{StartedAt: time.Now()}
+
+	level := raidVerificationLevel(conf)
+	raised, err := c.raiseVerificationLevel(s, guildID, level, &rt)
+	if err != nil {
+		log.Println("[!] Error (Raid Mode): " + err.Error())
+		raised = "I couldn't raise the verification level: " + err.Error() + "\n"
+	}
+
+	_, err = c.ScheduleTask(s, guildID, raidEndTask, time.Now().Add(raidCooldown(conf)), rt)
+	if err != nil {
+		return err
+	}
+	c.raidState(guildID).saved = time.Now()
+
+	msg := why + "\n\n" + raised
+	switch conf.RaidAction {
+	case "kick":
+		msg += "Members joining now will be kicked.\n"
+	case "timeout":
+		msg += "Members joining now will be timed out.\n"
+	}
+	msg += "Members joining now will be welcomed and given join roles once the raid is over. Raid mode ends `" + formatDuration(raidCooldown(conf)) + "` after the last join, or with `antiraid off`."
+	c.logGuildEvent(s, guildID, c.CreateDefinedEmbed("Raid Mode On", msg, "error", nil))
+
+	return nil
+}
+
+// raiseVerificationLevel raises a guild's verification level to level if it is lower, noting the
+// level to go back to in rt. It returns a line for the raid alert.
+func (c *Commands) raiseVerificationLevel(s discord.Session, guildID string, level discordgo.VerificationLevel, rt *raidTask) (string, error) {
+	g, err := s.GetState().Guild(guildID)
+	if err != nil {
+		g, err = s.Guild(guildID)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	previous := g.VerificationLevel
+	if previous >= level {
+		return "", nil
+	}
+
+	_, err = s.GuildEdit(guildID, &discordgo.GuildParams{VerificationLevel: &level}, discordgo.WithAuditLogReason(raidReason))
+	if err != nil {
+		return "", err
+	}
+	rt.PreviousLevel = &previous
+
+	return "The verification level has been raised to `" + verificationLevelNames[level] + "`.\n", nil
+}
+
+// endRaid takes a guild out of raid mode, putting back the verification level it had before.
+// The caller holds raidMu, and welcomes rt.Joined once it has let go of it.
+func (c *Commands) endRaid(s discord.Session, guildID string, rt raidTask, why string) {
+	// Start counting joins afresh, or the joins that started the raid would start it again
+	delete(c.raids, guildID)
+
+	msg := why + "\n"
+	if rt.PreviousLevel != nil {
+		_, err := s.GuildEdit(guildID, &discordgo.GuildParams{VerificationLevel: rt.PreviousLevel}, discordgo.WithAuditLogReason(raidReason))
+		if err != nil {
+			log.Println("[!] Error (Raid Mode): " + err.Error())
+			msg += "I couldn't put the verification level back to `" + verificationLevelNames[*rt.PreviousLevel] + "`: " + err.Error() + "\n"
+		} else {
+			msg += "The verification level is back to `" + verificationLevelNames[*rt.PreviousLevel] + "`.\n"
+		}
+	}
+	msg += "Welcome messages and join roles have resumed"
+	if len(rt.Joined) > 0 {
+		msg += ", the `" + strconv.Itoa(len(rt.Joined)) + "` members who joined during the raid are being welcomed now"
+	}
+	msg += "."
+
+	c.logGuildEvent(s, guildID, c.CreateDefinedEmbed("Raid Mode Off", msg, "success", nil))
+}
+
+// welcomeRaidJoins welcomes the members who joined during a raid and are still around.
+func (c *Commands) welcomeRaidJoins(s discord.Session, guildID string, userIDs []string) {
+	for _, userID := range userIDs {
+		member, err := s.GuildMember(guildID, userID)
+		if err != nil {
+			// Left or was removed since
+			continue
+		}
+
+		err = c.welcomeMember(s, guildID, member)
+		if err != nil {
+			log.Println("[!] Error (Raid Mode): " + err.Error())
+		}
+	}
+}
+
+func (c *Commands) runRaidEndTask(s discord.Session, guildID string, task ScheduledTask) error {
+	c.raidMu.Lock()
+
+	// Ended by hand while waiting for the lock
+	pending, ok := c.raidMode(guildID)
+	if !ok || pending.ID != task.ID {
+		c.raidMu.Unlock()
+		return nil
+	}
+
+	rt := raidTaskData(pending)
+	c.endRaid(s, guildID, rt, "No one has joined for a while.")
+	c.raidMu.Unlock()
+
+	c.welcomeRaidJoins(s, guildID, rt.Joined)
+
+	return nil
+}
+
+// checkRaid counts a member joining and reports whether the guild is in raid mode, starting it
+// once JoinFloodThreshold is crossed. Every join during a raid puts its end back, which is only
+// written to storage once per join window.
+func (c *Commands) checkRaid(s discord.Session, guildID string, conf *models.Configuration) (bool, error) {
+	c.raidMu.Lock()
+	defer c.raidMu.Unlock()
+
+	window := joinFloodWindow(conf)
+	joins := c.countJoin(guildID, window)
+
+	task, raid := c.raidMode(guildID)
+	if raid {
+		state := c.raidState(guildID)
+		save := time.Since(state.saved) >= window
+		if save {
+			state.saved = time.Now()
+		}
+
+		task.At = time.Now().Add(raidCooldown(conf))
+		return true, c.PostponeTask(guildID, task, save)
+	}
+
+	if conf.JoinFloodThreshold <= 0 || joins < conf.JoinFloodThreshold {
+		return false, nil
+	}
+
+	why := "`" + strconv.Itoa(joins) + "` members joined in the last `" + formatDuration(window) + "`."
+	return true, c.startRaid(s, guildID, conf, why)
+}
+
+// queueRaidJoin notes a member who joined during a raid, to be welcomed once it ends.
+func (c *Commands) queueRaidJoin(guildID string, userID string) error {
+	c.raidMu.Lock()
+	defer c.raidMu.Unlock()
+
+	task, raid := c.raidMode(guildID)
+	if !raid {
+		return nil
+	}
+
+	rt := raidTaskData(task)
+	rt.Joined = append(rt.Joined, userID)
+	data, err := json.Marshal(rt)
+	if err != nil {
+		return err
+	}
+	task.Data = data
+
+	// Saved along with the raid's end
+	return c.PostponeTask(guildID, task, false)
+}
+
+// handleRaidJoin takes the guild's RaidAction against a member who joined during a raid, and
+// queues them to be welcomed afterwards if they are still around.
+func (c *Commands) handleRaidJoin(s discord.Session, guildID string, conf *models.Configuration, userID string) error {
+	botID := s.GetState().User.ID
+
+	switch conf.RaidAction {
+	case "kick":
+		err := c.Actions.KickUser(s, guildID, userID, botID, raidReason)
+		if err == nil {
+			return nil
+		}
+		log.Println("[!] Error (Raid Mode): " + err.Error())
+	case "timeout":
+		err := c.TimeoutUser(s, guildID, userID, botID, raidCooldown(conf), raidReason)
+		if err != nil {
+			log.Println("[!] Error (Raid Mode): " + err.Error())
+		}
+	}
+
+	return c.queueRaidJoin(guildID, userID)
+}
+
+func (c *Commands) handleAntiRaid(s discord.Session, m *ScuzzyContext) error {
+	switch m.Args.String("mode") {
+	case "on":
+		c.raidMu.Lock()
+		err := c.startAntiRaid(s, m)
+		c.raidMu.Unlock()
+		if err != nil {
+			return err
+		}
+
+		msg := c.CreateDefinedEmbed("Anti Raid", "Raid mode is on.", "success", m.Author)
+		_, err = c.SendEmbed(s, m, msg)
+		return err
+	case "off":
+		c.raidMu.Lock()
+		rt, err := c.stopAntiRaid(s, m)
+		c.raidMu.Unlock()
+		if err != nil {
+			return err
+		}
+
+		msg := c.CreateDefinedEmbed("Anti Raid", "Raid mode is off.", "success", m.Author)
+		_, err = c.SendEmbed(s, m, msg)
+		if err != nil {
+			return err
+		}
+
+		c.welcomeRaidJoins(s, m.GuildID, rt.Joined)
+		return nil
+	}
+
+	msg := "Raid mode is off.\n"
+	if task, raid := c.raidMode(m.GuildID); raid {
+		rt := raidTaskData(task)
+		msg = "Raid mode has been on for `" + formatDuration(time.Since(rt.StartedAt)) + "` and ends in `" + formatDuration(time.Until(task.At)) + "` unless more members join.\n"
+		msg += "`" + strconv.Itoa(len(rt.Joined)) + "` members are waiting to be welcomed.\n"
+	}
+	if m.Config.JoinFloodThreshold > 0 {
+		msg += "It starts when `" + strconv.Itoa(m.Config.JoinFloodThreshold) + "` members join within `" + formatDuration(joinFloodWindow(m.Config)) + "`."
+	} else {
+		msg += "Raid detection is disabled, set `JoinFloodThreshold` to enable it."
+	}
+
+	embed := c.CreateDefinedEmbed("Anti Raid", msg, "", m.Author)
+	_, err := c.SendEmbed(s, m, embed)
+	return err
+}
+
+// startAntiRaid turns raid mode on by hand. The caller holds raidMu.
+func (c *Commands) startAntiRaid(s discord.Session, m *ScuzzyContext) error {
+	if _, raid := c.raidMode(m.GuildID); raid {
+		return errors.New("Raid mode is already on.")
+	}
+
+	return c.startRaid(s, m.GuildID, m.Config, "Turned on by <@"+m.Author.ID+">.")
+}
+
+// stopAntiRaid turns raid mode off by hand. The caller holds raidMu.
+func (c *Commands) stopAntiRaid(s discord.Session, m *ScuzzyContext) (raidTask, error) {
+	task, raid := c.raidMode(m.GuildID)
+	if !raid {
+		return raidTask{}, errors.New("Raid mode is not on.")
+	}
+
+	_, err := c.CancelTasks(m.GuildID, raidEndTask, func(task ScheduledTask) bool {
+		return true
+	})
+	if err != nil {
+		return raidTask{}, err
+	}
+
+	rt := raidTaskData(task)
+	c.endRaid(s, m.GuildID, rt, "Turned off by <@"+m.Author.ID+">.")
+
+	return rt, nil
+}
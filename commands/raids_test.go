@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func newRaidBot(t *testing.T, action string) *testBot {
+	return newTestBot(t, map[string]interface{}{
+		"JoinFloodThreshold": 3,
+		"raid_action":        action,
+		"welcome_text":       "Welcome {{.User.Username}}",
+		"join_role_ids":      []string{testJoinRole},
+	})
+}
+
+func joinID(k int) string {
+	return strconv.Itoa(700000000000000000 + k)
+}
+
+func TestRaidMode(t *testing.T) {
+	b := newRaidBot(t, "")
+
+	b.join(joinID(1))
+	b.join(joinID(2))
+	if _, raid := b.raidMode(testGuildID); raid {
+		t.Fatal("raid mode shouldn't start under the threshold")
+	}
+	if len(b.s.Sent["dm-"+joinID(1)]) != 1 || !hasRole(b.member(joinID(2)), testJoinRole) {
+		t.Error("members joining before a raid should be welcomed")
+	}
+
+	b.join(joinID(3))
+	task, raid := b.raidMode(testGuildID)
+	if !raid {
+		t.Fatal("expected raid mode to start")
+	}
+	if b.guild.VerificationLevel != discordgo.VerificationLevelHigh {
+		t.Errorf("verification level is %v, want High", b.guild.VerificationLevel)
+	}
+
+	b.join(joinID(4))
+	for _, id := range []string{joinID(3), joinID(4)} {
+		if len(b.s.Sent["dm-"+id]) != 0 || hasRole(b.member(id), testJoinRole) {
+			t.Errorf("%s shouldn't be welcomed during the raid", id)
+		}
+	}
+
+	// Joiners who leave before the raid ends aren't welcomed
+	err := b.s.State.MemberRemove(b.member(joinID(4)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task, _ = b.raidMode(testGuildID)
+	if joined := raidTaskData(task).Joined; len(joined) != 2 {
+		t.Errorf("expected both raid joiners to be queued, got %v", joined)
+	}
+
+	// End the raid the way the scheduler would once it is due
+	b.tasksMu.Lock()
+	task.At = time.Now()
+	b.tasks[testGuildID][task.ID] = task
+	b.tasksMu.Unlock()
+	b.runTask(b.s, testGuildID, task.ID)
+	b.Wait()
+
+	if _, raid := b.raidMode(testGuildID); raid {
+		t.Error("expected raid mode to end")
+	}
+	if b.guild.VerificationLevel != discordgo.VerificationLevelNone {
+		t.Errorf("verification level is %v, want it put back", b.guild.VerificationLevel)
+	}
+	if len(b.s.Sent["dm-"+joinID(3)]) != 1 || !hasRole(b.member(joinID(3)), testJoinRole) {
+		t.Error("raid joiners still around should be welcomed once it ends")
+	}
+	if len(b.s.Sent["dm-"+joinID(4)]) != 0 {
+		t.Error("raid joiners who left shouldn't be welcomed")
+	}
+
+	// The joins that started the raid don't start another
+	b.join(joinID(5))
+	if _, raid := b.raidMode(testGuildID); raid {
+		t.Error("raid mode shouldn't start again straight away")
+	}
+}
+
+func TestRaidModeWithoutManageServer(t *testing.T) {
+	b := newRaidBot(t, "kick")
+	b.s.Errors["GuildEdit"] = errors.New("403 Forbidden: Missing Permissions")
+
+	for k := 1; k <= 4; k++ {
+		b.join(joinID(k))
+	}
+
+	task, raid := b.raidMode(testGuildID)
+	if !raid {
+		t.Fatal("raid mode should start even if the verification level can't be raised")
+	}
+	if raidTaskData(task).PreviousLevel != nil {
+		t.Error("there is no verification level to put back")
+	}
+	for _, id := range []string{joinID(3), joinID(4)} {
+		if b.member(id) != nil {
+			t.Errorf("%s should have been kicked", id)
+		}
+	}
+	if joined := raidTaskData(task).Joined; len(joined) != 0 {
+		t.Errorf("kicked joiners shouldn't be queued, got %v", joined)
+	}
+}
@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/discord"
+	"github.com/foxtrot/scuzzy/models"
+)
+
+// ScuzzyResponse is how a command's replies are delivered and cleaned up.
+type ScuzzyResponse struct {
+	// DeleteInvocation removes the message that ran the command once it succeeds.
+	DeleteInvocation bool
+	// DeleteAfter removes each reply once it has been up this long.
+	DeleteAfter time.Duration
+	// DM sends replies to the user directly. Slash commands reply ephemerally instead.
+	DM bool
+	// Ephemeral makes slash command replies visible only to the user.
+	Ephemeral bool
+}
+
+// commandResponse returns a command's response policy, or the guild's command_responses override.
+func (c *Commands) commandResponse(conf *models.Configuration, cmd *ScuzzyCommand) ScuzzyResponse {
+	for _, cR := range conf.CommandResponses {
+		if cR.Command == cmd.Path {
+			return ScuzzyResponse{
+				DeleteInvocation: cR.DeleteInvocation,
+				DeleteAfter:      time.Duration(cR.DeleteAfter) * time.Second,
+				DM:               cR.DM,
+				Ephemeral:        cR.Ephemeral,
+			}
+		}
+	}
+
+	return cmd.Response
+}
+
+func (m *ScuzzyContext) responseFlags() discordgo.MessageFlags {
+	if m.Response.Ephemeral || m.Response.DM {
+		return discordgo.MessageFlagsEphemeral
+	}
+
+	return 0
+}
+
+// replyChannel returns the channel text command replies go to, the user's DMs if the policy asks.
+func (c *Commands) replyChannel(s discord.Session, m *ScuzzyContext) (string, error) {
+	if !m.Response.DM {
+		return m.ChannelID, nil
+	}
+
+	userChannel, err := s.UserChannelCreate(m.Author.ID, m.requestOptions()...)
+	if err != nil {
+		return "", err
+	}
+
+	return userChannel.ID, nil
+}
+
+// applyResponse schedules a sent reply's deletion if the policy asks for it.
+func (c *Commands) applyResponse(s discord.Session, m *ScuzzyContext, r *discordgo.Message) {
+	if r == nil || m.Response.DeleteAfter <= 0 {
+		return
+	}
+
+	c.deleteAfter(s, m, r.ChannelID, m.Response.DeleteAfter, r.ID)
+}
+
+func (c *Commands) deleteAfter(s discord.Session, m *ScuzzyContext, channelID string, d time.Duration, messageIDs ...string) {
+	dt := deleteTask{ChannelID: channelID}
+	for _, id := range messageIDs {
+		// There is no invoking message to delete for slash commands
+		if m.Interaction != nil && id == m.ID {
+			continue
+		}
+		dt.MessageIDs = append(dt.MessageIDs, id)
+	}
+	if len(dt.MessageIDs) == 0 {
+		return
+	}
+
+	if m.Interaction != nil {
+		dt.AppID = m.Interaction.AppID
+		dt.Token = m.Interaction.Token
+	}
+
+	_, err := c.ScheduleTask(s, m.GuildID, "delete", time.Now().Add(d), dt)
+	if err != nil {
+		log.Println("[!] Error (Delete After): " + err.Error())
+	}
+}
+
+// DeleteAfter schedules messages in the command's channel for deletion once d has passed,
+// without holding up the handler. Pending deletions survive restarts.
+func (c *Commands) DeleteAfter(s discord.Session, m *ScuzzyContext, d time.Duration, messageIDs ...string) {
+	c.deleteAfter(s, m, m.ChannelID, d, messageIDs...)
+}
+
+// ResponseMiddleware cleans up the invoking message after a command succeeds.
+func (c *Commands) ResponseMiddleware(next ScuzzyHandler) ScuzzyHandler {
+	return func(s discord.Session, m *ScuzzyContext) error {
+		err := next(s, m)
+		if err != nil || !m.Response.DeleteInvocation || m.Interaction != nil {
+			return err
+		}
+
+		err = c.DeleteMessage(s, m, m.ID)
+		if err != nil && !isUnknownMessage(err) {
+			return err
+		}
+
+		return nil
+	}
+}
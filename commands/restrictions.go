@@ -0,0 +1,223 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/discord"
+	"github.com/foxtrot/scuzzy/models"
+	"github.com/foxtrot/scuzzy/permissions"
+)
+
+func (c *Commands) restrictionCommandName(m *ScuzzyContext) (string, error) {
+	name := strings.ToLower(trimCommandKey(m.Config, m.Args.String("command")))
+	if name == permissions.AllCommands || name == "all" {
+		return permissions.AllCommands, nil
+	}
+
+	fields := strings.Fields(name)
+	if len(fields) == 0 {
+		return "", &ArgumentError{Argument: "command", Reason: "expected a command name", Usage: c.CommandUsage(m.Config, m.Command)}
+	}
+
+	first := fields[0]
+	if _, ok := c.FindCommand(first); !ok {
+		if _, ok := c.FindTag(m.GuildID, first); ok {
+			return name, nil
+		}
+		return "", errors.New("Unknown command '" + name + "'")
+	}
+
+	return name, nil
+}
+
+func hasID(ids []string, id string) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func removeID(ids []string, id string) ([]string, bool) {
+	for k, v := range ids {
+		if v == id {
+			return append(ids[:k], ids[k+1:]...), true
+		}
+	}
+
+	return ids, false
+}
+
+func describeRestriction(cR models.CommandRestriction) string {
+	var targets []string
+	for _, cID := range cR.Channels {
+		targets = append(targets, "<#"+cID+">")
+	}
+	for _, cID := range cR.Categories {
+		targets = append(targets, "category <#"+cID+">")
+	}
+	for _, rID := range cR.Roles {
+		targets = append(targets, "<@&"+rID+">")
+	}
+	if len(targets) == 0 {
+		targets = append(targets, "nothing")
+	}
+
+	return "`" + cR.Command + "` - " + cR.Mode + "list " + strings.Join(targets, ", ")
+}
+
+func (c *Commands) handleListRestrictions(s discord.Session, m *ScuzzyContext) error {
+	msg := ""
+	for _, cR := range m.Config.CommandRestrictions {
+		msg += describeRestriction(cR) + "\n"
+	}
+	if len(msg) == 0 {
+		msg = "No command restrictions are configured."
+	}
+
+	eMsg := c.CreateDefinedEmbed("Command Restrictions", msg, "", m.Author)
+	_, err := c.SendEmbed(s, m, eMsg)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Commands) handleAddRestriction(s discord.Session, m *ScuzzyContext) error {
+	name, err := c.restrictionCommandName(m)
+	if err != nil {
+		return err
+	}
+	mode := m.Args.String("mode")
+
+	if !m.Args.Has("channel") && !m.Args.Has("role") {
+		return errors.New("Specify a channel, category or role to restrict.")
+	}
+
+	var cR models.CommandRestriction
+	err = c.UpdateConfig(s, m, func(conf *models.Configuration) error {
+		// Extend an existing rule for the same command and mode rather than stacking rules
+		idx := -1
+		for k, cR := range conf.CommandRestrictions {
+			if cR.Command == name && cR.Mode == mode {
+				idx = k
+				break
+			}
+		}
+		if idx < 0 {
+			conf.CommandRestrictions = append(conf.CommandRestrictions, models.CommandRestriction{
+				Command: name,
+				Mode:    mode,
+			})
+			idx = len(conf.CommandRestrictions) - 1
+		}
+		rule := &conf.CommandRestrictions[idx]
+
+		if m.Args.Has("channel") {
+			channel := m.Args.Channel("channel")
+			if channel.Type == discordgo.ChannelTypeGuildCategory {
+				if !hasID(rule.Categories, channel.ID) {
+					rule.Categories = append(rule.Categories, channel.ID)
+				}
+			} else if !hasID(rule.Channels, channel.ID) {
+				rule.Channels = append(rule.Channels, channel.ID)
+			}
+		}
+		if m.Args.Has("role") {
+			role := m.Args.Role("role")
+			if !hasID(rule.Roles, role.ID) {
+				rule.Roles = append(rule.Roles, role.ID)
+			}
+		}
+		cR = *rule
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	eMsg := c.CreateDefinedEmbed("Add Restriction", describeRestriction(cR), "success", m.Author)
+	_, err = c.SendEmbed(s, m, eMsg)
+	if err != nil {
+		return err
+	}
+
+	err = c.handleSaveConfig(s, m)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Commands) handleRemoveRestriction(s discord.Session, m *ScuzzyContext) error {
+	name, err := c.restrictionCommandName(m)
+	if err != nil {
+		return err
+	}
+
+	err = c.UpdateConfig(s, m, func(conf *models.Configuration) error {
+		removed := false
+		var crs []models.CommandRestriction
+		for _, cR := range conf.CommandRestrictions {
+			if cR.Command != name {
+				crs = append(crs, cR)
+				continue
+			}
+
+			// Without a target the whole rule goes
+			if !m.Args.Has("channel") && !m.Args.Has("role") {
+				removed = true
+				continue
+			}
+
+			var found bool
+			if m.Args.Has("channel") {
+				cID := m.Args.Channel("channel").ID
+				cR.Channels, found = removeID(cR.Channels, cID)
+				removed = removed || found
+				cR.Categories, found = removeID(cR.Categories, cID)
+				removed = removed || found
+			}
+			if m.Args.Has("role") {
+				cR.Roles, found = removeID(cR.Roles, m.Args.Role("role").ID)
+				removed = removed || found
+			}
+
+			// An empty whitelist would block the command everywhere
+			if len(cR.Channels) == 0 && len(cR.Categories) == 0 && len(cR.Roles) == 0 {
+				continue
+			}
+			crs = append(crs, cR)
+		}
+
+		if !removed {
+			return errors.New("No matching restriction for '" + name + "'")
+		}
+		conf.CommandRestrictions = crs
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	eMsg := c.CreateDefinedEmbed("Remove Restriction", "Updated restrictions for `"+name+"`.", "success", m.Author)
+	_, err = c.SendEmbed(s, m, eMsg)
+	if err != nil {
+		return err
+	}
+
+	err = c.handleSaveConfig(s, m)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package commands
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/foxtrot/scuzzy/discord"
+)
+
+const (
+	// maxSuggestions is how many close matches are offered for an unknown command.
+	maxSuggestions = 3
+	// suggestionCooldown stops a user being corrected on every typo in casual chat.
+	suggestionCooldown = time.Minute
+)
+
+func minInt(v int, vs ...int) int {
+	for _, o := range vs {
+		if o < v {
+			v = o
+		}
+	}
+
+	return v
+}
+
+// editDistance is the optimal string alignment distance between a and b, so swapping two
+// adjacent letters ("hlep") counts as a single edit.
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			d[i][j] = minInt(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(ra)][len(rb)]
+}
+
+// maxSuggestionDistance scales the edits allowed with the length of what was typed, so short
+// words aren't matched against everything.
+func maxSuggestionDistance(name string) int {
+	dist := len([]rune(name)) / 3
+	if dist < 1 {
+		return 1
+	}
+
+	return minInt(dist, 2)
+}
+
+func isCommandName(name string) bool {
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+
+	return len(name) > 0
+}
+
+// SuggestCommands returns the commands and aliases closest to name that the user may run.
+func (c *Commands) SuggestCommands(s discord.Session, m *ScuzzyContext, name string) []string {
+	name = strings.ToLower(name)
+	if !isCommandName(name) {
+		return nil
+	}
+	maxDist := maxSuggestionDistance(name)
+
+	best := make(map[string]int)
+	consider := func(candidate string, cmd ScuzzyCommand) {
+		dist := editDistance(name, candidate)
+		if dist > maxDist {
+			return
+		}
+
+		// Only offer commands the user could actually run here
+		check := *m
+		check.Command = &cmd
+		if ok, _ := c.CanRunCommand(s, &check, &cmd); !ok || cmd.Hidden || !m.CommandEnabled(&cmd) || !c.checkCommandRestrictions(s, &check) {
+			return
+		}
+
+		if prev, ok := best[candidate]; !ok || dist < prev {
+			best[candidate] = dist
+		}
+	}
+
+	for cName, cmd := range c.ScuzzyCommands {
+		consider(cName, cmd)
+		for _, alias := range cmd.Aliases {
+			consider(alias, cmd)
+		}
+	}
+
+	suggestions := make([]string, 0, len(best))
+	for candidate := range best {
+		suggestions = append(suggestions, candidate)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if best[suggestions[i]] != best[suggestions[j]] {
+			return best[suggestions[i]] < best[suggestions[j]]
+		}
+		return suggestions[i] < suggestions[j]
+	})
+
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+
+	return suggestions
+}
+
+// handleUnknownCommand suggests close matches for a command that doesn't exist.
+func (c *Commands) handleUnknownCommand(s discord.Session, m *ScuzzyContext, name string) error {
+	if m.Permissions.CheckIgnoredUser(m.Author) {
+		return nil
+	}
+
+	suggestions := c.SuggestCommands(s, m, name)
+	if len(suggestions) == 0 {
+		return nil
+	}
+
+	buckets := []cooldownBucket{{Key: "suggest:" + m.GuildID + ":" + m.Author.ID, Duration: suggestionCooldown}}
+	if c.cooldowns.Take(buckets) > 0 {
+		return nil
+	}
+
+	log.Printf("[*] User %s tried unknown command %s, suggesting %s\n", m.Author.Username, name, strings.Join(suggestions, ", "))
+
+	key := commandKey(m.Config)
+	msg := "`" + name + "` isn't a command. Did you mean `" + key + strings.Join(suggestions, "`, `"+key) + "`?"
+	eMsg := c.CreateDefinedEmbed("Unknown Command", msg, "error", m.Author)
+	_, err := c.SendCannedEmbed(s, m, "unknown_command", map[string]string{"command": name, "suggestions": strings.Join(suggestions, ", ")}, eMsg)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,315 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/discord"
+	"github.com/foxtrot/scuzzy/models"
+)
+
+// tagsFile is the storage name a guild's tags are kept under.
+const tagsFile = "tags"
+
+var tagNameRegex = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// guildTags returns a guild's tags, loading them from storage the first time. The caller holds tagsMu.
+func (c *Commands) guildTags(guildID string) (map[string]models.Tag, error) {
+	if tags, ok := c.tags[guildID]; ok {
+		return tags, nil
+	}
+
+	var stored []models.Tag
+	err := c.Data.Load(guildID, tagsFile, &stored)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]models.Tag)
+	for _, tag := range stored {
+		tags[tag.Name] = tag
+	}
+	c.tags[guildID] = tags
+
+	return tags, nil
+}
+
+// saveTags writes a guild's tags to storage. The caller holds tagsMu.
+func (c *Commands) saveTags(guildID string) error {
+	var stored []models.Tag
+	for _, tag := range c.tags[guildID] {
+		stored = append(stored, tag)
+	}
+	sort.Slice(stored, func(i, j int) bool {
+		return stored[i].Name < stored[j].Name
+	})
+
+	return c.Data.Save(guildID, tagsFile, stored)
+}
+
+// FindTag looks up one of a guild's tags by name.
+func (c *Commands) FindTag(guildID string, name string) (models.Tag, bool) {
+	c.tagsMu.Lock()
+	defer c.tagsMu.Unlock()
+
+	tags, err := c.guildTags(guildID)
+	if err != nil {
+		return models.Tag{}, false
+	}
+
+	tag, ok := tags[strings.ToLower(name)]
+	return tag, ok
+}
+
+// tagCommand wraps a tag in a command so it runs through the same middleware as any other.
+// Restrictions and cooldowns are configured against the tag's name.
+func (c *Commands) tagCommand(tag models.Tag) *ScuzzyCommand {
+	return &ScuzzyCommand{
+		Name:        tag.Name,
+		Path:        tag.Name,
+		Category:    "Tags",
+		Description: "Custom command",
+		Cooldown:    ScuzzyCooldown{Channel: 15 * time.Second},
+		Arguments:   []ScuzzyArgument{{Name: "args", Description: "Text for the {args} placeholder", Type: ArgRest}},
+		Handler:     c.handleTag,
+	}
+}
+
+// tagReplacer fills in the placeholders tags may use.
+func tagReplacer(m *ScuzzyContext) *strings.Replacer {
+	return strings.NewReplacer(
+		"{user}", "<@"+m.Author.ID+">",
+		"{username}", m.Author.Username,
+		"{channel}", "<#"+m.ChannelID+">",
+		"{server}", m.Config.GuildName,
+		"{prefix}", commandKey(m.Config),
+		"{args}", m.Args.String("args"),
+	)
+}
+
+func replaceEmbed(embed *discordgo.MessageEmbed, r *strings.Replacer) *discordgo.MessageEmbed {
+	e := *embed
+	e.Title = r.Replace(e.Title)
+	e.Description = r.Replace(e.Description)
+
+	if e.Footer != nil {
+		ftr := *e.Footer
+		ftr.Text = r.Replace(ftr.Text)
+		e.Footer = &ftr
+	}
+	if e.Author != nil {
+		atr := *e.Author
+		atr.Name = r.Replace(atr.Name)
+		e.Author = &atr
+	}
+
+	e.Fields = nil
+	for _, f := range embed.Fields {
+		field := *f
+		field.Name = r.Replace(field.Name)
+		field.Value = r.Replace(field.Value)
+		e.Fields = append(e.Fields, &field)
+	}
+
+	return &e
+}
+
+func (c *Commands) handleTag(s discord.Session, m *ScuzzyContext) error {
+	tag, ok := c.FindTag(m.GuildID, m.Command.Name)
+	if !ok {
+		return errors.New("That tag no longer exists.")
+	}
+
+	r := tagReplacer(m)
+	data := &discordgo.MessageSend{
+		Content: r.Replace(tag.Content),
+		// Placeholders such as {args} carry the invoker's text, which mustn't be able to ping roles or everyone
+		AllowedMentions: &discordgo.MessageAllowedMentions{
+			Parse: []discordgo.AllowedMentionType{discordgo.AllowedMentionTypeUsers},
+		},
+	}
+	if tag.Embed != nil {
+		data.Embeds = []*discordgo.MessageEmbed{replaceEmbed(tag.Embed, r)}
+	}
+
+	_, err := c.SendComplex(s, m, data)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// parseTagContent reads a tag's body, either plain text or a JSON embed object.
+func parseTagContent(content string) (string, *discordgo.MessageEmbed, error) {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "{") || !strings.HasSuffix(content, "}") {
+		return content, nil, nil
+	}
+
+	embed := &discordgo.MessageEmbed{}
+	err := json.Unmarshal([]byte(content), embed)
+	if err != nil {
+		return "", nil, errors.New("Invalid embed JSON: " + err.Error())
+	}
+	if len(embed.Title) == 0 && len(embed.Description) == 0 && len(embed.Fields) == 0 && embed.Image == nil {
+		return "", nil, errors.New("The embed needs a title, description, fields or an image.")
+	}
+
+	return "", embed, nil
+}
+
+func (c *Commands) tagName(m *ScuzzyContext) (string, error) {
+	name := strings.ToLower(m.Args.String("name"))
+	if !tagNameRegex.MatchString(name) {
+		return "", errors.New("Tag names may only use letters, numbers, `-` and `_`, up to 32 characters.")
+	}
+
+	return name, nil
+}
+
+func (c *Commands) handleAddTag(s discord.Session, m *ScuzzyContext) error {
+	name, err := c.tagName(m)
+	if err != nil {
+		return err
+	}
+	if _, ok := c.FindCommand(name); ok {
+		return errors.New("`" + name + "` is already a command.")
+	}
+
+	content, embed, err := parseTagContent(m.Args.String("content"))
+	if err != nil {
+		return err
+	}
+
+	c.tagsMu.Lock()
+	tags, err := c.guildTags(m.GuildID)
+	if err == nil {
+		if _, ok := tags[name]; ok {
+			err = errors.New("Tag `" + name + "` already exists, use `" + commandKey(m.Config) + "tag edit` to change it.")
+		} else {
+			tags[name] = models.Tag{
+				Name:      name,
+				Content:   content,
+				Embed:     embed,
+				CreatedBy: m.Author.ID,
+				CreatedAt: time.Now(),
+			}
+			err = c.saveTags(m.GuildID)
+		}
+	}
+	c.tagsMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	eMsg := c.CreateDefinedEmbed("Add Tag", "Created `"+commandKey(m.Config)+name+"`.", "success", m.Author)
+	_, err = c.SendEmbed(s, m, eMsg)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Commands) handleEditTag(s discord.Session, m *ScuzzyContext) error {
+	name, err := c.tagName(m)
+	if err != nil {
+		return err
+	}
+
+	content, embed, err := parseTagContent(m.Args.String("content"))
+	if err != nil {
+		return err
+	}
+
+	c.tagsMu.Lock()
+	tags, err := c.guildTags(m.GuildID)
+	if err == nil {
+		tag, ok := tags[name]
+		if !ok {
+			err = errors.New("Unknown tag `" + name + "`")
+		} else {
+			tag.Content = content
+			tag.Embed = embed
+			tag.UpdatedBy = m.Author.ID
+			tag.UpdatedAt = time.Now()
+			tags[name] = tag
+			err = c.saveTags(m.GuildID)
+		}
+	}
+	c.tagsMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	eMsg := c.CreateDefinedEmbed("Edit Tag", "Updated `"+commandKey(m.Config)+name+"`.", "success", m.Author)
+	_, err = c.SendEmbed(s, m, eMsg)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Commands) handleDeleteTag(s discord.Session, m *ScuzzyContext) error {
+	name, err := c.tagName(m)
+	if err != nil {
+		return err
+	}
+
+	c.tagsMu.Lock()
+	tags, err := c.guildTags(m.GuildID)
+	if err == nil {
+		if _, ok := tags[name]; !ok {
+			err = errors.New("Unknown tag `" + name + "`")
+		} else {
+			delete(tags, name)
+			err = c.saveTags(m.GuildID)
+		}
+	}
+	c.tagsMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	eMsg := c.CreateDefinedEmbed("Delete Tag", "Deleted `"+commandKey(m.Config)+name+"`.", "success", m.Author)
+	_, err = c.SendEmbed(s, m, eMsg)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Commands) handleListTags(s discord.Session, m *ScuzzyContext) error {
+	c.tagsMu.Lock()
+	tags, err := c.guildTags(m.GuildID)
+	var names []string
+	for name := range tags {
+		names = append(names, name)
+	}
+	c.tagsMu.Unlock()
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	msg := "No tags have been created yet."
+	if len(names) > 0 {
+		msg = "`" + commandKey(m.Config) + strings.Join(names, "`, `"+commandKey(m.Config)) + "`"
+	}
+
+	eMsg := c.CreateDefinedEmbed("Tags", msg, "", m.Author)
+	_, err = c.SendEmbed(s, m, eMsg)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
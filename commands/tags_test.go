@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// lastSent returns the last message sent to the test channel.
+func (b *testBot) lastSent() *discordgo.Message {
+	sent := b.s.Sent[testChannelID]
+	if len(sent) == 0 {
+		b.t.Fatal("expected a message to be sent")
+	}
+
+	return sent[len(sent)-1]
+}
+
+func TestParseTagContent(t *testing.T) {
+	content, embed, err := parseTagContent("  Read the rules  ")
+	if err != nil || content != "Read the rules" || embed != nil {
+		t.Errorf("got %q, %v, %v", content, embed, err)
+	}
+
+	content, embed, err = parseTagContent(`{"title": "Rules", "description": "Be nice"}`)
+	if err != nil || len(content) != 0 || embed == nil || embed.Title != "Rules" {
+		t.Errorf("got %q, %v, %v", content, embed, err)
+	}
+
+	if _, _, err := parseTagContent(`{"title": }`); err == nil {
+		t.Error("invalid JSON should be rejected")
+	}
+	if _, _, err := parseTagContent(`{"color": 1}`); err == nil {
+		t.Error("empty embeds should be rejected")
+	}
+}
+
+func TestTags(t *testing.T) {
+	b := newTestBot(t, nil)
+	bob := b.member(testUserID)
+
+	b.run(".tag add vpn Hi {user}, see {prefix}help about {args}")
+	b.runAs(bob, ".vpn @everyone")
+
+	msg := b.lastSent()
+	if msg.Content != "Hi <@"+testUserID+">, see .help about @everyone" {
+		t.Errorf("placeholders not filled in: %q", msg.Content)
+	}
+	calls := b.s.CallsTo("ChannelMessageSendComplex")
+	data := calls[len(calls)-1].Args[1].(*discordgo.MessageSend)
+	if data.AllowedMentions == nil || len(data.AllowedMentions.Parse) != 1 || data.AllowedMentions.Parse[0] != discordgo.AllowedMentionTypeUsers {
+		t.Errorf("tags should only be able to ping users, got %+v", data.AllowedMentions)
+	}
+
+	// Tags are kept across restarts
+	b.Commands = b.start()
+	if tag, ok := b.FindTag(testGuildID, "VPN"); !ok || tag.CreatedBy != testAdminID {
+		t.Fatalf("tag not reloaded: %+v", tag)
+	}
+
+	b.run(`.tag edit vpn {"title": "VPN", "description": "See the guide, {username}"}`)
+	b.runAs(bob, ".vpn")
+	if msg := b.lastSent(); len(msg.Embeds) != 1 || msg.Embeds[0].Description != "See the guide, bob" {
+		t.Errorf("expected the edited embed, got %+v", msg)
+	}
+
+	b.run(".tag delete vpn")
+	if _, ok := b.FindTag(testGuildID, "vpn"); ok {
+		t.Error("tag not deleted")
+	}
+}
+
+func TestTagNames(t *testing.T) {
+	b := newTestBot(t, nil)
+
+	for _, name := range []string{"ping", "colors", "no.dots"} {
+		b.run(".tag add " + name + " text")
+		if _, ok := b.FindTag(testGuildID, name); ok {
+			t.Errorf("tag %q shouldn't have been created", name)
+		}
+	}
+
+	b.run(".tag add faq one")
+	b.run(".tag add faq two")
+	if tag, _ := b.FindTag(testGuildID, "faq"); tag.Content != "one" {
+		t.Errorf("existing tags shouldn't be replaced by add, got %q", tag.Content)
+	}
+
+	// Only admins manage tags
+	b.runAs(b.member(testUserID), ".tag delete faq")
+	if _, ok := b.FindTag(testGuildID, "faq"); !ok {
+		t.Error("regular members shouldn't be able to delete tags")
+	}
+}
@@ -0,0 +1,306 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/discord"
+)
+
+// tasksFile is the storage name a guild's scheduled tasks are kept under.
+const tasksFile = "tasks"
+
+const (
+	// taskRetry is how long a failed task waits before running again, doubling after each
+	// failure up to taskMaxRetry.
+	taskRetry    = time.Minute
+	taskMaxRetry = time.Hour
+)
+
+// ScheduledTask is a job that has to run at a set time, even if Scuzzy restarts in between.
+type ScheduledTask struct {
+	ID   string          `json:"id"`
+	Kind string          `json:"kind"`
+	At   time.Time       `json:"at"`
+	Data json.RawMessage `json:"data"`
+	// Attempts counts the times the task has failed so far.
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// TaskHandler runs a scheduled task of the kind it was registered for.
+type TaskHandler func(s discord.Session, guildID string, task ScheduledTask) error
+
+var taskCounter uint64
+
+func newTaskID() string {
+	n := atomic.AddUint64(&taskCounter, 1)
+	return strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.FormatUint(n, 36)
+}
+
+func (c *Commands) RegisterTask(kind string, handler TaskHandler) {
+	c.taskHandlers[kind] = handler
+}
+
+// saveTasks writes a guild's pending tasks to storage. The caller holds tasksMu.
+func (c *Commands) saveTasks(guildID string) error {
+	var tasks []ScheduledTask
+	for _, task := range c.tasks[guildID] {
+		tasks = append(tasks, task)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].At.Before(tasks[j].At)
+	})
+
+	return c.Data.Save(guildID, tasksFile, tasks)
+}
+
+func (c *Commands) runTask(s discord.Session, guildID string, taskID string) {
+	c.tasksMu.Lock()
+	task, ok := c.tasks[guildID][taskID]
+	c.tasksMu.Unlock()
+
+	// Cancelled since it was scheduled
+	if !ok {
+		return
+	}
+	// Put back since it was scheduled
+	if time.Until(task.At) > 0 {
+		c.queueTask(s, guildID, task)
+		return
+	}
+
+	handler, ok := c.taskHandlers[task.Kind]
+	if !ok {
+		log.Printf("[!] No handler for scheduled task kind '%s'\n", task.Kind)
+	} else if err := handler(s, guildID, task); err != nil {
+		c.logGuildError(s, guildID, "Error (Scheduled "+task.Kind+")", err)
+
+		// Tasks stand for things that have to happen, e.g. an unban, so only give up on
+		// those that can never succeed
+		if !isPermanentTaskError(err) {
+			c.retryTask(s, guildID, task)
+			return
+		}
+	}
+
+	c.tasksMu.Lock()
+	delete(c.tasks[guildID], taskID)
+	err := c.saveTasks(guildID)
+	c.tasksMu.Unlock()
+	if err != nil {
+		log.Println("[!] Error (Scheduled Tasks): " + err.Error())
+	}
+}
+
+// isPermanentTaskError reports whether a task failed because what it acts on is gone, such as
+// the member or ban it was for.
+func isPermanentTaskError(err error) bool {
+	return isUnknownMember(err) || isUnknownBan(err) || isUnknownMessage(err)
+}
+
+// retryTask runs a failed task again later, waiting longer after each failure.
+func (c *Commands) retryTask(s discord.Session, guildID string, task ScheduledTask) {
+	backoff := taskMaxRetry
+	if task.Attempts < 10 {
+		backoff = taskRetry << uint(task.Attempts)
+		if backoff > taskMaxRetry {
+			backoff = taskMaxRetry
+		}
+	}
+
+	c.tasksMu.Lock()
+	// Cancelled while it ran
+	pending, ok := c.tasks[guildID][task.ID]
+	if !ok {
+		c.tasksMu.Unlock()
+		return
+	}
+	pending.Attempts = task.Attempts + 1
+	if retryAt := time.Now().Add(backoff); pending.At.Before(retryAt) {
+		pending.At = retryAt
+	}
+	c.tasks[guildID][task.ID] = pending
+	err := c.saveTasks(guildID)
+	c.tasksMu.Unlock()
+	if err != nil {
+		log.Println("[!] Error (Scheduled Tasks): " + err.Error())
+	}
+
+	c.queueTask(s, guildID, pending)
+}
+
+func (c *Commands) queueTask(s discord.Session, guildID string, task ScheduledTask) {
+	c.scheduler.After(time.Until(task.At), func() {
+		c.runTask(s, guildID, task.ID)
+	})
+}
+
+// ScheduleTask stores a task and runs it at the given time. data is passed to the task's
+// handler as JSON.
+func (c *Commands) ScheduleTask(s discord.Session, guildID string, kind string, at time.Time, data interface{}) (ScheduledTask, error) {
+	if _, ok := c.taskHandlers[kind]; !ok {
+		return ScheduledTask{}, errors.New("Unknown task kind '" + kind + "'")
+	}
+
+	j, err := json.Marshal(data)
+	if err != nil {
+		return ScheduledTask{}, err
+	}
+
+	task := ScheduledTask{
+		ID:   newTaskID(),
+		Kind: kind,
+		At:   at,
+		Data: j,
+	}
+
+	c.tasksMu.Lock()
+	if c.tasks[guildID] == nil {
+		c.tasks[guildID] = make(map[string]ScheduledTask)
+	}
+	c.tasks[guildID][task.ID] = task
+	err = c.saveTasks(guildID)
+	c.tasksMu.Unlock()
+	if err != nil {
+		return task, err
+	}
+
+	c.queueTask(s, guildID, task)
+
+	return task, nil
+}
+
+// Tasks lists a guild's pending tasks of one kind.
+func (c *Commands) Tasks(guildID string, kind string) []ScheduledTask {
+	c.tasksMu.Lock()
+	defer c.tasksMu.Unlock()
+
+	var tasks []ScheduledTask
+	for _, task := range c.tasks[guildID] {
+		if task.Kind == kind {
+			tasks = append(tasks, task)
+		}
+	}
+
+	return tasks
+}
+
+// PostponeTask moves a pending task to a later time and replaces its data. The change is only
+// written to storage when save is set, so frequent updates can be saved now and then.
+func (c *Commands) PostponeTask(guildID string, task ScheduledTask, save bool) error {
+	c.tasksMu.Lock()
+	defer c.tasksMu.Unlock()
+
+	pending, ok := c.tasks[guildID][task.ID]
+	if !ok {
+		return errors.New("Scheduled task '" + task.ID + "' has already run or been cancelled.")
+	}
+	if task.At.Before(pending.At) {
+		task.At = pending.At
+	}
+	c.tasks[guildID][task.ID] = task
+
+	if !save {
+		return nil
+	}
+
+	return c.saveTasks(guildID)
+}
+
+// CancelTasks drops the pending tasks of one kind that match, returning how many were dropped.
+func (c *Commands) CancelTasks(guildID string, kind string, match func(task ScheduledTask) bool) (int, error) {
+	c.tasksMu.Lock()
+	defer c.tasksMu.Unlock()
+
+	n := 0
+	for id, task := range c.tasks[guildID] {
+		if task.Kind == kind && match(task) {
+			delete(c.tasks[guildID], id)
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, nil
+	}
+
+	return n, c.saveTasks(guildID)
+}
+
+// loadTasks picks up a guild's tasks from before a restart. Overdue tasks run straight away.
+func (c *Commands) loadTasks(s discord.Session, guildID string) error {
+	c.tasksMu.Lock()
+	if _, ok := c.tasks[guildID]; ok {
+		// Already running, the guild has just become available again
+		c.tasksMu.Unlock()
+		return nil
+	}
+
+	var tasks []ScheduledTask
+	err := c.Data.Load(guildID, tasksFile, &tasks)
+	if err != nil {
+		c.tasksMu.Unlock()
+		return err
+	}
+
+	c.tasks[guildID] = make(map[string]ScheduledTask)
+	for _, task := range tasks {
+		c.tasks[guildID][task.ID] = task
+	}
+	c.tasksMu.Unlock()
+
+	for _, task := range tasks {
+		c.queueTask(s, guildID, task)
+	}
+	if len(tasks) > 0 {
+		log.Printf("[*] Resumed %d scheduled tasks for guild %s\n", len(tasks), guildID)
+	}
+
+	return nil
+}
+
+// deleteTask is the data of a scheduled message deletion. Interaction replies keep the
+// interaction's token, they can only be deleted through it.
+type deleteTask struct {
+	ChannelID  string   `json:"channel_id"`
+	MessageIDs []string `json:"message_ids"`
+	AppID      string   `json:"app_id,omitempty"`
+	Token      string   `json:"token,omitempty"`
+}
+
+func isUnknownMessage(err error) bool {
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Message != nil {
+		return restErr.Message.Code == discordgo.ErrCodeUnknownMessage
+	}
+
+	return false
+}
+
+func (c *Commands) runDeleteTask(s discord.Session, guildID string, task ScheduledTask) error {
+	var dt deleteTask
+	err := json.Unmarshal(task.Data, &dt)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range dt.MessageIDs {
+		if len(dt.Token) > 0 {
+			err = s.FollowupMessageDelete(&discordgo.Interaction{AppID: dt.AppID, Token: dt.Token}, id)
+		} else {
+			err = s.ChannelMessageDelete(dt.ChannelID, id)
+		}
+
+		// Someone got there first
+		if err != nil && !isUnknownMessage(err) {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package commands
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/discord"
+)
+
+// eventually waits up to a second for cond, for work handed to timers.
+func eventually(t *testing.T, cond func() bool) bool {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	return cond()
+}
+
+func TestScheduler(t *testing.T) {
+	wp := NewWorkerPool(1, 1)
+	sc := NewScheduler(wp)
+
+	ran := make(chan int, 3)
+	sc.After(10*time.Millisecond, func() { ran <- 1 })
+	sc.After(time.Hour, func() { ran <- 2 })
+	if n := sc.Pending(); n != 2 {
+		t.Errorf("expected 2 pending jobs, got %d", n)
+	}
+
+	select {
+	case n := <-ran:
+		if n != 1 {
+			t.Errorf("job %d ran first", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the due job never ran")
+	}
+
+	// Stopping drops what hasn't run, and nothing new is taken
+	sc.Stop()
+	sc.After(0, func() { ran <- 3 })
+	wp.Stop()
+	if n := sc.Pending(); n != 0 || len(ran) != 0 {
+		t.Errorf("expected nothing left after stopping, got %d pending and %d run", n, len(ran))
+	}
+}
+
+func TestTaskPersistence(t *testing.T) {
+	b := newTestBot(t, nil)
+
+	b.run(".ban <@" + testUserID + "> 7d Spamming")
+	tasks := b.Tasks(testGuildID, "unban")
+	if len(tasks) != 1 {
+		t.Fatalf("expected an unban task, got %+v", tasks)
+	}
+
+	// Pending tasks come back after a restart
+	b.Shutdown()
+	b.Commands = b.start()
+	resumed := b.Tasks(testGuildID, "unban")
+	if len(resumed) != 1 || resumed[0].ID != tasks[0].ID || !resumed[0].At.Equal(tasks[0].At) {
+		t.Fatalf("expected the task to be resumed, got %+v", resumed)
+	}
+
+	// Tasks that fell due while Scuzzy was down run as soon as it is back
+	resumed[0].At = time.Now().Add(-time.Minute)
+	err := b.Data.Save(testGuildID, tasksFile, resumed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Shutdown()
+	b.Commands = b.start()
+	if !eventually(t, func() bool { return len(b.Tasks(testGuildID, "unban")) == 0 }) {
+		t.Error("the overdue unban never ran")
+	}
+	if _, ok := b.s.Bans[testGuildID][testUserID]; ok {
+		t.Error("the ban should have been lifted")
+	}
+}
+
+func TestTaskRetry(t *testing.T) {
+	b := newTestBot(t, nil)
+
+	var fail error
+	runs := 0
+	b.RegisterTask("test", func(s discord.Session, guildID string, task ScheduledTask) error {
+		runs++
+		return fail
+	})
+
+	// runNow runs a pending task as if it had fallen due
+	runNow := func(id string) {
+		b.tasksMu.Lock()
+		task := b.tasks[testGuildID][id]
+		task.At = time.Now()
+		b.tasks[testGuildID][id] = task
+		b.tasksMu.Unlock()
+
+		b.runTask(b.s, testGuildID, id)
+	}
+	schedule := func() ScheduledTask {
+		task, err := b.ScheduleTask(b.s, testGuildID, "test", time.Now().Add(time.Hour), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return task
+	}
+
+	fail = errors.New("500 Internal Server Error")
+	task := schedule()
+	runNow(task.ID)
+	tasks := b.Tasks(testGuildID, "test")
+	if len(tasks) != 1 || tasks[0].Attempts != 1 {
+		t.Fatalf("a failed task should be kept, got %+v", tasks)
+	}
+	if d := time.Until(tasks[0].At); d < taskRetry-time.Second || d > taskRetry {
+		t.Errorf("retry in %v, want %v", d, taskRetry)
+	}
+
+	// Each failure waits longer
+	runNow(task.ID)
+	if tasks := b.Tasks(testGuildID, "test"); len(tasks) != 1 || time.Until(tasks[0].At) < 2*taskRetry-time.Second {
+		t.Errorf("expected the second retry to wait longer, got %+v", tasks)
+	}
+
+	fail = nil
+	runNow(task.ID)
+	if runs != 3 || len(b.Tasks(testGuildID, "test")) != 0 {
+		t.Errorf("a task should be dropped once it succeeds, got %d runs and %+v", runs, b.Tasks(testGuildID, "test"))
+	}
+
+	// Tasks for something that is gone can never succeed
+	fail = &discordgo.RESTError{
+		Response: &http.Response{Status: "404 Not Found"},
+		Message:  &discordgo.APIErrorMessage{Code: discordgo.ErrCodeUnknownMember, Message: "Unknown Member"},
+	}
+	runNow(schedule().ID)
+	if tasks := b.Tasks(testGuildID, "test"); len(tasks) != 0 {
+		t.Errorf("a task failing for good should be dropped, got %+v", tasks)
+	}
+}
@@ -1,26 +1,22 @@
 package commands
 
 import (
-	"errors"
-	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/discord"
+	"github.com/foxtrot/scuzzy/models"
 	"strings"
 )
 
-func (c *Commands) handleUserColors(s *discordgo.Session, m *discordgo.MessageCreate) error {
-	if !c.Permissions.CheckCommandRestrictions(m) {
-		return errors.New("This command is not allowed in this channel.")
-	}
-
+func (c *Commands) handleUserColors(s discord.Session, m *ScuzzyContext) error {
 	msgC := "You can choose from the following colors:\n\n"
-	for _, v := range c.Config.ColorRoles {
+	for _, v := range m.Config.ColorRoles {
 		msgC += "<@&" + v.ID + ">\n"
 	}
-	msgC += "\n\nUse `" + c.Config.CommandKey + "color <color>` to set.\n"
-	msgC += "Example: `" + c.Config.CommandKey + "color red`.\n"
+	msgC += "\n\nUse `" + commandKey(m.Config) + "colour <color>` to set.\n"
+	msgC += "Example: `" + commandKey(m.Config) + "colour red`.\n"
 
 	msg := c.CreateDefinedEmbed("User Colors", msgC, "", m.Author)
 
-	_, err := s.ChannelMessageSendEmbed(m.ChannelID, msg)
+	_, err := c.SendEmbed(s, m, msg)
 	if err != nil {
 		return err
 	}
@@ -28,25 +24,19 @@ func (c *Commands) handleUserColors(s *discordgo.Session, m *discordgo.MessageCr
 	return nil
 }
 
-func (c *Commands) handleUserColor(s *discordgo.Session, m *discordgo.MessageCreate) error {
+func (c *Commands) handleUserColor(s discord.Session, m *ScuzzyContext) error {
 	var err error
 
-	if !c.Permissions.CheckCommandRestrictions(m) {
-		return errors.New("This command is not allowed in this channel.")
-	}
-
 	rUserID := m.Author.ID
 
-	userInput := strings.Split(m.Content, " ")
-	if len(userInput) < 2 {
+	if !m.Args.Has("color") {
 		err = c.handleUserColors(s, m)
 		return err
 	}
-	roleColorName := userInput[1]
-	roleColorName = strings.ToLower(roleColorName)
+	roleColorName := strings.ToLower(m.Args.String("color"))
 
 	roleColorID := ""
-	for _, role := range c.Config.ColorRoles {
+	for _, role := range m.Config.ColorRoles {
 		if role.Name == roleColorName {
 			roleColorID = role.ID
 			break
@@ -57,7 +47,7 @@ func (c *Commands) handleUserColor(s *discordgo.Session, m *discordgo.MessageCre
 		return err
 	}
 
-	for _, role := range c.Config.ColorRoles {
+	for _, role := range m.Config.ColorRoles {
 		// Attempt to remove all color roles regardless of if they have them or not.
 		// Slow because of the REST requests...
 		_ = s.GuildMemberRoleRemove(m.GuildID, rUserID, role.ID)
@@ -68,16 +58,20 @@ func (c *Commands) handleUserColor(s *discordgo.Session, m *discordgo.MessageCre
 		return err
 	} else {
 		msg := c.CreateDefinedEmbed("User Color", "<@"+m.Author.ID+">: Your color has been set to <@&"+roleColorID+">!", "success", m.Author)
-		_, err = s.ChannelMessageSendEmbed(m.ChannelID, msg)
+		_, err = c.SendCannedEmbed(s, m, "colour_set", map[string]string{"role": "<@&" + roleColorID + ">", "colour": roleColorName}, msg)
 		if err != nil {
 			return err
 		}
 	}
 
-	err = s.ChannelMessageDelete(m.ChannelID, m.ID)
-	if err != nil {
-		return err
+	return nil
+}
+
+func colorRoleNames(conf *models.Configuration) []string {
+	var names []string
+	for _, role := range conf.ColorRoles {
+		names = append(names, role.Name)
 	}
 
-	return nil
+	return names
 }
@@ -0,0 +1,240 @@
+package commands
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/foxtrot/scuzzy/actions"
+	"github.com/foxtrot/scuzzy/discord"
+	"github.com/foxtrot/scuzzy/models"
+)
+
+// warningsFile is the storage name a guild's warnings are kept under.
+const warningsFile = "warnings"
+
+type guildWarnings struct {
+	NextID   int              `json:"next_id"`
+	Warnings []models.Warning `json:"warnings"`
+}
+
+// loadWarnings reads a guild's warnings. The caller holds modMu.
+func (c *Commands) loadWarnings(guildID string) (*guildWarnings, error) {
+	gw := &guildWarnings{NextID: 1}
+	err := c.Data.Load(guildID, warningsFile, gw)
+	if err != nil {
+		return nil, err
+	}
+
+	return gw, nil
+}
+
+// UserWarnings returns a user's warnings that haven't expired, oldest first.
+func (c *Commands) UserWarnings(guildID string, userID string) ([]models.Warning, error) {
+	c.modMu.Lock()
+	defer c.modMu.Unlock()
+
+	gw, err := c.loadWarnings(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []models.Warning
+	now := time.Now()
+	for _, w := range gw.Warnings {
+		if w.UserID == userID && w.Active(now) {
+			warnings = append(warnings, w)
+		}
+	}
+
+	return warnings, nil
+}
+
+// AddWarning numbers and stores a warning.
+func (c *Commands) AddWarning(guildID string, w models.Warning) (models.Warning, error) {
+	c.modMu.Lock()
+	defer c.modMu.Unlock()
+
+	gw, err := c.loadWarnings(guildID)
+	if err != nil {
+		return w, err
+	}
+
+	w.ID = gw.NextID
+	gw.NextID++
+	gw.Warnings = append(gw.Warnings, w)
+
+	return w, c.Data.Save(guildID, warningsFile, gw)
+}
+
+// removeWarnings drops the warnings that match, returning how many went.
+func (c *Commands) removeWarnings(guildID string, match func(w models.Warning) bool) (int, error) {
+	c.modMu.Lock()
+	defer c.modMu.Unlock()
+
+	gw, err := c.loadWarnings(guildID)
+	if err != nil {
+		return 0, err
+	}
+
+	var kept []models.Warning
+	for _, w := range gw.Warnings {
+		if !match(w) {
+			kept = append(kept, w)
+		}
+	}
+	n := len(gw.Warnings) - len(kept)
+	if n == 0 {
+		return 0, nil
+	}
+	gw.Warnings = kept
+
+	return n, c.Data.Save(guildID, warningsFile, gw)
+}
+
+func formatWarning(w models.Warning) string {
+	msg := "`#" + strconv.Itoa(w.ID) + "` <t:" + strconv.FormatInt(w.CreatedAt.Unix(), 10) + ":d> by <@" + w.ModeratorID + ">"
+	if w.ExpiresAt != nil {
+		msg += ", expires <t:" + strconv.FormatInt(w.ExpiresAt.Unix(), 10) + ":R>"
+	}
+	if len(w.Reason) > 0 {
+		msg += "\n" + w.Reason
+	}
+
+	return msg
+}
+
+// splitWarningExpiry takes the expiry off the front of a warning's reason, e.g. "30d Spamming".
+// A duration on its own is left as the reason.
+func splitWarningExpiry(reason string) (time.Duration, string) {
+	fields := strings.Fields(reason)
+	if len(fields) < 2 {
+		return 0, reason
+	}
+
+	d, err := parseDuration(fields[0])
+	if err != nil {
+		return 0, reason
+	}
+
+	return d, strings.Join(fields[1:], " ")
+}
+
+func (c *Commands) handleWarnUser(s discord.Session, m *ScuzzyContext) error {
+	target := m.Args.User("user")
+	if target.ID == m.Author.ID {
+		return errors.New("You can't warn yourself.")
+	}
+
+	expiry, reason := splitWarningExpiry(moderationReason(m))
+	w := models.Warning{
+		UserID:      target.ID,
+		ModeratorID: m.Author.ID,
+		Reason:      reason,
+		CreatedAt:   time.Now(),
+	}
+	if expiry > 0 {
+		expires := w.CreatedAt.Add(expiry)
+		w.ExpiresAt = &expires
+	}
+
+	w, err := c.AddWarning(m.GuildID, w)
+	if err != nil {
+		return err
+	}
+
+	warnings, err := c.UserWarnings(m.GuildID, target.ID)
+	if err != nil {
+		return err
+	}
+	count := strconv.Itoa(len(warnings))
+
+	vars := map[string]string{"reason": w.Reason, "count": count, "server": m.Config.GuildName}
+	dm := c.CreateDefinedEmbed("Warning", "You have been warned in **"+m.Config.GuildName+"**.\nReason: "+w.Reason, "error", nil)
+	dmErr := c.NotifyUser(s, m.GuildID, target, "warn_dm", vars, dm)
+
+	msg := "<@" + target.ID + "> has been warned (`#" + strconv.Itoa(w.ID) + "`), they now have `" + count + "` active warnings.\n"
+	msg += "Reason: " + w.Reason + "\n"
+	if dmErr != nil {
+		msg += "I couldn't DM them about it.\n"
+	}
+
+	eMsg := c.CreateDefinedEmbed("Warn User", msg, "success", m.Author)
+	_, err = c.SendCannedEmbed(s, m, "warn", map[string]string{"target": "<@" + target.ID + ">", "reason": w.Reason, "count": count}, eMsg)
+	if err != nil {
+		return err
+	}
+
+	c.recordAction(s, actions.Action{Type: actions.ActionWarn, GuildID: m.GuildID, UserID: target.ID, ModeratorID: m.Author.ID, Reason: w.Reason})
+
+	return nil
+}
+
+func (c *Commands) handleListWarnings(s discord.Session, m *ScuzzyContext) error {
+	target := m.Args.User("user")
+
+	warnings, err := c.UserWarnings(m.GuildID, target.ID)
+	if err != nil {
+		return err
+	}
+
+	msg := ""
+	for _, w := range warnings {
+		msg += formatWarning(w) + "\n\n"
+	}
+	if len(msg) == 0 {
+		msg = "<@" + target.ID + "> has no active warnings."
+	}
+
+	eMsg := c.CreateDefinedEmbed("Warnings ("+target.Username+")", msg, "", m.Author)
+	_, err = c.SendEmbed(s, m, eMsg)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Commands) handleDeleteWarning(s discord.Session, m *ScuzzyContext) error {
+	id := m.Args.Int("id")
+
+	n, err := c.removeWarnings(m.GuildID, func(w models.Warning) bool {
+		return w.ID == id
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.New("There is no warning `#" + strconv.Itoa(id) + "`.")
+	}
+
+	eMsg := c.CreateDefinedEmbed("Delete Warning", "Deleted warning `#"+strconv.Itoa(id)+"`.", "success", m.Author)
+	_, err = c.SendCannedEmbed(s, m, "delwarn", map[string]string{"id": strconv.Itoa(id)}, eMsg)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Commands) handleClearWarnings(s discord.Session, m *ScuzzyContext) error {
+	target := m.Args.User("user")
+
+	n, err := c.removeWarnings(m.GuildID, func(w models.Warning) bool {
+		return w.UserID == target.ID
+	})
+	if err != nil {
+		return err
+	}
+
+	eMsg := c.CreateDefinedEmbed("Clear Warnings", "Cleared `"+strconv.Itoa(n)+"` warnings for <@"+target.ID+">.", "success", m.Author)
+	_, err = c.SendCannedEmbed(s, m, "clearwarns", map[string]string{"target": "<@" + target.ID + ">", "count": strconv.Itoa(n)}, eMsg)
+	if err != nil {
+		return err
+	}
+
+	c.logGuildEvent(s, m.GuildID, c.CreateDefinedEmbed("Warnings Cleared", "<@"+m.Author.ID+"> cleared `"+strconv.Itoa(n)+"` warnings for <@"+target.ID+">.", "", m.Author))
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/foxtrot/scuzzy/actions"
+	"github.com/foxtrot/scuzzy/models"
+)
+
+func TestSplitWarningExpiry(t *testing.T) {
+	tests := []struct {
+		in     string
+		expiry time.Duration
+		reason string
+	}{
+		{"30d Spamming links", 30 * 24 * time.Hour, "Spamming links"},
+		{"Spamming links", 0, "Spamming links"},
+		{"30d", 0, "30d"},
+		{"0s Spamming", 0, "0s Spamming"},
+		{"", 0, ""},
+	}
+	for _, tt := range tests {
+		expiry, reason := splitWarningExpiry(tt.in)
+		if expiry != tt.expiry || reason != tt.reason {
+			t.Errorf("splitWarningExpiry(%q) = %v, %q, want %v, %q", tt.in, expiry, reason, tt.expiry, tt.reason)
+		}
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	b := newTestBot(t, nil)
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	for _, w := range []models.Warning{
+		{UserID: testUserID, Reason: "one"},
+		{UserID: testUserID, Reason: "expired", ExpiresAt: &past},
+		{UserID: testUserID, Reason: "expiring", ExpiresAt: &future},
+		{UserID: testAdminID, Reason: "someone else"},
+	} {
+		if _, err := b.AddWarning(testGuildID, w); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	warnings, err := b.UserWarnings(testGuildID, testUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 2 || warnings[0].ID != 1 || warnings[1].ID != 3 {
+		t.Errorf("expected warnings 1 and 3 to be active, got %+v", warnings)
+	}
+
+	n, err := b.removeWarnings(testGuildID, func(w models.Warning) bool {
+		return w.UserID == testUserID
+	})
+	if err != nil || n != 3 {
+		t.Errorf("removed %d, %v, want 3", n, err)
+	}
+
+	// Numbers aren't reused once warnings are removed
+	w, err := b.AddWarning(testGuildID, models.Warning{UserID: testUserID})
+	if err != nil || w.ID != 5 {
+		t.Errorf("got warning #%d, %v, want #5", w.ID, err)
+	}
+}
+
+func TestWarnCommand(t *testing.T) {
+	b := newTestBot(t, nil)
+
+	b.run(".warn <@" + testUserID + "> 30d Spamming")
+
+	warnings, err := b.UserWarnings(testGuildID, testUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("expected a warning, got %+v", warnings)
+	}
+
+	w := warnings[0]
+	if w.Reason != "Spamming" || w.ModeratorID != testAdminID || w.ExpiresAt == nil {
+		t.Errorf("got %+v", w)
+	} else if d := w.ExpiresAt.Sub(w.CreatedAt); d != 30*24*time.Hour {
+		t.Errorf("expires after %v, want 30d", d)
+	}
+
+	cases, _ := b.UserCases(testGuildID, testUserID)
+	if len(cases) != 1 || cases[0].Action != actions.ActionWarn {
+		t.Errorf("expected a warn case, got %+v", cases)
+	}
+}
+
+func TestWarningAction(t *testing.T) {
+	b := newTestBot(t, map[string]interface{}{
+		"MaxUserWarnings":  2,
+		"warning_action":   "timeout",
+		"warning_duration": 600,
+	})
+
+	b.run(".warn <@" + testUserID + "> one")
+	if b.member(testUserID).CommunicationDisabledUntil != nil {
+		t.Fatal("timed out before reaching the limit")
+	}
+
+	b.run(".warn <@" + testUserID + "> two")
+	until := b.member(testUserID).CommunicationDisabledUntil
+	if until == nil || until.Sub(time.Now()) > 10*time.Minute || until.Sub(time.Now()) < 9*time.Minute {
+		t.Errorf("expected a 10 minute timeout at the limit, got %v", until)
+	}
+}
+
+func TestIgnoreUser(t *testing.T) {
+	b := newTestBot(t, nil)
+
+	b.run(".ignore <@" + testUserID + ">")
+	b.run(".ignore <@" + testUserID + ">")
+
+	conf, _ := b.Store.Get(testGuildID)
+	if len(conf.IgnoredUsers) != 1 {
+		t.Errorf("expected the user to be ignored once, got %v", conf.IgnoredUsers)
+	}
+}
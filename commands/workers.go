@@ -0,0 +1,169 @@
+package commands
+
+import (
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const (
+	// commandWorkers is how many events are handled at once.
+	commandWorkers = 16
+	// commandQueue is how many events may wait for a worker before new ones are dropped.
+	commandQueue = 256
+	// taskWorkers and taskQueue size the separate pool scheduled jobs run on, so a busy command
+	// pool can't hold them up.
+	taskWorkers = 4
+	taskQueue   = 64
+	// defaultCommandTimeout is how long a command may run when it doesn't set its own Timeout.
+	defaultCommandTimeout = 30 * time.Second
+)
+
+// WorkerPool runs jobs on a fixed number of goroutines so a burst of events can't spawn
+// an unbounded number of handlers.
+type WorkerPool struct {
+	jobs chan func()
+	wg   sync.WaitGroup
+
+	closeOnce sync.Once
+}
+
+func NewWorkerPool(workers int, queue int) *WorkerPool {
+	wp := &WorkerPool{
+		jobs: make(chan func(), queue),
+	}
+
+	for i := 0; i < workers; i++ {
+		go wp.work()
+	}
+
+	return wp
+}
+
+func (wp *WorkerPool) work() {
+	for job := range wp.jobs {
+		wp.run(job)
+	}
+}
+
+func (wp *WorkerPool) run(job func()) {
+	defer wp.wg.Done()
+	defer func() {
+		// Last line of defence, handlers report their own panics with more context
+		if r := recover(); r != nil {
+			log.Printf("[!] Worker panicked: %v\n%s", r, debug.Stack())
+		}
+	}()
+
+	job()
+}
+
+// Submit queues a job, returning false if the queue is full or the pool has stopped.
+func (wp *WorkerPool) Submit(job func()) (ok bool) {
+	defer func() {
+		// Sending on the closed queue of a stopped pool
+		if recover() != nil {
+			wp.wg.Done()
+			ok = false
+		}
+	}()
+
+	wp.wg.Add(1)
+	select {
+	case wp.jobs <- job:
+		return true
+	default:
+		wp.wg.Done()
+		return false
+	}
+}
+
+// SubmitWait queues a job, waiting for room in the queue if it is full. It returns false only
+// if the pool has stopped.
+func (wp *WorkerPool) SubmitWait(job func()) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			wp.wg.Done()
+			ok = false
+		}
+	}()
+
+	wp.wg.Add(1)
+	wp.jobs <- job
+
+	return true
+}
+
+// Wait blocks until every queued job has finished.
+func (wp *WorkerPool) Wait() {
+	wp.wg.Wait()
+}
+
+// Stop finishes the queued jobs and then stops the workers.
+func (wp *WorkerPool) Stop() {
+	wp.closeOnce.Do(func() {
+		close(wp.jobs)
+	})
+	wp.Wait()
+}
+
+// Scheduler runs jobs on a worker pool once their time comes, in place of sleeping goroutines.
+// Jobs wait for a free worker rather than being dropped, they stand for tasks that have to happen.
+type Scheduler struct {
+	sync.Mutex
+
+	workers *WorkerPool
+	timers  map[int]*time.Timer
+	nextID  int
+	stopped bool
+}
+
+func NewScheduler(workers *WorkerPool) *Scheduler {
+	return &Scheduler{
+		workers: workers,
+		timers:  make(map[int]*time.Timer),
+	}
+}
+
+// After runs job once d has passed.
+func (sc *Scheduler) After(d time.Duration, job func()) {
+	sc.Lock()
+	defer sc.Unlock()
+
+	if sc.stopped {
+		return
+	}
+
+	sc.nextID++
+	id := sc.nextID
+	sc.timers[id] = time.AfterFunc(d, func() {
+		sc.Lock()
+		delete(sc.timers, id)
+		sc.Unlock()
+
+		if !sc.workers.SubmitWait(job) {
+			log.Println("[!] Dropping scheduled job, the scheduler has stopped")
+		}
+	})
+}
+
+// Pending returns how many jobs are waiting to run.
+func (sc *Scheduler) Pending() int {
+	sc.Lock()
+	defer sc.Unlock()
+
+	return len(sc.timers)
+}
+
+// Stop cancels every job that hasn't run yet.
+func (sc *Scheduler) Stop() {
+	sc.Lock()
+	defer sc.Unlock()
+
+	sc.stopped = true
+	for id, t := range sc.timers {
+		t.Stop()
+		delete(sc.timers, id)
+	}
+}
@@ -0,0 +1,234 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/foxtrot/scuzzy/actions"
+	"github.com/foxtrot/scuzzy/models"
+	"github.com/foxtrot/scuzzy/permissions"
+	"github.com/foxtrot/scuzzy/templates"
+)
+
+// DefaultFile is the configuration new guilds start from when they have none of their own.
+const DefaultFile = "default.json"
+
+// Store holds the configuration of every guild Scuzzy serves, one JSON file per guild.
+type Store struct {
+	Path string
+
+	sync.RWMutex
+	guilds map[string]*models.Configuration
+}
+
+func readConfig(path string) (*models.Configuration, error) {
+	cf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	conf := &models.Configuration{}
+	err = json.Unmarshal(cf, conf)
+	if err != nil {
+		return nil, err
+	}
+	conf.ConfigPath = path
+
+	err = Validate(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return conf, nil
+}
+
+// Validate checks the settings of a configuration that can't be checked by decoding it alone.
+func Validate(conf *models.Configuration) error {
+	err := templates.Validate(conf)
+	if err != nil {
+		return err
+	}
+	err = permissions.Validate(conf)
+	if err != nil {
+		return err
+	}
+
+	switch conf.WarningAction {
+	case "", "alert", actions.ActionKick, actions.ActionBan:
+	case actions.ActionTimeout:
+		if conf.WarningDuration <= 0 {
+			return errors.New("warning_action: timeout needs a warning_duration")
+		}
+	default:
+		return errors.New("warning_action: unknown action '" + conf.WarningAction + "'")
+	}
+
+	return nil
+}
+
+// NewStore loads every guild configuration in a directory. A single config file is also
+// accepted for existing single guild setups, new guilds are then stored alongside it.
+func NewStore(path string) (*Store, error) {
+	st := &Store{
+		Path:   path,
+		guilds: make(map[string]*models.Configuration),
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	if fi.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		files = []string{path}
+		st.Path = filepath.Dir(path)
+	}
+
+	for _, f := range files {
+		if filepath.Base(f) == DefaultFile {
+			continue
+		}
+
+		conf, err := readConfig(f)
+		if err != nil {
+			return nil, errors.New(f + ": " + err.Error())
+		}
+		if len(conf.GuildID) == 0 {
+			return nil, errors.New(f + ": missing guild_id")
+		}
+
+		st.guilds[conf.GuildID] = conf
+	}
+
+	return st, nil
+}
+
+func (st *Store) guildPath(guildID string) string {
+	return filepath.Join(st.Path, guildID+".json")
+}
+
+// Get returns a guild's configuration, creating it from the default if the guild is new.
+func (st *Store) Get(guildID string) (*models.Configuration, error) {
+	st.Lock()
+	defer st.Unlock()
+
+	if conf, ok := st.guilds[guildID]; ok {
+		return conf, nil
+	}
+
+	conf, err := readConfig(filepath.Join(st.Path, DefaultFile))
+	if os.IsNotExist(err) {
+		conf = &models.Configuration{CommandKey: "."}
+	} else if err != nil {
+		return nil, err
+	}
+	conf.GuildID = guildID
+	conf.ConfigPath = st.guildPath(guildID)
+
+	st.guilds[guildID] = conf
+
+	return conf, nil
+}
+
+// Reload replaces a guild's configuration with the copy on disk.
+func (st *Store) Reload(guildID string) (*models.Configuration, error) {
+	st.Lock()
+	defer st.Unlock()
+
+	path := st.guildPath(guildID)
+	if conf, ok := st.guilds[guildID]; ok {
+		path = conf.ConfigPath
+	}
+
+	conf, err := readConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if conf.GuildID != guildID {
+		return nil, errors.New("Configuration on disk belongs to another guild")
+	}
+
+	st.guilds[guildID] = conf
+
+	return conf, nil
+}
+
+// Update applies change to a copy of a guild's configuration and swaps the copy in, so anything
+// still holding the old configuration never sees it half changed. Nothing changes if change fails.
+func (st *Store) Update(guildID string, change func(conf *models.Configuration) error) (*models.Configuration, error) {
+	st.Lock()
+	defer st.Unlock()
+
+	current, ok := st.guilds[guildID]
+	if !ok {
+		return nil, errors.New("No configuration for guild " + guildID)
+	}
+
+	conf, err := copyConfig(current)
+	if err != nil {
+		return nil, err
+	}
+
+	err = change(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	st.guilds[guildID] = conf
+
+	return conf, nil
+}
+
+// copyConfig deep copies a configuration through JSON, which every field round trips through.
+func copyConfig(conf *models.Configuration) (*models.Configuration, error) {
+	j, err := json.Marshal(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	cp := &models.Configuration{}
+	err = json.Unmarshal(j, cp)
+	if err != nil {
+		return nil, err
+	}
+
+	return cp, nil
+}
+
+// Save writes a guild's configuration back to disk.
+func (st *Store) Save(conf *models.Configuration) error {
+	j, err := json.Marshal(conf)
+	if err != nil {
+		return err
+	}
+
+	path := conf.ConfigPath
+	if len(path) == 0 {
+		path = st.guildPath(conf.GuildID)
+	}
+
+	return ioutil.WriteFile(path, j, os.ModePerm)
+}
+
+// GuildIDs lists every guild with a configuration.
+func (st *Store) GuildIDs() []string {
+	st.RLock()
+	defer st.RUnlock()
+
+	var ids []string
+	for id := range st.guilds {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
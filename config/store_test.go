@@ -0,0 +1,180 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/foxtrot/scuzzy/models"
+)
+
+const (
+	guildID = "100000000000000001"
+	otherID = "100000000000000002"
+)
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+
+	err := ioutil.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, guildID+".json"), `{"guild_id": "`+guildID+`", "command_key": "!"}`)
+	writeFile(t, filepath.Join(dir, DefaultFile), `{"command_key": "?"}`)
+
+	st, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := st.GuildIDs(); len(ids) != 1 || ids[0] != guildID {
+		t.Errorf("the default shouldn't be loaded as a guild, got %v", ids)
+	}
+
+	conf, err := st.Get(guildID)
+	if err != nil || conf.CommandKey != "!" {
+		t.Errorf("got %+v, %v", conf, err)
+	}
+
+	// New guilds start from the default and are saved next to the others
+	conf, err = st.Get(otherID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.CommandKey != "?" || conf.GuildID != otherID || conf.ConfigPath != filepath.Join(dir, otherID+".json") {
+		t.Errorf("new guild not made from the default: %+v", conf)
+	}
+}
+
+func TestNewStoreSingleFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bot_config.json")
+	writeFile(t, path, `{"guild_id": "`+guildID+`"}`)
+
+	st, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf, err := st.Get(guildID)
+	if err != nil || conf.ConfigPath != path {
+		t.Errorf("got %+v, %v", conf, err)
+	}
+
+	// Without a default, new guilds get a bare configuration
+	conf, err = st.Get(otherID)
+	if err != nil || conf.CommandKey != "." || conf.ConfigPath != filepath.Join(dir, otherID+".json") {
+		t.Errorf("got %+v, %v", conf, err)
+	}
+}
+
+func TestNewStoreInvalid(t *testing.T) {
+	tests := map[string]string{
+		"no guild":                 `{"command_key": "!"}`,
+		"bad json":                 `{"guild_id": `,
+		"bad template":             `{"guild_id": "` + guildID + `", "welcome_text": {"content": "{{.Nope"}}`,
+		"unknown tier":             `{"guild_id": "` + guildID + `", "command_permissions": [{"command": "ping", "tier": "owner"}]}`,
+		"unknown permission":       `{"guild_id": "` + guildID + `", "command_permissions": [{"command": "ping", "permissions": ["Fly"]}]}`,
+		"timeout without duration": `{"guild_id": "` + guildID + `", "warning_action": "timeout"}`,
+		"unknown warning action":   `{"guild_id": "` + guildID + `", "warning_action": "explode"}`,
+	}
+	for name, content := range tests {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, guildID+".json"), content)
+
+		if _, err := NewStore(dir); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, guildID+".json"), `{"guild_id": "`+guildID+`", "ignored_users": ["1"]}`)
+
+	st, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, _ := st.Get(guildID)
+
+	conf, err := st.Update(guildID, func(conf *models.Configuration) error {
+		conf.IgnoredUsers = append(conf.IgnoredUsers, "2")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.IgnoredUsers) != 2 || conf.ConfigPath != old.ConfigPath {
+		t.Errorf("change not applied: %+v", conf)
+	}
+	if len(old.IgnoredUsers) != 1 {
+		t.Errorf("the old configuration shouldn't change, got %v", old.IgnoredUsers)
+	}
+	if cur, _ := st.Get(guildID); cur != conf {
+		t.Error("the updated configuration should replace the old one")
+	}
+
+	// Failed changes are thrown away
+	_, err = st.Update(guildID, func(conf *models.Configuration) error {
+		conf.IgnoredUsers = nil
+		return errors.New("no")
+	})
+	if err == nil {
+		t.Error("expected the change's error")
+	}
+	if cur, _ := st.Get(guildID); len(cur.IgnoredUsers) != 2 {
+		t.Errorf("a failed change shouldn't be kept, got %v", cur.IgnoredUsers)
+	}
+
+	if _, err := st.Update(otherID, func(*models.Configuration) error { return nil }); err == nil {
+		t.Error("unknown guilds shouldn't be updated")
+	}
+}
+
+func TestSaveReload(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, guildID+".json"), `{"guild_id": "`+guildID+`", "command_key": "!"}`)
+
+	st, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf, err := st.Update(guildID, func(conf *models.Configuration) error {
+		conf.CommandKey = "?"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Save(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf, _ := reloaded.Get(guildID); conf.CommandKey != "?" {
+		t.Errorf("saved change not read back, got %q", conf.CommandKey)
+	}
+
+	// Reload picks up edits made on disk
+	writeFile(t, filepath.Join(dir, guildID+".json"), `{"guild_id": "`+guildID+`", "command_key": "$"}`)
+	conf, err = st.Reload(guildID)
+	if err != nil || conf.CommandKey != "$" {
+		t.Errorf("got %+v, %v", conf, err)
+	}
+
+	writeFile(t, filepath.Join(dir, guildID+".json"), `{"guild_id": "`+otherID+`"}`)
+	if _, err := st.Reload(guildID); err == nil {
+		t.Error("a file for another guild shouldn't be loaded")
+	}
+}
@@ -0,0 +1,579 @@
+// Package discordtest provides an in-memory discord.Session for running handlers offline.
+package discordtest
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/discord"
+)
+
+var _ discord.Session = (*Session)(nil)
+
+// Call is one request made against the fake session.
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// Session fakes the Discord API on top of a discordgo.State. Guilds, channels and members
+// added to State are what the REST methods read and change, and every call is recorded.
+type Session struct {
+	sync.Mutex
+
+	State *discordgo.State
+
+	// Calls lists every request in the order it was made.
+	Calls []Call
+	// Sent holds every message sent, keyed by channel ID. Followups are keyed by the interaction's channel.
+	Sent map[string][]*discordgo.Message
+	// Commands holds the slash commands published to each guild.
+	Commands map[string][]*discordgo.ApplicationCommand
+	// Bans holds each guild's bans, keyed by user ID.
+	Bans map[string]map[string]*discordgo.GuildBan
+	// Errors makes a method fail with the given error instead of running.
+	Errors map[string]error
+
+	Status    string
+	Connected bool
+
+	nextID int
+}
+
+// NewSession returns a fake session whose bot user is botID.
+func NewSession(botID string) *Session {
+	state := discordgo.NewState()
+	state.MaxMessageCount = 100
+	state.User = &discordgo.User{ID: botID, Username: "Scuzzy", Bot: true}
+
+	return &Session{
+		State:     state,
+		Sent:      make(map[string][]*discordgo.Message),
+		Commands:  make(map[string][]*discordgo.ApplicationCommand),
+		Bans:      make(map[string]map[string]*discordgo.GuildBan),
+		Errors:    make(map[string]error),
+		Connected: true,
+	}
+}
+
+// CallsTo returns the recorded calls to one method.
+func (s *Session) CallsTo(method string) []Call {
+	s.Lock()
+	defer s.Unlock()
+
+	var calls []Call
+	for _, c := range s.Calls {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+
+	return calls
+}
+
+// record logs a call and returns the error configured for the method, if any.
+func (s *Session) record(method string, args ...interface{}) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.Calls = append(s.Calls, Call{Method: method, Args: args})
+
+	return s.Errors[method]
+}
+
+func (s *Session) newID() string {
+	s.Lock()
+	defer s.Unlock()
+
+	s.nextID++
+	return strconv.Itoa(900000000000000000 + s.nextID)
+}
+
+func (s *Session) send(channelID string, msg *discordgo.Message) *discordgo.Message {
+	msg.ID = s.newID()
+	msg.ChannelID = channelID
+	msg.Author = s.State.User
+
+	s.Lock()
+	s.Sent[channelID] = append(s.Sent[channelID], msg)
+	s.Unlock()
+
+	if channel, err := s.State.Channel(channelID); err == nil {
+		msg.GuildID = channel.GuildID
+		_ = s.State.MessageAdd(msg)
+	}
+
+	return msg
+}
+
+// removeMessage drops a message from the state. Messages the state never saw, such as the
+// command that invoked a handler, are deleted without complaint like the API would.
+func (s *Session) removeMessage(channelID, messageID string) error {
+	err := s.State.MessageRemove(&discordgo.Message{ID: messageID, ChannelID: channelID})
+	if errors.Is(err, discordgo.ErrStateNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+func (s *Session) GetState() *discordgo.State {
+	return s.State
+}
+
+func (s *Session) Open() error {
+	if err := s.record("Open"); err != nil {
+		return err
+	}
+
+	s.Connected = true
+	return nil
+}
+
+func (s *Session) Close() error {
+	if err := s.record("Close"); err != nil {
+		return err
+	}
+
+	s.Connected = false
+	return nil
+}
+
+func (s *Session) UpdateGameStatus(idle int, name string) error {
+	if err := s.record("UpdateGameStatus", idle, name); err != nil {
+		return err
+	}
+
+	s.Status = name
+	return nil
+}
+
+func (s *Session) ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	if err := s.record("ChannelMessageSend", channelID, content); err != nil {
+		return nil, err
+	}
+
+	return s.send(channelID, &discordgo.Message{Content: content}), nil
+}
+
+func (s *Session) ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	if err := s.record("ChannelMessageSendEmbed", channelID, embed); err != nil {
+		return nil, err
+	}
+
+	return s.send(channelID, &discordgo.Message{Embeds: []*discordgo.MessageEmbed{embed}}), nil
+}
+
+func (s *Session) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	if err := s.record("ChannelMessageSendComplex", channelID, data); err != nil {
+		return nil, err
+	}
+
+	return s.send(channelID, &discordgo.Message{
+		Content:    data.Content,
+		Embeds:     data.Embeds,
+		Components: data.Components,
+	}), nil
+}
+
+func (s *Session) ChannelMessageEditEmbed(channelID, messageID string, embed *discordgo.MessageEmbed, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	if err := s.record("ChannelMessageEditEmbed", channelID, messageID, embed); err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	for _, msg := range s.Sent[channelID] {
+		if msg.ID == messageID {
+			msg.Embeds = []*discordgo.MessageEmbed{embed}
+			return msg, nil
+		}
+	}
+
+	return nil, &discordgo.RESTError{Message: &discordgo.APIErrorMessage{Code: discordgo.ErrCodeUnknownMessage, Message: "Unknown Message"}}
+}
+
+func (s *Session) ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error) {
+	if err := s.record("ChannelMessages", channelID, limit, beforeID, afterID, aroundID); err != nil {
+		return nil, err
+	}
+
+	channel, err := s.State.Channel(channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Newest first, like the API
+	var msgs []*discordgo.Message
+	for k := len(channel.Messages) - 1; k >= 0 && len(msgs) < limit; k-- {
+		msg := channel.Messages[k]
+		if len(beforeID) > 0 && !snowflakeLess(msg.ID, beforeID) {
+			continue
+		}
+		if len(afterID) > 0 && !snowflakeLess(afterID, msg.ID) {
+			continue
+		}
+		msgs = append(msgs, msg)
+	}
+
+	return msgs, nil
+}
+
+func (s *Session) ChannelMessageDelete(channelID, messageID string, options ...discordgo.RequestOption) error {
+	if err := s.record("ChannelMessageDelete", channelID, messageID); err != nil {
+		return err
+	}
+
+	return s.removeMessage(channelID, messageID)
+}
+
+func (s *Session) ChannelMessagesBulkDelete(channelID string, messages []string, options ...discordgo.RequestOption) error {
+	if err := s.record("ChannelMessagesBulkDelete", channelID, messages); err != nil {
+		return err
+	}
+
+	for _, id := range messages {
+		_ = s.removeMessage(channelID, id)
+	}
+
+	return nil
+}
+
+func (s *Session) UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	if err := s.record("UserChannelCreate", recipientID); err != nil {
+		return nil, err
+	}
+
+	return &discordgo.Channel{
+		ID:   "dm-" + recipientID,
+		Type: discordgo.ChannelTypeDM,
+	}, nil
+}
+
+func (s *Session) ApplicationCommandBulkOverwrite(appID string, guildID string, commands []*discordgo.ApplicationCommand, options ...discordgo.RequestOption) ([]*discordgo.ApplicationCommand, error) {
+	if err := s.record("ApplicationCommandBulkOverwrite", appID, guildID, commands); err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	s.Commands[guildID] = commands
+	s.Unlock()
+
+	return commands, nil
+}
+
+func (s *Session) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	return s.record("InteractionRespond", interaction, resp)
+}
+
+func (s *Session) InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	if err := s.record("InteractionResponseEdit", interaction, newresp); err != nil {
+		return nil, err
+	}
+
+	msg := &discordgo.Message{ChannelID: interaction.ChannelID}
+	if interaction.Message != nil {
+		msg = interaction.Message
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if newresp.Content != nil {
+		msg.Content = *newresp.Content
+	}
+	if newresp.Embeds != nil {
+		msg.Embeds = *newresp.Embeds
+	}
+	if newresp.Components != nil {
+		msg.Components = *newresp.Components
+	}
+
+	return msg, nil
+}
+
+func (s *Session) FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	if err := s.record("FollowupMessageCreate", interaction, wait, data); err != nil {
+		return nil, err
+	}
+
+	return s.send(interaction.ChannelID, &discordgo.Message{
+		Content:    data.Content,
+		Embeds:     data.Embeds,
+		Components: data.Components,
+		Flags:      data.Flags,
+	}), nil
+}
+
+func (s *Session) FollowupMessageDelete(interaction *discordgo.Interaction, messageID string, options ...discordgo.RequestOption) error {
+	if err := s.record("FollowupMessageDelete", interaction, messageID); err != nil {
+		return err
+	}
+
+	return s.removeMessage(interaction.ChannelID, messageID)
+}
+
+func (s *Session) Guild(guildID string, options ...discordgo.RequestOption) (*discordgo.Guild, error) {
+	if err := s.record("Guild", guildID); err != nil {
+		return nil, err
+	}
+
+	return s.State.Guild(guildID)
+}
+
+func (s *Session) GuildEdit(guildID string, g *discordgo.GuildParams, options ...discordgo.RequestOption) (*discordgo.Guild, error) {
+	if err := s.record("GuildEdit", guildID, g); err != nil {
+		return nil, err
+	}
+
+	guild, err := s.State.Guild(guildID)
+	if err != nil {
+		return nil, err
+	}
+	if len(g.Name) > 0 {
+		guild.Name = g.Name
+	}
+	if g.VerificationLevel != nil {
+		guild.VerificationLevel = *g.VerificationLevel
+	}
+
+	return guild, nil
+}
+
+func (s *Session) GuildChannels(guildID string, options ...discordgo.RequestOption) ([]*discordgo.Channel, error) {
+	if err := s.record("GuildChannels", guildID); err != nil {
+		return nil, err
+	}
+
+	guild, err := s.State.Guild(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	return guild.Channels, nil
+}
+
+func (s *Session) GuildRoles(guildID string, options ...discordgo.RequestOption) ([]*discordgo.Role, error) {
+	if err := s.record("GuildRoles", guildID); err != nil {
+		return nil, err
+	}
+
+	guild, err := s.State.Guild(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	return guild.Roles, nil
+}
+
+func (s *Session) Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	if err := s.record("Channel", channelID); err != nil {
+		return nil, err
+	}
+
+	return s.State.Channel(channelID)
+}
+
+func (s *Session) ChannelEditComplex(channelID string, data *discordgo.ChannelEdit, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	if err := s.record("ChannelEditComplex", channelID, data); err != nil {
+		return nil, err
+	}
+
+	channel, err := s.State.Channel(channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data.Name) > 0 {
+		channel.Name = data.Name
+	}
+	if len(data.Topic) > 0 {
+		channel.Topic = data.Topic
+	}
+	if data.RateLimitPerUser != nil {
+		channel.RateLimitPerUser = *data.RateLimitPerUser
+	}
+
+	return channel, nil
+}
+
+func (s *Session) User(userID string, options ...discordgo.RequestOption) (*discordgo.User, error) {
+	if err := s.record("User", userID); err != nil {
+		return nil, err
+	}
+
+	for _, guild := range s.State.Guilds {
+		if member, err := s.State.Member(guild.ID, userID); err == nil {
+			return member.User, nil
+		}
+	}
+
+	return nil, discordgo.ErrStateNotFound
+}
+
+func (s *Session) GuildMember(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.Member, error) {
+	if err := s.record("GuildMember", guildID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.State.Member(guildID, userID)
+}
+
+func (s *Session) GuildMemberRoleAdd(guildID, userID, roleID string, options ...discordgo.RequestOption) error {
+	if err := s.record("GuildMemberRoleAdd", guildID, userID, roleID); err != nil {
+		return err
+	}
+
+	member, err := s.State.Member(guildID, userID)
+	if err != nil {
+		return err
+	}
+	for _, r := range member.Roles {
+		if r == roleID {
+			return nil
+		}
+	}
+	member.Roles = append(member.Roles, roleID)
+
+	return nil
+}
+
+func (s *Session) GuildMemberRoleRemove(guildID, userID, roleID string, options ...discordgo.RequestOption) error {
+	if err := s.record("GuildMemberRoleRemove", guildID, userID, roleID); err != nil {
+		return err
+	}
+
+	member, err := s.State.Member(guildID, userID)
+	if err != nil {
+		return err
+	}
+	for k, r := range member.Roles {
+		if r == roleID {
+			member.Roles = append(member.Roles[:k], member.Roles[k+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+func (s *Session) removeMember(guildID, userID string) error {
+	member, err := s.State.Member(guildID, userID)
+	if err != nil {
+		return err
+	}
+
+	return s.State.MemberRemove(member)
+}
+
+func (s *Session) GuildMemberDeleteWithReason(guildID, userID, reason string, options ...discordgo.RequestOption) error {
+	if err := s.record("GuildMemberDeleteWithReason", guildID, userID, reason); err != nil {
+		return err
+	}
+
+	return s.removeMember(guildID, userID)
+}
+
+func (s *Session) GuildBanCreateWithReason(guildID, userID, reason string, days int, options ...discordgo.RequestOption) error {
+	if err := s.record("GuildBanCreateWithReason", guildID, userID, reason, days); err != nil {
+		return err
+	}
+
+	user := &discordgo.User{ID: userID}
+	if member, err := s.State.Member(guildID, userID); err == nil {
+		user = member.User
+	}
+
+	s.Lock()
+	if s.Bans[guildID] == nil {
+		s.Bans[guildID] = make(map[string]*discordgo.GuildBan)
+	}
+	s.Bans[guildID][userID] = &discordgo.GuildBan{User: user, Reason: reason}
+	s.Unlock()
+
+	// Users can be banned without being members
+	if err := s.removeMember(guildID, userID); err != nil && !errors.Is(err, discordgo.ErrStateNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Session) GuildBanDelete(guildID, userID string, options ...discordgo.RequestOption) error {
+	if err := s.record("GuildBanDelete", guildID, userID); err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.Bans[guildID][userID]; !ok {
+		return &discordgo.RESTError{Message: &discordgo.APIErrorMessage{Code: discordgo.ErrCodeUnknownBan, Message: "Unknown Ban"}}
+	}
+	delete(s.Bans[guildID], userID)
+
+	return nil
+}
+
+func (s *Session) GuildBans(guildID string, limit int, beforeID, afterID string, options ...discordgo.RequestOption) ([]*discordgo.GuildBan, error) {
+	if err := s.record("GuildBans", guildID, limit, beforeID, afterID); err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	var bans []*discordgo.GuildBan
+	for _, ban := range s.Bans[guildID] {
+		if len(afterID) > 0 && !snowflakeLess(afterID, ban.User.ID) {
+			continue
+		}
+		if len(beforeID) > 0 && !snowflakeLess(ban.User.ID, beforeID) {
+			continue
+		}
+		bans = append(bans, ban)
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		return snowflakeLess(bans[i].User.ID, bans[j].User.ID)
+	})
+	if limit > 0 && len(bans) > limit {
+		bans = bans[:limit]
+	}
+
+	return bans, nil
+}
+
+func snowflakeLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+
+	return a < b
+}
+
+func (s *Session) GuildMemberTimeout(guildID string, userID string, until *time.Time, options ...discordgo.RequestOption) error {
+	if err := s.record("GuildMemberTimeout", guildID, userID, until); err != nil {
+		return err
+	}
+
+	member, err := s.State.Member(guildID, userID)
+	if err != nil {
+		return err
+	}
+	member.CommunicationDisabledUntil = until
+
+	return nil
+}
+
+func (s *Session) UserChannelPermissions(userID, channelID string, options ...discordgo.RequestOption) (int64, error) {
+	if err := s.record("UserChannelPermissions", userID, channelID); err != nil {
+		return 0, err
+	}
+
+	return s.State.UserChannelPermissions(userID, channelID)
+}
@@ -0,0 +1,195 @@
+package discordtest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	guildID   = "100000000000000001"
+	channelID = "300000000000000001"
+	userID    = "500000000000000002"
+)
+
+func testSession(t *testing.T) *Session {
+	t.Helper()
+
+	s := NewSession("200000000000000000")
+	err := s.State.GuildAdd(&discordgo.Guild{
+		ID:       guildID,
+		Channels: []*discordgo.Channel{{ID: channelID, GuildID: guildID}},
+		Members: []*discordgo.Member{{
+			GuildID: guildID,
+			User:    &discordgo.User{ID: userID, Username: "bob"},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestSend(t *testing.T) {
+	s := testSession(t)
+
+	msg, err := s.ChannelMessageSend(channelID, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msg.ID) == 0 || msg.GuildID != guildID || msg.Author.ID != s.State.User.ID {
+		t.Errorf("message not filled in: %+v", msg)
+	}
+	if len(s.Sent[channelID]) != 1 || s.Sent[channelID][0].Content != "hello" {
+		t.Errorf("Sent = %v", s.Sent[channelID])
+	}
+
+	calls := s.CallsTo("ChannelMessageSend")
+	if len(calls) != 1 || calls[0].Args[0] != channelID || calls[0].Args[1] != "hello" {
+		t.Errorf("calls = %v", calls)
+	}
+
+	edited, err := s.ChannelMessageEditEmbed(channelID, msg.ID, &discordgo.MessageEmbed{Title: "Edited"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.Embeds[0].Title != "Edited" {
+		t.Errorf("embed not replaced: %v", edited.Embeds)
+	}
+	if _, err := s.ChannelMessageEditEmbed(channelID, "1", &discordgo.MessageEmbed{}); err == nil {
+		t.Error("editing an unknown message should fail")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	s := testSession(t)
+	s.Errors["ChannelMessageSend"] = errors.New("boom")
+
+	if _, err := s.ChannelMessageSend(channelID, "hello"); err == nil || err.Error() != "boom" {
+		t.Errorf("err = %v", err)
+	}
+	if len(s.Sent[channelID]) != 0 {
+		t.Error("a failed send shouldn't be kept")
+	}
+	if len(s.CallsTo("ChannelMessageSend")) != 1 {
+		t.Error("failed calls should still be recorded")
+	}
+}
+
+func TestChannelMessages(t *testing.T) {
+	s := testSession(t)
+
+	var ids []string
+	for k := 0; k < 5; k++ {
+		msg, err := s.ChannelMessageSend(channelID, "message")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, msg.ID)
+	}
+
+	msgs, err := s.ChannelMessages(channelID, 2, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].ID != ids[4] || msgs[1].ID != ids[3] {
+		t.Errorf("expected the newest two, newest first, got %v", msgs)
+	}
+
+	msgs, _ = s.ChannelMessages(channelID, 100, ids[3], ids[0], "")
+	if len(msgs) != 2 || msgs[0].ID != ids[2] || msgs[1].ID != ids[1] {
+		t.Errorf("expected the messages between, got %v", msgs)
+	}
+
+	if err := s.ChannelMessagesBulkDelete(channelID, ids[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ChannelMessageDelete(channelID, ids[2]); err != nil {
+		t.Fatal(err)
+	}
+	msgs, _ = s.ChannelMessages(channelID, 100, "", "", "")
+	if len(msgs) != 2 {
+		t.Errorf("expected 2 messages left, got %d", len(msgs))
+	}
+
+	// Messages the state never saw are deleted without complaint
+	if err := s.ChannelMessageDelete(channelID, "1"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBans(t *testing.T) {
+	s := testSession(t)
+
+	if err := s.GuildBanCreateWithReason(guildID, userID, "Spamming", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.State.Member(guildID, userID); err == nil {
+		t.Error("banned members should be removed")
+	}
+	ban := s.Bans[guildID][userID]
+	if ban == nil || ban.Reason != "Spamming" || ban.User.Username != "bob" {
+		t.Errorf("ban = %+v", ban)
+	}
+
+	// Users can be banned without being members
+	if err := s.GuildBanCreateWithReason(guildID, "500000000000000003", "", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	bans, err := s.GuildBans(guildID, 1, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bans) != 1 || bans[0].User.ID != userID {
+		t.Errorf("bans = %v", bans)
+	}
+	bans, _ = s.GuildBans(guildID, 100, "", userID)
+	if len(bans) != 1 || bans[0].User.ID != "500000000000000003" {
+		t.Errorf("bans after %s = %v", userID, bans)
+	}
+
+	if err := s.GuildBanDelete(guildID, userID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.GuildBanDelete(guildID, userID); err == nil {
+		t.Error("lifting a missing ban should fail")
+	}
+}
+
+func TestMembers(t *testing.T) {
+	s := testSession(t)
+
+	until := time.Now().Add(time.Hour)
+	if err := s.GuildMemberTimeout(guildID, userID, &until); err != nil {
+		t.Fatal(err)
+	}
+	member, _ := s.State.Member(guildID, userID)
+	if member.CommunicationDisabledUntil == nil || !member.CommunicationDisabledUntil.Equal(until) {
+		t.Errorf("timeout not applied: %v", member.CommunicationDisabledUntil)
+	}
+
+	if err := s.GuildMemberRoleAdd(guildID, userID, "400000000000000001"); err != nil {
+		t.Fatal(err)
+	}
+	_ = s.GuildMemberRoleAdd(guildID, userID, "400000000000000001")
+	if len(member.Roles) != 1 {
+		t.Errorf("roles = %v", member.Roles)
+	}
+	if err := s.GuildMemberRoleRemove(guildID, userID, "400000000000000001"); err != nil {
+		t.Fatal(err)
+	}
+	if len(member.Roles) != 0 {
+		t.Errorf("roles = %v", member.Roles)
+	}
+
+	if err := s.GuildMemberDeleteWithReason(guildID, userID, "Kicked"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GuildMember(guildID, userID); err == nil {
+		t.Error("kicked members should be removed")
+	}
+}
@@ -2,4 +2,4 @@ module github.com/foxtrot/scuzzy
 
 go 1.16
 
-require github.com/bwmarrin/discordgo v0.23.2
+require github.com/bwmarrin/discordgo v0.27.1
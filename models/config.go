@@ -1,5 +1,7 @@
 package models
 
+import "encoding/json"
+
 type ColorRole struct {
 	Name string `json:"color"`
 	ID   string `json:"id"`
@@ -12,39 +14,110 @@ type CustomRole struct {
 }
 
 type CommandRestriction struct {
-	Command  string   `json:"command"`
-	Mode     string   `json:"mode"`
-	Channels []string `json:"channels"`
+	Command    string   `json:"command"`
+	Mode       string   `json:"mode"`
+	Channels   []string `json:"channels"`
+	Categories []string `json:"categories"`
+	Roles      []string `json:"roles"`
+}
+
+type CommandPermission struct {
+	Command     string   `json:"command"`
+	Tier        string   `json:"tier"`
+	AllowRoles  []string `json:"allow_roles"`
+	DenyRoles   []string `json:"deny_roles"`
+	Permissions []string `json:"permissions"`
+}
+
+type CommandCooldown struct {
+	Command string `json:"command"`
+	User    int    `json:"user"`
+	Channel int    `json:"channel"`
+	Global  int    `json:"global"`
+}
+
+type CommandResponse struct {
+	Command          string `json:"command"`
+	DeleteInvocation bool   `json:"delete_invocation"`
+	DeleteAfter      int    `json:"delete_after"`
+	DM               bool   `json:"dm"`
+	Ephemeral        bool   `json:"ephemeral"`
+}
+
+// EscalationRule takes Action once a user has Count actions of type After on record,
+// counting only the last Within seconds when it is set.
+type EscalationRule struct {
+	After    string `json:"after"`
+	Count    int    `json:"count"`
+	Within   int    `json:"within"`
+	Action   string `json:"action"`
+	Duration int    `json:"duration"`
+}
+
+type ModuleConfig struct {
+	Enabled bool            `json:"enabled"`
+	Config  json.RawMessage `json:"config"`
 }
 
 type Configuration struct {
-	CommandKey string `json:"command_key"`
+	CommandKey  string   `json:"command_key"`
+	CommandKeys []string `json:"command_keys"`
 
 	GuildID   string `json:"guild_id"`
 	GuildName string `json:"guild_name"`
 
-	StatusText  string `json:"status_text"`
-	WelcomeText string `json:"welcome_text"`
-	RulesText   string `json:"rules_text"`
+	StatusText  string          `json:"status_text"`
+	WelcomeText MessageTemplate `json:"welcome_text"`
+	RulesText   MessageTemplate `json:"rules_text"`
+
+	// Messages overrides Scuzzy's canned replies, keyed by message name.
+	Messages map[string]MessageTemplate `json:"messages"`
 
-	AdminRoles  []string `json:"admin_roles"`
-	JoinRoleIDs []string `json:"join_role_ids"`
+	AdminRoles     []string `json:"admin_roles"`
+	ModeratorRoles []string `json:"moderator_roles"`
+	HelperRoles    []string `json:"helper_roles"`
+	JoinRoleIDs    []string `json:"join_role_ids"`
 
 	CommandRestrictions []CommandRestriction `json:"command_restrictions"`
+	CommandCooldowns    []CommandCooldown    `json:"command_cooldowns"`
+	CommandPermissions  []CommandPermission  `json:"command_permissions"`
+	CommandResponses    []CommandResponse    `json:"command_responses"`
 
 	ColorRoles  []ColorRole  `json:"color_roles"`
 	CustomRoles []CustomRole `json:"custom_roles"`
 
 	IgnoredUsers []string `json:"ignored_users"`
 
+	// MuteMode is "timeout" to use Discord's timeouts, or "role" to give muted members MuteRoleID.
+	MuteMode   string `json:"mute_mode"`
+	MuteRoleID string `json:"mute_role_id"`
+
+	Escalations []EscalationRule `json:"escalations"`
+
+	// JoinFloodThreshold joins within JoinFloodWindow seconds start raid mode, 0 turns raid detection off.
+	JoinFloodThreshold int
+	JoinFloodWindow    int `json:"join_flood_window"`
+	// RaidCooldown is how many seconds raid mode lasts after the last join.
+	RaidCooldown int `json:"raid_cooldown"`
+	// RaidAction is taken against members who join during a raid, "kick", "timeout" or "" for neither.
+	RaidAction            string `json:"raid_action"`
+	RaidVerificationLevel int    `json:"raid_verification_level"`
+
+	Modules map[string]ModuleConfig `json:"modules"`
+
 	LoggingChannel string `json:"logging_channel"`
+	// ModLogChannel receives the moderation case log, the logging channel is used when it is empty.
+	ModLogChannel string `json:"mod_log_channel"`
 
 	ConfigPath string
 
 	FilterLanguage       bool
-	JoinFloodThreshold   int
 	UserMessageThreshold int
 	MaxUserWarnings      int
-	MaxUserKicks         int
-	EnforceMode          bool
+	// WarningAction is taken at MaxUserWarnings, "alert", "kick", "ban" or "timeout". WarningDuration
+	// is how many seconds a timeout, or a ban if set, lasts.
+	WarningAction   string `json:"warning_action"`
+	WarningDuration int    `json:"warning_duration"`
+	MaxUserKicks    int
+	EnforceMode     bool
 }
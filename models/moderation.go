@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Warning is a note staff have made against a user. Warnings past their expiry no longer count.
+type Warning struct {
+	ID          int        `json:"id"`
+	UserID      string     `json:"user_id"`
+	ModeratorID string     `json:"moderator_id"`
+	Reason      string     `json:"reason"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// Active reports whether a warning still counts against the user.
+func (w Warning) Active(now time.Time) bool {
+	return w.ExpiresAt == nil || w.ExpiresAt.After(now)
+}
+
+// Case is one numbered entry in a guild's moderation log.
+type Case struct {
+	ID          int           `json:"id"`
+	Action      string        `json:"action"`
+	UserID      string        `json:"user_id"`
+	ModeratorID string        `json:"moderator_id"`
+	Reason      string        `json:"reason"`
+	Duration    time.Duration `json:"duration,omitempty"`
+	Evidence    []string      `json:"evidence,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedBy   string        `json:"updated_by,omitempty"`
+	UpdatedAt   *time.Time    `json:"updated_at,omitempty"`
+
+	// LogChannelID and LogMessageID locate the case's post in the moderation log.
+	LogChannelID string `json:"log_channel_id,omitempty"`
+	LogMessageID string `json:"log_message_id,omitempty"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Tag is a custom text command created by a guild's admins.
+type Tag struct {
+	Name    string                  `json:"name"`
+	Content string                  `json:"content"`
+	Embed   *discordgo.MessageEmbed `json:"embed,omitempty"`
+
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedBy string    `json:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "encoding/json"
+
+type EmbedFieldTemplate struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type EmbedTemplate struct {
+	Title       string               `json:"title"`
+	Description string               `json:"description"`
+	URL         string               `json:"url"`
+	Color       int                  `json:"color"`
+	ImageURL    string               `json:"image"`
+	Thumbnail   string               `json:"thumbnail"`
+	AuthorText  string               `json:"author"`
+	FooterText  string               `json:"footer"`
+	Fields      []EmbedFieldTemplate `json:"fields"`
+}
+
+// MessageTemplate is a message whose text is a Go template. In a config it is either a plain
+// string, or an object with "content" and an "embed".
+type MessageTemplate struct {
+	Content string         `json:"content"`
+	Embed   *EmbedTemplate `json:"embed,omitempty"`
+}
+
+type messageTemplate MessageTemplate
+
+func (t *MessageTemplate) UnmarshalJSON(b []byte) error {
+	var content string
+	if err := json.Unmarshal(b, &content); err == nil {
+		*t = MessageTemplate{Content: content}
+		return nil
+	}
+
+	return json.Unmarshal(b, (*messageTemplate)(t))
+}
+
+func (t MessageTemplate) MarshalJSON() ([]byte, error) {
+	// Plain text stays a plain string so existing configs keep their shape
+	if t.Embed == nil {
+		return json.Marshal(t.Content)
+	}
+
+	return json.Marshal(messageTemplate(t))
+}
@@ -1,60 +1,171 @@
 package permissions
 
 import (
+	"errors"
+	"sort"
+	"strings"
+
 	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/discord"
 	"github.com/foxtrot/scuzzy/models"
-	"strings"
 )
 
-type AdminRole struct {
+type Tier int
+
+const (
+	TierEveryone Tier = iota
+	TierHelper
+	TierModerator
+	TierAdmin
+)
+
+func (t Tier) String() string {
+	switch t {
+	case TierHelper:
+		return "helper"
+	case TierModerator:
+		return "moderator"
+	case TierAdmin:
+		return "admin"
+	default:
+		return "everyone"
+	}
+}
+
+func ParseTier(tier string) (Tier, error) {
+	switch strings.ToLower(tier) {
+	case "", "everyone":
+		return TierEveryone, nil
+	case "helper":
+		return TierHelper, nil
+	case "moderator", "mod":
+		return TierModerator, nil
+	case "admin":
+		return TierAdmin, nil
+	}
+
+	return TierEveryone, errors.New("Unknown tier '" + tier + "'")
+}
+
+var permissionNames = map[string]int64{
+	"Administrator":   discordgo.PermissionAdministrator,
+	"KickMembers":     discordgo.PermissionKickMembers,
+	"BanMembers":      discordgo.PermissionBanMembers,
+	"ModerateMembers": discordgo.PermissionModerateMembers,
+	"ManageChannels":  discordgo.PermissionManageChannels,
+	"ManageServer":    discordgo.PermissionManageServer,
+	"ManageRoles":     discordgo.PermissionManageRoles,
+	"ManageMessages":  discordgo.PermissionManageMessages,
+	"ManageNicknames": discordgo.PermissionManageNicknames,
+	"ViewAuditLogs":   discordgo.PermissionViewAuditLogs,
+	"MentionEveryone": discordgo.PermissionMentionEveryone,
+}
+
+func ParsePermissions(names []string) (int64, error) {
+	var perms int64
+	for _, name := range names {
+		found := false
+		for pName, perm := range permissionNames {
+			if strings.EqualFold(pName, name) {
+				perms |= perm
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, errors.New("Unknown permission '" + name + "'")
+		}
+	}
+
+	return perms, nil
+}
+
+// PermissionNames lists the names of the permissions set in perms, in the form the config uses.
+func PermissionNames(perms int64) []string {
+	var names []string
+	for pName, perm := range permissionNames {
+		if perms&perm != 0 {
+			names = append(names, pName)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+type StaffRole struct {
 	Name string
 	ID   string
 }
 
+// CommandAccess describes who may run a command.
+type CommandAccess struct {
+	Tier        Tier
+	AllowRoles  []string
+	DenyRoles   []string
+	Permissions int64
+}
+
 type Permissions struct {
-	AdminRoles          []AdminRole
-	CommandRestrictions []models.CommandRestriction
+	AdminRoles     []StaffRole
+	ModeratorRoles []StaffRole
+	HelperRoles    []StaffRole
 
 	Config *models.Configuration
 }
 
-func New(config *models.Configuration, guild *discordgo.Guild) *Permissions {
-	var ars []AdminRole
+func staffRoles(guild *discordgo.Guild, names []string) []StaffRole {
+	var srs []StaffRole
 	for _, gRole := range guild.Roles {
-		for _, aRole := range config.AdminRoles {
-			if aRole != gRole.Name {
+		for _, sRole := range names {
+			if sRole != gRole.Name {
 				continue
 			}
 
-			ar := AdminRole{
+			sr := StaffRole{
 				Name: gRole.Name,
 				ID:   gRole.ID,
 			}
-			ars = append(ars, ar)
+			srs = append(srs, sr)
 		}
 	}
 
-	var crs []models.CommandRestriction
-	for _, cRes := range config.CommandRestrictions {
-		cr := models.CommandRestriction{
-			Command:  cRes.Command,
-			Mode:     cRes.Mode,
-			Channels: cRes.Channels,
-		}
-		crs = append(crs, cr)
-	}
+	return srs
+}
 
+func New(config *models.Configuration, guild *discordgo.Guild) *Permissions {
 	return &Permissions{
-		AdminRoles:          ars,
-		CommandRestrictions: crs,
-		Config:              config,
+		AdminRoles:     staffRoles(guild, config.AdminRoles),
+		ModeratorRoles: staffRoles(guild, config.ModeratorRoles),
+		HelperRoles:    staffRoles(guild, config.HelperRoles),
+		Config:         config,
 	}
 }
 
-func (p *Permissions) CheckAdminRole(m *discordgo.Member) bool {
-	for _, aR := range p.AdminRoles {
+func hasRole(m *discordgo.Member, roles []StaffRole) bool {
+	if m == nil {
+		return false
+	}
+
+	for _, r := range roles {
+		for _, mID := range m.Roles {
+			if r.ID == mID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func hasAnyRole(m *discordgo.Member, roleIDs []string) bool {
+	if m == nil {
+		return false
+	}
+
+	for _, rID := range roleIDs {
 		for _, mID := range m.Roles {
-			if aR.ID == mID {
+			if rID == mID {
 				return true
 			}
 		}
@@ -63,6 +174,155 @@ func (p *Permissions) CheckAdminRole(m *discordgo.Member) bool {
 	return false
 }
 
+func (p *Permissions) MemberTier(m *discordgo.Member) Tier {
+	switch {
+	case hasRole(m, p.AdminRoles):
+		return TierAdmin
+	case hasRole(m, p.ModeratorRoles):
+		return TierModerator
+	case hasRole(m, p.HelperRoles):
+		return TierHelper
+	}
+
+	return TierEveryone
+}
+
+func (p *Permissions) CheckTier(m *discordgo.Member, tier Tier) bool {
+	return p.MemberTier(m) >= tier
+}
+
+func (p *Permissions) CheckAdminRole(m *discordgo.Member) bool {
+	return p.CheckTier(m, TierAdmin)
+}
+
+// highestRole returns the position of a member's highest role, or 0 for only @everyone.
+func highestRole(s discord.Session, guildID string, m *discordgo.Member) int {
+	highest := 0
+	for _, rID := range m.Roles {
+		role, err := s.GetState().Role(guildID, rID)
+		if err != nil {
+			continue
+		}
+		if role.Position > highest {
+			highest = role.Position
+		}
+	}
+
+	return highest
+}
+
+// CheckTarget reports whether a member may moderate target, and why not if they can't. Targets
+// at or above the moderator's tier, or with a higher role, are off limits to all but the owner.
+func (p *Permissions) CheckTarget(s discord.Session, guildID string, m *discordgo.Member, target *discordgo.Member) (bool, string) {
+	// Users outside the server have no tier or roles to protect them
+	if target == nil {
+		return true, ""
+	}
+	if m == nil {
+		return false, "You can't moderate that user."
+	}
+
+	if guild, err := s.GetState().Guild(guildID); err == nil {
+		if m.User != nil && guild.OwnerID == m.User.ID {
+			return true, ""
+		}
+		if target.User != nil && guild.OwnerID == target.User.ID {
+			return false, "You can't moderate the server owner."
+		}
+	}
+
+	if p.MemberTier(target) >= p.MemberTier(m) {
+		return false, "You can't moderate someone in the " + p.MemberTier(target).String() + " tier."
+	}
+	if highestRole(s, guildID, target) > highestRole(s, guildID, m) {
+		return false, "You can't moderate someone with a higher role than yours."
+	}
+
+	return true, ""
+}
+
+// CommandAccess merges any command_permissions override from the config on top of a command's defaults.
+// matches reports whether an override's command name refers to the command, so aliases can be followed.
+func (p *Permissions) CommandAccess(matches func(command string) bool, defaults CommandAccess) CommandAccess {
+	access := defaults
+
+	for _, cP := range p.Config.CommandPermissions {
+		if !matches(cP.Command) {
+			continue
+		}
+
+		if len(cP.Tier) > 0 {
+			if tier, err := ParseTier(cP.Tier); err == nil {
+				access.Tier = tier
+			}
+		}
+		if len(cP.Permissions) > 0 {
+			if perms, err := ParsePermissions(cP.Permissions); err == nil {
+				access.Permissions = perms
+			}
+		}
+		access.AllowRoles = append(access.AllowRoles, cP.AllowRoles...)
+		access.DenyRoles = append(access.DenyRoles, cP.DenyRoles...)
+	}
+
+	return access
+}
+
+// Validate parses every command_permissions override in a configuration so mistakes show up when it loads.
+func Validate(conf *models.Configuration) error {
+	for _, cP := range conf.CommandPermissions {
+		if len(cP.Command) == 0 {
+			return errors.New("command_permissions: missing command name")
+		}
+		if _, err := ParseTier(cP.Tier); err != nil {
+			return errors.New("command_permissions." + cP.Command + ": " + err.Error())
+		}
+		if _, err := ParsePermissions(cP.Permissions); err != nil {
+			return errors.New("command_permissions." + cP.Command + ": " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+func memberPermissions(s discord.Session, m *discordgo.Member, userID string, channelID string) int64 {
+	// Interactions include the member's resolved permissions
+	if m != nil && m.Permissions != 0 {
+		return m.Permissions
+	}
+
+	perms, err := s.GetState().UserChannelPermissions(userID, channelID)
+	if err != nil {
+		perms, err = s.UserChannelPermissions(userID, channelID)
+		if err != nil {
+			return 0
+		}
+	}
+
+	return perms
+}
+
+// CheckCommandAccess reports whether a member may run a command, and why not if they can't.
+func (p *Permissions) CheckCommandAccess(s discord.Session, access CommandAccess, m *discordgo.Member, userID string, channelID string) (bool, string) {
+	if hasAnyRole(m, access.DenyRoles) {
+		return false, "You are not allowed to use this command."
+	}
+
+	// Allowed roles skip the tier requirement
+	if !hasAnyRole(m, access.AllowRoles) && !p.CheckTier(m, access.Tier) {
+		return false, "This command requires the " + access.Tier.String() + " tier."
+	}
+
+	if access.Permissions != 0 {
+		perms := memberPermissions(s, m, userID, channelID)
+		if perms&discordgo.PermissionAdministrator == 0 && perms&access.Permissions != access.Permissions {
+			return false, "You are missing the Discord permissions required for this command."
+		}
+	}
+
+	return true, ""
+}
+
 func (p *Permissions) CheckIgnoredUser(m *discordgo.User) bool {
 	for _, iU := range p.Config.IgnoredUsers {
 		if iU == m.ID {
@@ -73,26 +333,98 @@ func (p *Permissions) CheckIgnoredUser(m *discordgo.User) bool {
 	return false
 }
 
-func (p *Permissions) CheckCommandRestrictions(m *discordgo.MessageCreate) bool {
-	cName := strings.Split(m.Content, " ")[0]
-	cName = strings.Replace(cName, p.Config.CommandKey, "", 1)
-	cChanID := m.ChannelID
+// AllCommands is the command name a restriction uses to apply to every command.
+const AllCommands = "*"
 
-	for _, cR := range p.CommandRestrictions {
-		if cName == cR.Command {
-			for _, cID := range cR.Channels {
-				if cID == cChanID && cR.Mode == "white" {
-					return true
-				} else if cID == cChanID && cR.Mode == "black" {
-					return false
-				}
-			}
+func restrictionApplies(cR models.CommandRestriction, names []string) bool {
+	if cR.Command == AllCommands {
+		return true
+	}
 
-			if cR.Mode == "white" {
-				return false
-			} else {
-				return true
-			}
+	for _, name := range names {
+		if name == cR.Command {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsID(ids []string, id string) bool {
+	if len(id) == 0 {
+		return false
+	}
+
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// channelLineage returns the channel, its parent channel for threads, and the category it sits in.
+func channelLineage(s discord.Session, channelID string) (channels []string, category string) {
+	channels = []string{channelID}
+
+	channel, err := s.GetState().Channel(channelID)
+	if err != nil {
+		channel, err = s.Channel(channelID)
+		if err != nil {
+			return channels, ""
+		}
+	}
+
+	if channel.IsThread() {
+		channels = append(channels, channel.ParentID)
+		parent, err := s.GetState().Channel(channel.ParentID)
+		if err != nil {
+			return channels, ""
+		}
+		channel = parent
+	}
+
+	return channels, channel.ParentID
+}
+
+func restrictionMatches(cR models.CommandRestriction, m *discordgo.Member, channels []string, category string) bool {
+	for _, cID := range channels {
+		if containsID(cR.Channels, cID) {
+			return true
+		}
+	}
+
+	if containsID(cR.Categories, category) {
+		return true
+	}
+
+	return hasAnyRole(m, cR.Roles)
+}
+
+// CheckCommandRestrictions reports whether a command may be used by a member in a channel. Names
+// are every name the command answers to. A "white" rule only allows the channels, categories and
+// roles it lists, a "black" rule denies them, and every rule that applies must pass.
+func (p *Permissions) CheckCommandRestrictions(s discord.Session, names []string, m *discordgo.Member, channelID string) bool {
+	var channels []string
+	var category string
+	resolved := false
+
+	for _, cR := range p.Config.CommandRestrictions {
+		if !restrictionApplies(cR, names) {
+			continue
+		}
+
+		if !resolved {
+			channels, category = channelLineage(s, channelID)
+			resolved = true
+		}
+
+		matched := restrictionMatches(cR, m, channels, category)
+		if cR.Mode == "white" && !matched {
+			return false
+		} else if cR.Mode == "black" && matched {
+			return false
 		}
 	}
 
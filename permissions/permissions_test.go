@@ -0,0 +1,255 @@
+package permissions
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/discord/discordtest"
+	"github.com/foxtrot/scuzzy/models"
+)
+
+const (
+	guildID     = "100000000000000001"
+	adminRole   = "400000000000000001"
+	modRole     = "400000000000000002"
+	helperRole  = "400000000000000003"
+	mutedRole   = "400000000000000004"
+	channelID   = "300000000000000001"
+	otherChanID = "300000000000000002"
+	categoryID  = "300000000000000003"
+)
+
+func testPermissions(conf *models.Configuration) *Permissions {
+	guild := &discordgo.Guild{
+		ID: guildID,
+		Roles: []*discordgo.Role{
+			{ID: adminRole, Name: "Admin"},
+			{ID: modRole, Name: "Moderator"},
+			{ID: helperRole, Name: "Helper"},
+			{ID: mutedRole, Name: "Muted"},
+		},
+	}
+	conf.AdminRoles = []string{"Admin"}
+	conf.ModeratorRoles = []string{"Moderator"}
+	conf.HelperRoles = []string{"Helper"}
+
+	return New(conf, guild)
+}
+
+func member(roles ...string) *discordgo.Member {
+	return &discordgo.Member{User: &discordgo.User{ID: "500000000000000001"}, Roles: roles}
+}
+
+// is matches command_permissions entries naming command exactly.
+func is(command string) func(string) bool {
+	return func(name string) bool {
+		return name == command
+	}
+}
+
+func TestParseTier(t *testing.T) {
+	tests := map[string]Tier{
+		"":          TierEveryone,
+		"everyone":  TierEveryone,
+		"Helper":    TierHelper,
+		"mod":       TierModerator,
+		"moderator": TierModerator,
+		"ADMIN":     TierAdmin,
+	}
+	for in, want := range tests {
+		got, err := ParseTier(in)
+		if err != nil || got != want {
+			t.Errorf("ParseTier(%q) = %v, %v, want %v", in, got, err, want)
+		}
+	}
+
+	if _, err := ParseTier("owner"); err == nil {
+		t.Error("ParseTier(\"owner\") should fail")
+	}
+}
+
+func TestParsePermissions(t *testing.T) {
+	perms, err := ParsePermissions([]string{"banmembers", "KickMembers"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perms != discordgo.PermissionBanMembers|discordgo.PermissionKickMembers {
+		t.Errorf("got %d", perms)
+	}
+
+	names := PermissionNames(perms)
+	if len(names) != 2 || names[0] != "BanMembers" || names[1] != "KickMembers" {
+		t.Errorf("PermissionNames = %v", names)
+	}
+
+	if _, err := ParsePermissions([]string{"Fly"}); err == nil {
+		t.Error("unknown permissions should fail")
+	}
+}
+
+func TestMemberTier(t *testing.T) {
+	p := testPermissions(&models.Configuration{})
+
+	tests := []struct {
+		member *discordgo.Member
+		want   Tier
+	}{
+		{nil, TierEveryone},
+		{member(), TierEveryone},
+		{member(mutedRole), TierEveryone},
+		{member(helperRole), TierHelper},
+		{member(modRole), TierModerator},
+		{member(helperRole, adminRole), TierAdmin},
+	}
+	for _, tt := range tests {
+		if got := p.MemberTier(tt.member); got != tt.want {
+			t.Errorf("MemberTier(%v) = %v, want %v", tt.member, got, tt.want)
+		}
+	}
+
+	if !p.CheckTier(member(modRole), TierHelper) {
+		t.Error("moderators should pass the helper tier")
+	}
+	if p.CheckTier(member(helperRole), TierModerator) {
+		t.Error("helpers shouldn't pass the moderator tier")
+	}
+	if !p.CheckAdminRole(member(adminRole)) || p.CheckAdminRole(member(modRole)) {
+		t.Error("only admins should pass CheckAdminRole")
+	}
+}
+
+func TestCommandAccess(t *testing.T) {
+	p := testPermissions(&models.Configuration{
+		CommandPermissions: []models.CommandPermission{
+			{Command: "purge", Tier: "helper", AllowRoles: []string{mutedRole}, Permissions: []string{"ManageMessages"}},
+			{Command: "ban", DenyRoles: []string{modRole}},
+		},
+	})
+	s := discordtest.NewSession("200000000000000000")
+
+	access := p.CommandAccess(is("purge"), CommandAccess{Tier: TierModerator})
+	if access.Tier != TierHelper || access.Permissions != discordgo.PermissionManageMessages {
+		t.Errorf("override not applied: %+v", access)
+	}
+
+	// Discord permissions are taken from the member when an interaction resolved them
+	helper := member(helperRole)
+	if ok, _ := p.CheckCommandAccess(s, access, helper, helper.User.ID, channelID); ok {
+		t.Error("a helper without Manage Messages shouldn't be able to purge")
+	}
+	helper.Permissions = discordgo.PermissionManageMessages
+	if ok, why := p.CheckCommandAccess(s, access, helper, helper.User.ID, channelID); !ok {
+		t.Errorf("a helper with Manage Messages should be able to purge: %s", why)
+	}
+
+	// Allowed roles skip the tier
+	muted := member(mutedRole)
+	muted.Permissions = discordgo.PermissionAdministrator
+	if ok, why := p.CheckCommandAccess(s, access, muted, muted.User.ID, channelID); !ok {
+		t.Errorf("allowed roles should skip the tier: %s", why)
+	}
+
+	// Denied roles win over the tier
+	ban := p.CommandAccess(is("ban"), CommandAccess{Tier: TierModerator})
+	if ok, _ := p.CheckCommandAccess(s, ban, member(modRole), "500000000000000001", channelID); ok {
+		t.Error("denied roles should be refused")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		cP   models.CommandPermission
+		ok   bool
+	}{
+		{"valid", models.CommandPermission{Command: "purge", Tier: "helper", Permissions: []string{"ManageMessages"}}, true},
+		{"no tier", models.CommandPermission{Command: "purge", AllowRoles: []string{mutedRole}}, true},
+		{"unknown tier", models.CommandPermission{Command: "purge", Tier: "owner"}, false},
+		{"unknown permission", models.CommandPermission{Command: "purge", Permissions: []string{"Fly"}}, false},
+		{"no command", models.CommandPermission{Tier: "helper"}, false},
+	}
+	for _, tt := range tests {
+		err := Validate(&models.Configuration{CommandPermissions: []models.CommandPermission{tt.cP}})
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+	}
+}
+
+func TestCheckTarget(t *testing.T) {
+	p := testPermissions(&models.Configuration{})
+
+	s := discordtest.NewSession("200000000000000000")
+	s.State.GuildAdd(&discordgo.Guild{ID: guildID, OwnerID: "500000000000000009", Roles: []*discordgo.Role{
+		{ID: adminRole, Position: 4},
+		{ID: modRole, Position: 3},
+		{ID: helperRole, Position: 2},
+		{ID: mutedRole, Position: 5},
+	}})
+
+	withID := func(id string, roles ...string) *discordgo.Member {
+		m := member(roles...)
+		m.User = &discordgo.User{ID: id}
+		return m
+	}
+	mod := withID("500000000000000002", modRole)
+
+	tests := []struct {
+		name   string
+		m      *discordgo.Member
+		target *discordgo.Member
+		want   bool
+	}{
+		{"lower tier", mod, withID("500000000000000003", helperRole), true},
+		{"not a member", mod, nil, true},
+		{"same tier", mod, withID("500000000000000003", modRole), false},
+		{"higher tier", mod, withID("500000000000000003", adminRole), false},
+		{"higher role", mod, withID("500000000000000003", mutedRole), false},
+		{"the owner", withID("500000000000000003", adminRole), withID("500000000000000009"), false},
+		{"as the owner", withID("500000000000000009"), withID("500000000000000003", adminRole), true},
+	}
+	for _, tt := range tests {
+		if got, why := p.CheckTarget(s, guildID, tt.m, tt.target); got != tt.want {
+			t.Errorf("%s: got %v (%s), want %v", tt.name, got, why, tt.want)
+		}
+	}
+}
+
+func TestCheckCommandRestrictions(t *testing.T) {
+	p := testPermissions(&models.Configuration{
+		CommandRestrictions: []models.CommandRestriction{
+			{Command: "colour", Mode: "white", Channels: []string{channelID}, Categories: []string{categoryID}},
+			{Command: AllCommands, Mode: "black", Roles: []string{mutedRole}},
+		},
+	})
+
+	s := discordtest.NewSession("200000000000000000")
+	s.State.GuildAdd(&discordgo.Guild{ID: guildID, Channels: []*discordgo.Channel{
+		{ID: channelID, GuildID: guildID},
+		{ID: otherChanID, GuildID: guildID},
+		{ID: "300000000000000004", GuildID: guildID, ParentID: categoryID},
+		{ID: "300000000000000005", GuildID: guildID, ParentID: channelID, Type: discordgo.ChannelTypeGuildPublicThread},
+	}})
+
+	names := []string{"colour", "color"}
+	tests := []struct {
+		name    string
+		names   []string
+		member  *discordgo.Member
+		channel string
+		want    bool
+	}{
+		{"whitelisted channel", names, member(), channelID, true},
+		{"other channel", names, member(), otherChanID, false},
+		{"whitelisted category", names, member(), "300000000000000004", true},
+		{"thread in a whitelisted channel", names, member(), "300000000000000005", true},
+		{"alias", []string{"color"}, member(), otherChanID, true},
+		{"unrestricted command", []string{"ping"}, member(), otherChanID, true},
+		{"blacklisted role", []string{"ping"}, member(mutedRole), otherChanID, false},
+	}
+	for _, tt := range tests {
+		if got := p.CheckCommandRestrictions(s, tt.names, tt.member, tt.channel); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Package storage keeps the state Scuzzy builds up while running, such as scheduled jobs,
+// so it survives restarts. Each guild gets a directory holding one JSON file per kind of data.
+package storage
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type Store struct {
+	Path string
+
+	sync.Mutex
+}
+
+// New opens a store rooted at path, creating the directory if needed.
+func New(path string) (*Store, error) {
+	err := os.MkdirAll(path, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{Path: path}, nil
+}
+
+func (st *Store) file(guildID string, name string) string {
+	return filepath.Join(st.Path, guildID, name+".json")
+}
+
+// Load reads a guild's data into v. Data that was never saved leaves v untouched. A nil
+// Store holds nothing, so state is kept in memory only.
+func (st *Store) Load(guildID string, name string, v interface{}) error {
+	if st == nil {
+		return nil
+	}
+
+	st.Lock()
+	defer st.Unlock()
+
+	j, err := ioutil.ReadFile(st.file(guildID, name))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(j, v)
+}
+
+// Save replaces a guild's data with v. The file is swapped in whole so a crash mid-write
+// can't leave it truncated.
+func (st *Store) Save(guildID string, name string, v interface{}) error {
+	if st == nil {
+		return nil
+	}
+
+	j, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	st.Lock()
+	defer st.Unlock()
+
+	path := st.file(guildID, name)
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	err = ioutil.WriteFile(tmp, j, 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// Names lists what has been saved under dir in a guild's data, for data kept one file per record,
+// e.g. "cases/12". The names returned can be passed to Load.
+func (st *Store) Names(guildID string, dir string) ([]string, error) {
+	if st == nil {
+		return nil, nil
+	}
+
+	st.Lock()
+	defer st.Unlock()
+
+	files, err := filepath.Glob(filepath.Join(st.Path, guildID, dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, f := range files {
+		names = append(names, dir+"/"+strings.TrimSuffix(filepath.Base(f), ".json"))
+	}
+
+	return names, nil
+}
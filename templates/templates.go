@@ -0,0 +1,274 @@
+// Package templates renders the configurable messages Scuzzy sends, such as the welcome
+// message and rules, from Go templates.
+//
+// Templates see the user, member, guild, channel and member count they are rendered for, any
+// extra values under .Vars, and can look up mentions with {{channel "rules"}} and {{role "Admin"}}.
+package templates
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/models"
+)
+
+// Data is what a template is rendered with. Any field may be nil.
+type Data struct {
+	User        *discordgo.User
+	Member      *discordgo.Member
+	Guild       *discordgo.Guild
+	Channel     *discordgo.Channel
+	MemberCount int
+
+	// Vars holds values particular to a message, e.g. .Vars.reason for a ban.
+	Vars map[string]string
+}
+
+var (
+	messagesMu sync.RWMutex
+	messages   = make(map[string]bool)
+)
+
+// RegisterMessages adds the names of messages a configuration may override under "messages".
+// It is meant to be called from a package's init, before any configuration loads.
+func RegisterMessages(names ...string) {
+	messagesMu.Lock()
+	defer messagesMu.Unlock()
+
+	for _, name := range names {
+		messages[name] = true
+	}
+}
+
+// IsMessage reports whether a message has been registered.
+func IsMessage(name string) bool {
+	messagesMu.RLock()
+	defer messagesMu.RUnlock()
+
+	return messages[name]
+}
+
+// Template is a compiled MessageTemplate.
+type Template struct {
+	content     *template.Template
+	title       *template.Template
+	description *template.Template
+	author      *template.Template
+	footer      *template.Template
+	fields      [][2]*template.Template
+
+	embed *models.EmbedTemplate
+}
+
+func channelMention(g *discordgo.Guild, name string) string {
+	if g != nil {
+		name = strings.TrimPrefix(name, "#")
+		for _, ch := range g.Channels {
+			if strings.EqualFold(ch.Name, name) || ch.ID == name {
+				return "<#" + ch.ID + ">"
+			}
+		}
+	}
+
+	return "#" + name
+}
+
+func roleMention(g *discordgo.Guild, name string) string {
+	if g != nil {
+		for _, r := range g.Roles {
+			if strings.EqualFold(r.Name, name) || r.ID == name {
+				return "<@&" + r.ID + ">"
+			}
+		}
+	}
+
+	return "@" + name
+}
+
+// funcs returns the template functions, bound to a guild when rendering.
+func funcs(g *discordgo.Guild) template.FuncMap {
+	return template.FuncMap{
+		"channel": func(name string) string { return channelMention(g, name) },
+		"role":    func(name string) string { return roleMention(g, name) },
+		"upper":   strings.ToUpper,
+		"lower":   strings.ToLower,
+	}
+}
+
+func parse(name string, text string) (*template.Template, error) {
+	if len(text) == 0 {
+		return nil, nil
+	}
+
+	t, err := template.New(name).Option("missingkey=zero").Funcs(funcs(nil)).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Compile parses every part of a message template.
+func Compile(mt models.MessageTemplate) (*Template, error) {
+	var err error
+	t := &Template{embed: mt.Embed}
+
+	t.content, err = parse("content", mt.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	if mt.Embed == nil {
+		if len(mt.Content) == 0 {
+			return nil, errors.New("empty message")
+		}
+		return t, nil
+	}
+
+	e := mt.Embed
+	if len(e.Title) == 0 && len(e.Description) == 0 && len(e.Fields) == 0 && len(e.ImageURL) == 0 {
+		return nil, errors.New("embed needs a title, description, fields or an image")
+	}
+	if len(e.Fields) > 25 {
+		return nil, errors.New("embeds can have at most 25 fields")
+	}
+
+	if t.title, err = parse("title", e.Title); err != nil {
+		return nil, err
+	}
+	if t.description, err = parse("description", e.Description); err != nil {
+		return nil, err
+	}
+	if t.author, err = parse("author", e.AuthorText); err != nil {
+		return nil, err
+	}
+	if t.footer, err = parse("footer", e.FooterText); err != nil {
+		return nil, err
+	}
+	for k, f := range e.Fields {
+		name, err := parse("field "+strconv.Itoa(k+1)+" name", f.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := parse("field "+strconv.Itoa(k+1)+" value", f.Value)
+		if err != nil {
+			return nil, err
+		}
+		if name == nil || value == nil {
+			return nil, errors.New("field " + strconv.Itoa(k+1) + " needs a name and a value")
+		}
+		t.fields = append(t.fields, [2]*template.Template{name, value})
+	}
+
+	return t, nil
+}
+
+func execute(t *template.Template, d Data) (string, error) {
+	if t == nil {
+		return "", nil
+	}
+
+	t, err := t.Clone()
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = t.Funcs(funcs(d.Guild)).Execute(&buf, d)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// Render fills in a template, returning the message to send.
+func (t *Template) Render(d Data) (*discordgo.MessageSend, error) {
+	if d.Vars == nil {
+		d.Vars = make(map[string]string)
+	}
+
+	content, err := execute(t.content, d)
+	if err != nil {
+		return nil, err
+	}
+	msg := &discordgo.MessageSend{Content: content}
+
+	if t.embed == nil {
+		return msg, nil
+	}
+
+	embed := &discordgo.MessageEmbed{
+		URL:   t.embed.URL,
+		Color: t.embed.Color,
+	}
+	if embed.Title, err = execute(t.title, d); err != nil {
+		return nil, err
+	}
+	if embed.Description, err = execute(t.description, d); err != nil {
+		return nil, err
+	}
+	if author, err := execute(t.author, d); err != nil {
+		return nil, err
+	} else if len(author) > 0 {
+		embed.Author = &discordgo.MessageEmbedAuthor{Name: author}
+	}
+	if footer, err := execute(t.footer, d); err != nil {
+		return nil, err
+	} else if len(footer) > 0 {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: footer}
+	}
+	if len(t.embed.ImageURL) > 0 {
+		embed.Image = &discordgo.MessageEmbedImage{URL: t.embed.ImageURL}
+	}
+	if len(t.embed.Thumbnail) > 0 {
+		embed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: t.embed.Thumbnail}
+	}
+	for k, f := range t.fields {
+		name, err := execute(f[0], d)
+		if err != nil {
+			return nil, err
+		}
+		value, err := execute(f[1], d)
+		if err != nil {
+			return nil, err
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   name,
+			Value:  value,
+			Inline: t.embed.Fields[k].Inline,
+		})
+	}
+	msg.Embeds = []*discordgo.MessageEmbed{embed}
+
+	return msg, nil
+}
+
+// Validate compiles every template in a configuration so mistakes show up when it loads.
+func Validate(conf *models.Configuration) error {
+	if len(conf.WelcomeText.Content) > 0 || conf.WelcomeText.Embed != nil {
+		if _, err := Compile(conf.WelcomeText); err != nil {
+			return errors.New("welcome_text: " + err.Error())
+		}
+	}
+	if len(conf.RulesText.Content) > 0 || conf.RulesText.Embed != nil {
+		if _, err := Compile(conf.RulesText); err != nil {
+			return errors.New("rules_text: " + err.Error())
+		}
+	}
+	for key, mt := range conf.Messages {
+		if !IsMessage(key) {
+			return errors.New("messages." + key + ": unknown message")
+		}
+		if _, err := Compile(mt); err != nil {
+			return errors.New("messages." + key + ": " + err.Error())
+		}
+	}
+
+	return nil
+}
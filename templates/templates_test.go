@@ -0,0 +1,128 @@
+package templates
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/foxtrot/scuzzy/models"
+)
+
+func testData() Data {
+	return Data{
+		User: &discordgo.User{ID: "500000000000000001", Username: "bob"},
+		Guild: &discordgo.Guild{
+			Name:     "Test",
+			Channels: []*discordgo.Channel{{ID: "300000000000000001", Name: "rules"}},
+			Roles:    []*discordgo.Role{{ID: "400000000000000001", Name: "Admin"}},
+		},
+		MemberCount: 42,
+		Vars:        map[string]string{"reason": "spam"},
+	}
+}
+
+func TestRenderContent(t *testing.T) {
+	tmpl, err := Compile(models.MessageTemplate{
+		Content: `Welcome {{.User.Username}} to {{.Guild.Name}}, member {{.MemberCount}}! See {{channel "#rules"}}, ask {{role "admin"}}, not {{role "Nobody"}}. {{upper .Vars.reason}}{{.Vars.missing}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := tmpl.Render(testData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Welcome bob to Test, member 42! See <#300000000000000001>, ask <@&400000000000000001>, not @Nobody. SPAM"
+	if msg.Content != want || len(msg.Embeds) != 0 {
+		t.Errorf("got %q, want %q", msg.Content, want)
+	}
+
+	// Without a guild, mentions fall back to plain names
+	tmpl, err = Compile(models.MessageTemplate{Content: `{{channel "rules"}} {{role "Admin"}}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err = tmpl.Render(Data{})
+	if err != nil || msg.Content != "#rules @Admin" {
+		t.Errorf("got %q, %v", msg.Content, err)
+	}
+}
+
+func TestRenderEmbed(t *testing.T) {
+	tmpl, err := Compile(models.MessageTemplate{Embed: &models.EmbedTemplate{
+		Title:       "Hi {{.User.Username}}",
+		Description: "Banned for {{.Vars.reason}}",
+		Color:       0xff0000,
+		FooterText:  "{{.Guild.Name}}",
+		ImageURL:    "https://example.com/ban.png",
+		Fields:      []models.EmbedFieldTemplate{{Name: "Reason", Value: "{{.Vars.reason}}", Inline: true}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := tmpl.Render(testData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msg.Embeds) != 1 {
+		t.Fatalf("expected an embed, got %+v", msg)
+	}
+	e := msg.Embeds[0]
+	if e.Title != "Hi bob" || e.Description != "Banned for spam" || e.Color != 0xff0000 || e.Footer.Text != "Test" || e.Author != nil {
+		t.Errorf("embed not rendered: %+v", e)
+	}
+	if e.Image == nil || e.Image.URL != "https://example.com/ban.png" {
+		t.Errorf("image not set: %+v", e.Image)
+	}
+	if len(e.Fields) != 1 || e.Fields[0].Value != "spam" || !e.Fields[0].Inline {
+		t.Errorf("fields not rendered: %+v", e.Fields)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := map[string]models.MessageTemplate{
+		"empty":       {},
+		"bad syntax":  {Content: "{{.User"},
+		"unknown fn":  {Content: `{{emoji "wave"}}`},
+		"empty embed": {Embed: &models.EmbedTemplate{Color: 1}},
+		"half field":  {Embed: &models.EmbedTemplate{Fields: []models.EmbedFieldTemplate{{Name: "Reason"}}}},
+		"bad title":   {Embed: &models.EmbedTemplate{Title: "{{end}}"}},
+	}
+	for name, mt := range tests {
+		if _, err := Compile(mt); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	fields := make([]models.EmbedFieldTemplate, 26)
+	for k := range fields {
+		fields[k] = models.EmbedFieldTemplate{Name: "n", Value: "v"}
+	}
+	if _, err := Compile(models.MessageTemplate{Embed: &models.EmbedTemplate{Fields: fields}}); err == nil {
+		t.Error("more than 25 fields should be rejected")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	RegisterMessages("test_message")
+
+	conf := &models.Configuration{
+		WelcomeText: models.MessageTemplate{Content: "Hi {{.User.Username}}"},
+		Messages:    map[string]models.MessageTemplate{"test_message": {Content: "ok"}},
+	}
+	if err := Validate(conf); err != nil {
+		t.Errorf("valid configuration rejected: %v", err)
+	}
+
+	conf.Messages["not_a_message"] = models.MessageTemplate{Content: "ok"}
+	if err := Validate(conf); err == nil {
+		t.Error("unknown messages should be rejected")
+	}
+	delete(conf.Messages, "not_a_message")
+
+	conf.RulesText = models.MessageTemplate{Content: "{{.Guild"}
+	if err := Validate(conf); err == nil {
+		t.Error("broken rules text should be rejected")
+	}
+}